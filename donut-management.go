@@ -0,0 +1,522 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio-io/cli"
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/server"
+	donutstorage "github.com/minio-io/minio/pkg/storage/donut"
+	"github.com/minio-io/minio/pkg/storage/drivers/donut"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+var donutManagementCommands = []cli.Command{
+	donutInfoCmd,
+	donutHealCmd,
+	donutRebalanceCmd,
+	donutScrubCmd,
+	donutAttachDiskCmd,
+	donutDetachDiskCmd,
+	donutSetReserveCmd,
+}
+
+var donutManagementCmd = cli.Command{
+	Name:        "donut",
+	Subcommands: donutManagementCommands,
+	Description: "Manage a donut, commands modifying it refuse to run while a minio server serves it",
+}
+
+var donutManagementFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "json",
+		Usage: "print output in JSON format",
+	},
+}
+
+var donutInfoCmd = cli.Command{
+	Name:        "info",
+	Description: "Show disks, their usage and the last scrub and rebalance of a donut",
+	Action:      runDonutInfo,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...]
+
+EXAMPLES:
+  1. Show the donut served from "/mnt/backup"
+      $ minio donut {{.Name}} /mnt/backup
+
+  2. Show the donut served from a collection of paths in JSON format
+      $ minio donut {{.Name}} --json /mnt/disk1 /mnt/disk2 host1:9002/mnt/disk1
+
+`,
+}
+
+var donutHealCmd = cli.Command{
+	Name:        "heal",
+	Description: "Format replaced disks and reconstruct missing or unreadable slices of all objects",
+	Action:      runDonutHeal,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...]
+
+EXAMPLES:
+  1. Heal the donut served from "/mnt/backup"
+      $ minio donut {{.Name}} /mnt/backup
+
+`,
+}
+
+var donutRebalanceCmd = cli.Command{
+	Name:        "rebalance",
+	Description: "Move objects onto the erasure sets they are placed on since disks were attached",
+	Action:      runDonutRebalance,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...]
+
+EXAMPLES:
+  1. Rebalance the donut served from a collection of paths
+      $ minio donut {{.Name}} /mnt/disk1 /mnt/disk2 /mnt/disk3 /mnt/disk4
+
+`,
+}
+
+var donutScrubCmd = cli.Command{
+	Name:        "scrub",
+	Description: "Verify every block of all objects for bitrot, healing corrupted objects",
+	Action:      runDonutScrub,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...]
+
+EXAMPLES:
+  1. Scrub the donut served from "/mnt/backup"
+      $ minio donut {{.Name}} /mnt/backup
+
+`,
+}
+
+var donutAttachDiskCmd = cli.Command{
+	Name:        "attach-disk",
+	Description: "Attach the last PATH as a new disk to the donut served from the other paths",
+	Action:      runDonutAttachDisk,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...] DISK
+
+EXAMPLES:
+  1. Attach "/mnt/disk3" to the donut served from "/mnt/disk1" and "/mnt/disk2", then serve it
+     from all three paths and rebalance
+      $ minio donut {{.Name}} /mnt/disk1 /mnt/disk2 /mnt/disk3
+      $ minio donut rebalance /mnt/disk1 /mnt/disk2 /mnt/disk3
+
+`,
+}
+
+var donutDetachDiskCmd = cli.Command{
+	Name:        "detach-disk",
+	Description: "Detach the last PATH, the last disk of its node, from the donut served from all paths",
+	Action:      runDonutDetachDisk,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PATH [PATH...] DISK
+
+EXAMPLES:
+  1. Detach "/mnt/disk3" from the donut served from "/mnt/disk1", "/mnt/disk2" and "/mnt/disk3",
+     then serve it from the first two paths. Refused if any object spans more disks than remain,
+     heal reconstructs the slices of narrower objects on the remaining disks
+      $ minio donut {{.Name}} /mnt/disk1 /mnt/disk2 /mnt/disk3
+      $ minio donut heal /mnt/disk1 /mnt/disk2
+
+`,
+}
+
+var donutSetReserveCmd = cli.Command{
+	Name:        "set-reserve",
+	Description: "Keep PERCENT of every disk free, writes not fitting beside it fail with XMinioStorageFull",
+	Action:      runDonutSetReserve,
+	Flags:       donutManagementFlags,
+	CustomHelpTemplate: `NAME:
+  minio donut {{.Name}} - {{.Description}}
+
+USAGE:
+  minio donut {{.Name}} [--json] PERCENT PATH [PATH...]
+
+EXAMPLES:
+  1. Keep 10% of every disk of the donut served from "/mnt/backup" free
+      $ minio donut {{.Name}} 10 /mnt/backup
+
+`,
+}
+
+// getDonutPaths - paths a donut is served from, shows help of the command if none were given
+func getDonutPaths(c *cli.Context, minPaths int) []string {
+	if len(c.Args()) < minPaths {
+		cli.ShowCommandHelpAndExit(c, c.Command.Name, 1) // last argument is exit code
+	}
+	var paths []string
+	for _, arg := range c.Args() {
+		paths = append(paths, strings.TrimSpace(arg))
+	}
+	return paths
+}
+
+// lockDonut - lock all disks of the donut served from paths against other processes, exits while
+// the donut is served by another process. Locks are released on exit
+func lockDonut(paths []string) {
+	if err := server.SetRemoteDiskCredentials(paths); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if _, err := donut.LockDonut(paths); err != nil {
+		if iodine.ToError(err) == donutstorage.ErrDiskLocked {
+			log.Fatalln("Donut is in use by another minio process, stop it first")
+		}
+		log.Fatalln(iodine.New(err, nil))
+	}
+}
+
+// openDonut - open the donut served from paths for modification, exits on failure. Its disks
+// are to be locked with lockDonut beforehand
+func openDonut(paths []string) donutstorage.Donut {
+	d, err := donut.OpenDonut(paths)
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	return d
+}
+
+// openReadOnlyDonut - open the donut served from paths for inspection, exits on failure
+func openReadOnlyDonut(paths []string) donutstorage.Donut {
+	if err := server.SetRemoteDiskCredentials(paths); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	d, err := donut.OpenReadOnlyDonut(paths)
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	return d
+}
+
+// printJSON - print v indented in JSON format
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	fmt.Println(string(data))
+}
+
+// formatTime - human readable time, "never" for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.RFC1123)
+}
+
+func printScrubStats(title string, stats donutstorage.ScrubStats) {
+	fmt.Printf("%s: started %s, completed %s\n", title, formatTime(stats.Started), formatTime(stats.Completed))
+	fmt.Printf("  Objects: %d, Bitrot slices: %d, Healed: %d, Failed: %d, Unrecoverable: %d\n",
+		stats.Objects, stats.BitrotSlices, stats.Healed, stats.Failed, stats.Unrecoverable)
+}
+
+func printRebalanceStats(title string, stats donutstorage.RebalanceStats) {
+	fmt.Printf("%s: started %s, completed %s\n", title, formatTime(stats.Started), formatTime(stats.Completed))
+	fmt.Printf("  Objects: %d, Migrated: %d, Failed: %d\n", stats.Objects, stats.Migrated, stats.Failed)
+}
+
+func printHealReport(report donutstorage.HealReport) {
+	fmt.Printf("Healed: %d, Failed: %d, Unrecoverable: %d\n", report.Healed, report.Failed, report.Unrecoverable)
+}
+
+func printDonutInfo(info donutstorage.DonutInfo) {
+	var nodes []string
+	for node := range info.Disks {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		fmt.Printf("Node: %s\n", node)
+		for order, disk := range info.Disks[node] {
+			fsInfo := info.FSInfo[node][order]
+			if fsInfo == nil {
+				fmt.Printf("  %2d  %s  unavailable\n", order, disk)
+				continue
+			}
+			fmt.Printf("  %2d  %s  %s, %s total, %s free\n", order, disk, fsInfo["FSType"], fsInfo["Total"], fsInfo["Free"])
+		}
+	}
+	fmt.Printf("Disk reserve: %d%%\n", info.DiskReserve)
+	printScrubStats("Last scrub", info.LastScrub)
+	if !info.CurrentScrub.Started.IsZero() {
+		printScrubStats("Current scrub", info.CurrentScrub)
+	}
+	printRebalanceStats("Last rebalance", info.LastRebalance)
+	if !info.CurrentRebalance.Started.IsZero() {
+		printRebalanceStats("Current rebalance", info.CurrentRebalance)
+	}
+}
+
+// getDonutInfo - info of a donut, exits on failure
+func getDonutInfo(d donutstorage.Donut) donutstorage.DonutInfo {
+	info, err := d.Info()
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	return info
+}
+
+func runDonutInfo(c *cli.Context) {
+	d := openReadOnlyDonut(getDonutPaths(c, 1))
+	info := getDonutInfo(d)
+	if c.Bool("json") {
+		printJSON(info)
+		return
+	}
+	printDonutInfo(info)
+}
+
+func runDonutHeal(c *cli.Context) {
+	paths := getDonutPaths(c, 1)
+	lockDonut(paths)
+	d := openDonut(paths)
+	report, err := d.Heal()
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	// replaced disks were given their identity
+	if err := d.SaveConfig(); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if c.Bool("json") {
+		printJSON(report)
+		return
+	}
+	printHealReport(report)
+}
+
+func runDonutRebalance(c *cli.Context) {
+	paths := getDonutPaths(c, 1)
+	lockDonut(paths)
+	d := openDonut(paths)
+	if err := d.Rebalance(); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	info := getDonutInfo(d)
+	if c.Bool("json") {
+		printJSON(info.LastRebalance)
+		return
+	}
+	printRebalanceStats("Rebalance", info.LastRebalance)
+}
+
+func runDonutScrub(c *cli.Context) {
+	paths := getDonutPaths(c, 1)
+	lockDonut(paths)
+	d := openDonut(paths)
+	stats, err := d.Scrub()
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if c.Bool("json") {
+		printJSON(stats)
+		return
+	}
+	printScrubStats("Scrub", stats)
+}
+
+// verifyDiskCountChange - disks of a donut served from a single local path are fixed, the last
+// path only changes the disks of a donut served from a collection of paths
+func verifyDiskCountChange(paths []string) {
+	if len(paths) == 1 && !donutstorage.IsRemoteDisk(paths[0]) {
+		log.Fatalln(iodine.New(errors.New("disks of a donut served from a single path can not be changed"),
+			map[string]string{"path": paths[0]}))
+	}
+}
+
+// replaceDonutNode - attach a node in place of the node of the same name, its disks in the given order
+func replaceDonutNode(d donutstorage.Donut, hostname string, disks []string, orders map[string]int) error {
+	node, err := donutstorage.NewNode(hostname)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, diskPath := range disks {
+		disk, err := donutstorage.OpenDisk(diskPath, orders[diskPath])
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := node.AttachDisk(disk); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := d.AttachNode(node); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// getDiskOrders - order of every attached disk of a node, by path
+func getDiskOrders(attached []string) map[string]int {
+	orders := make(map[string]int)
+	for order, diskPath := range attached {
+		orders[diskPath] = order
+	}
+	return orders
+}
+
+func runDonutAttachDisk(c *cli.Context) {
+	paths := getDonutPaths(c, 2)
+	verifyDiskCountChange(paths[:len(paths)-1])
+	// the new disk is locked along with the attached ones
+	lockDonut(paths)
+	d := openDonut(paths[:len(paths)-1])
+	info := getDonutInfo(d)
+	for hostname, disks := range donut.CreateNodeDiskMap(paths) {
+		attached := info.Disks[hostname]
+		if len(disks) == len(attached) {
+			continue
+		}
+		// attached disks keep their order, the new disk is ordered after them
+		orders := getDiskOrders(attached)
+		for _, diskPath := range disks {
+			if _, ok := orders[diskPath]; !ok {
+				orders[diskPath] = len(attached)
+			}
+		}
+		if err := replaceDonutNode(d, hostname, disks, orders); err != nil {
+			log.Fatalln(iodine.New(err, nil))
+		}
+	}
+	// the new disk is formatted and given the bucket slices
+	report, err := d.Heal()
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if err := d.SaveConfig(); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	info = getDonutInfo(d)
+	if c.Bool("json") {
+		printJSON(info)
+		return
+	}
+	printHealReport(report)
+	printDonutInfo(info)
+}
+
+func runDonutDetachDisk(c *cli.Context) {
+	paths := getDonutPaths(c, 2)
+	verifyDiskCountChange(paths[:len(paths)-1])
+	lockDonut(paths)
+	d := openDonut(paths)
+	info := getDonutInfo(d)
+	nodeDiskMap := donut.CreateNodeDiskMap(paths[:len(paths)-1])
+	for hostname, attached := range info.Disks {
+		disks := nodeDiskMap[hostname]
+		if len(disks) == len(attached) {
+			continue
+		}
+		if len(disks) == 0 {
+			node, err := donutstorage.NewNode(hostname)
+			if err != nil {
+				log.Fatalln(iodine.New(err, nil))
+			}
+			if err := d.DetachNode(node); err != nil {
+				log.Fatalln(iodine.New(err, nil))
+			}
+			continue
+		}
+		// remaining disks keep their order, only the last disk of a node leaves no gap behind
+		orders := getDiskOrders(attached)
+		for _, diskPath := range disks {
+			if order, ok := orders[diskPath]; !ok || order >= len(disks) {
+				log.Fatalln(iodine.New(errors.New("only the last disk of a node can be detached"),
+					map[string]string{"node": hostname, "disk": attached[len(attached)-1]}))
+			}
+		}
+		if err := replaceDonutNode(d, hostname, disks, orders); err != nil {
+			log.Fatalln(iodine.New(err, nil))
+		}
+	}
+	// objects spread over the detached disk would be missing a slice heal cannot place
+	tooWide, err := d.ListTooWideObjects()
+	if err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if len(tooWide) > 0 {
+		log.Fatalln(iodine.New(errors.New("objects span more disks than would remain attached, disk not detached"),
+			map[string]string{"objects": strconv.Itoa(len(tooWide)), "first": tooWide[0]}))
+	}
+	if err := d.SaveConfig(); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	info = getDonutInfo(d)
+	if c.Bool("json") {
+		printJSON(info)
+		return
+	}
+	printDonutInfo(info)
+}
+
+func runDonutSetReserve(c *cli.Context) {
+	args := getDonutPaths(c, 2)
+	percent, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalln(iodine.New(err, map[string]string{"percent": args[0]}))
+	}
+	lockDonut(args[1:])
+	d := openDonut(args[1:])
+	if err := d.SetDiskReserve(percent); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	if err := d.SaveConfig(); err != nil {
+		log.Fatalln(iodine.New(err, nil))
+	}
+	info := getDonutInfo(d)
+	if c.Bool("json") {
+		printJSON(info)
+		return
+	}
+	printDonutInfo(info)
+}
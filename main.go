@@ -38,11 +38,13 @@ var globalDebugFlag = false
 
 var commands = []cli.Command{
 	modeCmd,
+	donutManagementCmd,
 }
 
 var modeCommands = []cli.Command{
 	memoryCmd,
 	donutCmd,
+	diskCmd,
 }
 
 var modeCmd = cli.Command{
@@ -93,6 +95,30 @@ EXAMPLES:
 `,
 }
 
+var diskCmd = cli.Command{
+	Name:        "disk",
+	Description: "Export local disks on API address to donuts of other hosts",
+	Action:      runDisk,
+	CustomHelpTemplate: `NAME:
+  minio mode {{.Name}} - {{.Description}}
+
+USAGE:
+  minio mode {{.Name}} PATH [PATH...]
+
+EXAMPLES:
+  1. Export two disks on the loopback interface, the default unless an API address is given
+      $ minio mode {{.Name}} /mnt/disk1 /mnt/disk2
+
+  2. Export two disks to other hosts on port 9002, requests must be signed with an access key
+     configured on both hosts
+      $ minio --api-address :9002 mode {{.Name}} /mnt/disk1 /mnt/disk2
+
+  3. Create a donut volume from disks exported by another host
+      $ minio mode donut host1:9002/mnt/disk1 host1:9002/mnt/disk2
+
+`,
+}
+
 var flags = []cli.Flag{
 	cli.StringFlag{
 		Name:  "domain,d",
@@ -184,6 +210,27 @@ func runDonut(c *cli.Context) {
 	server.StartMinio(servers)
 }
 
+func runDisk(c *cli.Context) {
+	if len(c.Args()) < 1 {
+		cli.ShowCommandHelpAndExit(c, "disk", 1) // last argument is exit code
+	}
+	var paths []string
+	for _, arg := range c.Args() {
+		paths = append(paths, strings.TrimSpace(arg))
+	}
+	apiServerConfig := getAPIServerConfig(c)
+	// disks are exported to other hosts only when asked to
+	if !c.GlobalIsSet("api-address") {
+		apiServerConfig.Address = "127.0.0.1:9000"
+	}
+	diskServer := server.DiskFactory{
+		Config: apiServerConfig,
+		Paths:  paths,
+	}
+	servers := []server.StartServerFunc{diskServer.GetStartServerFunc()}
+	server.StartMinio(servers)
+}
+
 func getAPIServerConfig(c *cli.Context) httpserver.Config {
 	certFile := c.String("cert")
 	keyFile := c.String("key")
@@ -16,14 +16,23 @@
 
 package api
 
-import "net/http"
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/minio-io/minio/pkg/storage/drivers"
+)
 
 // Please read for more information - http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#canned-acl
 //
-// Here We are only supporting 'acl's through request headers not through their request body
+// acls are supported through canned 'x-amz-acl' header, explicit 'x-amz-grant-*' headers
+// and AccessControlPolicy request body
 // http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#setting-acls
 
-// Minio only supports three types for now i.e 'private, public-read, public-read-write'
+// Minio only supports three canned types for now i.e 'private, public-read, public-read-write'
 
 // ACLType - different acl types
 type ACLType int
@@ -77,3 +86,158 @@ func getACLTypeString(acl ACLType) string {
 		return "private"
 	}
 }
+
+// grantee types as serialized in xsi:type
+const (
+	canonicalUserGrantee = "CanonicalUser"
+	groupGrantee         = "Group"
+	emailGrantee         = "AmazonCustomerByEmail"
+)
+
+// errors returned while parsing acls
+var (
+	errMalformedACL   = errors.New("malformed acl")
+	errUnsupportedACL = errors.New("unsupported acl")
+)
+
+// 'x-amz-grant-*' headers, in the order grants are generated
+var grantHeaders = []struct {
+	header     string
+	permission drivers.Permission
+}{
+	{"x-amz-grant-full-control", drivers.PermissionFullControl},
+	{"x-amz-grant-read", drivers.PermissionRead},
+	{"x-amz-grant-write", drivers.PermissionWrite},
+	{"x-amz-grant-read-acp", drivers.PermissionReadACP},
+	{"x-amz-grant-write-acp", drivers.PermissionWriteACP},
+}
+
+// check if request carries any 'x-amz-grant-*' header
+func isRequestGrantHeaders(req *http.Request) bool {
+	for _, grantHeader := range grantHeaders {
+		if req.Header.Get(grantHeader.header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parse a single grantee from 'x-amz-grant-*' header value i.e id="value" or uri="value"
+func getGranteeFromHeaderValue(value string) (drivers.Grantee, error) {
+	keyValue := strings.SplitN(strings.TrimSpace(value), "=", 2)
+	if len(keyValue) != 2 {
+		return drivers.Grantee{}, errMalformedACL
+	}
+	key := strings.ToLower(strings.TrimSpace(keyValue[0]))
+	value = strings.Trim(strings.TrimSpace(keyValue[1]), "\"")
+	if value == "" {
+		return drivers.Grantee{}, errMalformedACL
+	}
+	switch key {
+	case "id":
+		return drivers.Grantee{ID: value}, nil
+	case "uri":
+		if value != drivers.AllUsersGroup && value != drivers.AuthenticatedUsersGroup {
+			return drivers.Grantee{}, errMalformedACL
+		}
+		return drivers.Grantee{URI: value}, nil
+	case "emailaddress":
+		return drivers.Grantee{}, errUnsupportedACL
+	default:
+		return drivers.Grantee{}, errMalformedACL
+	}
+}
+
+// Get access control policy from 'x-amz-grant-*' headers
+func getACLPolicyFromGrantHeaders(req *http.Request) (drivers.AccessControlPolicy, error) {
+	policy := drivers.AccessControlPolicy{Owner: drivers.DefaultOwner}
+	for _, grantHeader := range grantHeaders {
+		headerValue := req.Header.Get(grantHeader.header)
+		if headerValue == "" {
+			continue
+		}
+		for _, value := range strings.Split(headerValue, ",") {
+			grantee, err := getGranteeFromHeaderValue(value)
+			if err != nil {
+				return drivers.AccessControlPolicy{}, err
+			}
+			policy.Grants = append(policy.Grants, drivers.Grant{Grantee: grantee, Permission: grantHeader.permission})
+		}
+	}
+	return policy, nil
+}
+
+// Get access control policy from AccessControlPolicy request body
+func getACLPolicyFromBody(body io.Reader) (drivers.AccessControlPolicy, error) {
+	var acp AccessControlPolicy
+	if err := xml.NewDecoder(body).Decode(&acp); err != nil {
+		return drivers.AccessControlPolicy{}, errMalformedACL
+	}
+	// minio is single tenant for now, a different owner cannot be set
+	if acp.Owner.ID != "" && acp.Owner.ID != drivers.DefaultOwner.ID {
+		return drivers.AccessControlPolicy{}, errMalformedACL
+	}
+	policy := drivers.AccessControlPolicy{Owner: drivers.DefaultOwner}
+	for _, grant := range acp.AccessControlList.Grant {
+		permission := drivers.Permission(grant.Permission)
+		if !permission.IsValid() {
+			return drivers.AccessControlPolicy{}, errMalformedACL
+		}
+		var grantee drivers.Grantee
+		switch {
+		case grant.Grantee.Type == emailGrantee || grant.Grantee.EmailAddress != "":
+			return drivers.AccessControlPolicy{}, errUnsupportedACL
+		case grant.Grantee.Type == groupGrantee || grant.Grantee.URI != "":
+			if grant.Grantee.URI != drivers.AllUsersGroup && grant.Grantee.URI != drivers.AuthenticatedUsersGroup {
+				return drivers.AccessControlPolicy{}, errMalformedACL
+			}
+			grantee.URI = grant.Grantee.URI
+		case grant.Grantee.Type == canonicalUserGrantee || grant.Grantee.ID != "":
+			if grant.Grantee.ID == "" {
+				return drivers.AccessControlPolicy{}, errMalformedACL
+			}
+			grantee.ID = grant.Grantee.ID
+			grantee.DisplayName = grant.Grantee.DisplayName
+		default:
+			return drivers.AccessControlPolicy{}, errMalformedACL
+		}
+		policy.Grants = append(policy.Grants, drivers.Grant{Grantee: grantee, Permission: permission})
+	}
+	return policy, nil
+}
+
+// Get access control policy requested by PUT ?acl, explicit grant headers take precedence over
+// canned 'x-amz-acl' header, which takes precedence over request body
+func getACLPolicyFromRequest(req *http.Request) (drivers.AccessControlPolicy, error) {
+	switch {
+	case isRequestGrantHeaders(req):
+		return getACLPolicyFromGrantHeaders(req)
+	case req.Header.Get("x-amz-acl") != "":
+		aclType := getACLType(req)
+		if aclType == unsupportedACLType {
+			return drivers.AccessControlPolicy{}, errUnsupportedACL
+		}
+		return drivers.NewCannedACLPolicy(drivers.BucketACL(getACLTypeString(aclType)), drivers.DefaultOwner), nil
+	default:
+		return getACLPolicyFromBody(req.Body)
+	}
+}
+
+// getACLPolicy - access control policy of a resource, drivers which do not carry a policy
+// fall back to the canned acl
+func getACLPolicy(policy drivers.AccessControlPolicy, acl drivers.BucketACL) drivers.AccessControlPolicy {
+	if policy.IsEmpty() {
+		return drivers.NewCannedACLPolicy(acl, drivers.DefaultOwner)
+	}
+	return policy
+}
+
+// getRequester - canonical user ID of the requester, empty for anonymous requests
+//
+// minio is single tenant for now, every authenticated user acts on behalf of the owner
+func getRequester(req *http.Request) string {
+	if stripAccessKey(req) == "" {
+		return ""
+	}
+	return drivers.DefaultOwner.ID
+}
@@ -25,37 +25,44 @@ import (
 	"github.com/minio-io/minio/pkg/utils/log"
 )
 
-func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acceptsContentType contentType) bool {
+// getBucketACLPolicy - verify if bucket exists and return its access control policy
+func (server *minioAPI) getBucketACLPolicy(w http.ResponseWriter, req *http.Request, acceptsContentType contentType) (drivers.AccessControlPolicy, bool) {
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
 
 	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
 	switch iodine.ToError(err).(type) {
+	case nil:
+		return getACLPolicy(bucketMetadata.AccessControlPolicy, bucketMetadata.ACL), true
 	case drivers.BucketNotFound:
 		{
 			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
-			return false
+			return drivers.AccessControlPolicy{}, false
 		}
 	case drivers.BucketNameInvalid:
 		{
 			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
-			return false
-		}
-	case nil:
-
-		if stripAccessKey(req) == "" && bucketMetadata.ACL.IsPrivate() {
-			return true
-			// Uncomment this before release
-			// writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
-			// return false
+			return drivers.AccessControlPolicy{}, false
 		}
-		if bucketMetadata.ACL.IsPublicRead() && req.Method == "PUT" {
-			return true
-			// Uncomment this before release
-			// writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
-			// return false
+	default:
+		{
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return drivers.AccessControlPolicy{}, false
 		}
 	}
+}
+
+// isValidOp - verify if bucket exists and its acl grants permission to the requester
+func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acceptsContentType contentType, permission drivers.Permission) bool {
+	policy, ok := server.getBucketACLPolicy(w, req, acceptsContentType)
+	if !ok {
+		return false
+	}
+	if !policy.IsAllowed(getRequester(req), permission) {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return false
+	}
 	return true
 }
 
@@ -66,13 +73,18 @@ func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acce
 // criteria to return a subset of the objects in a bucket.
 //
 func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Request) {
+	if isRequestACL(req.URL.Query()) {
+		server.getBucketACLHandler(w, req)
+		return
+	}
+
 	acceptsContentType := getContentType(req)
 	if acceptsContentType == unknownContentType {
 		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
 		return
 	}
 	// verify if bucket allows this operation
-	if !server.isValidOp(w, req, acceptsContentType) {
+	if !server.isValidOp(w, req, acceptsContentType, drivers.PermissionRead) {
 		return
 	}
 
@@ -81,6 +93,27 @@ func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Requ
 		resources.Maxkeys = maxObjectList
 	}
 
+	// list objects version 2 shares the same paging, continuation token or start-after
+	// simply translate into a marker
+	isListV2 := isRequestListObjectsV2(req.URL.Query())
+	var listV2Resources listObjectsV2Resources
+	if isListV2 {
+		listV2Resources = getListObjectsV2Resources(req.URL.Query())
+		if listV2Resources.EncodingType != "" && listV2Resources.EncodingType != "url" {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+		resources.Marker = listV2Resources.StartAfter
+		if listV2Resources.ContinuationToken != "" {
+			marker, err := getMarkerFromContinuationToken(listV2Resources.ContinuationToken)
+			if err != nil {
+				writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+				return
+			}
+			resources.Marker = marker
+		}
+	}
+
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
 
@@ -92,7 +125,12 @@ func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Requ
 			setCommonHeaders(w, getContentTypeString(acceptsContentType))
 			w.WriteHeader(http.StatusOK)
 			// write body
-			response := generateObjectsListResult(bucket, objects, resources)
+			var response interface{}
+			if isListV2 {
+				response = generateObjectsListV2Result(bucket, objects, resources, listV2Resources)
+			} else {
+				response = generateObjectsListResult(bucket, objects, resources)
+			}
 			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
 			w.Write(encodedSuccessResponse)
 		}
@@ -106,7 +144,7 @@ func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Requ
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -138,7 +176,7 @@ func (server *minioAPI) listBucketsHandler(w http.ResponseWriter, req *http.Requ
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -148,7 +186,7 @@ func (server *minioAPI) listBucketsHandler(w http.ResponseWriter, req *http.Requ
 // ----------
 // This implementation of the PUT operation creates a new bucket for authenticated request
 func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Request) {
-	if isRequestBucketACL(req.URL.Query()) {
+	if isRequestACL(req.URL.Query()) {
 		server.putBucketACLHandler(w, req)
 		return
 	}
@@ -166,9 +204,16 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	// read from 'x-amz-storage-class', default storage class of objects in the bucket
+	storageClass := req.Header.Get("x-amz-storage-class")
+	if !drivers.IsValidStorageClass(storageClass) {
+		writeErrorResponse(w, req, InvalidStorageClass, acceptsContentType, req.URL.Path)
+		return
+	}
+
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
-	err := server.driver.CreateBucket(bucket, getACLTypeString(aclType))
+	err := server.driver.CreateBucket(bucket, getACLTypeString(aclType), storageClass)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -190,15 +235,43 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
 }
 
+// GET Bucket ACL
+// ----------
+// This implementation of the GET operation returns the access control policy of a bucket
+func (server *minioAPI) getBucketACLHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if acceptsContentType == unknownContentType {
+		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	policy, ok := server.getBucketACLPolicy(w, req, acceptsContentType)
+	if !ok {
+		return
+	}
+	if !policy.IsAllowed(getRequester(req), drivers.PermissionReadACP) {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	response := generateAccessControlPolicyResponse(policy)
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType))
+	w.WriteHeader(http.StatusOK)
+	// write body
+	w.Write(encodedSuccessResponse)
+}
+
 // PUT Bucket ACL
 // ----------
-// This implementation of the PUT operation modifies the bucketACL for authenticated request
+// This implementation of the PUT operation modifies the bucketACL for authenticated request,
+// acl is read from 'x-amz-grant-*' headers, 'x-amz-acl' header or AccessControlPolicy body
 func (server *minioAPI) putBucketACLHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
 	if acceptsContentType == unknownContentType {
@@ -206,16 +279,25 @@ func (server *minioAPI) putBucketACLHandler(w http.ResponseWriter, req *http.Req
 		return
 	}
 
-	// read from 'x-amz-acl'
-	aclType := getACLType(req)
-	if aclType == unsupportedACLType {
+	// verify if bucket allows this operation
+	if !server.isValidOp(w, req, acceptsContentType, drivers.PermissionWriteACP) {
+		return
+	}
+
+	policy, err := getACLPolicyFromRequest(req)
+	switch err {
+	case nil:
+	case errUnsupportedACL:
 		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
 		return
+	default:
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
 	}
 
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
-	err := server.driver.SetBucketMetadata(bucket, getACLTypeString(aclType))
+	err = server.driver.SetBucketACL(bucket, policy)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -233,7 +315,7 @@ func (server *minioAPI) putBucketACLHandler(w http.ResponseWriter, req *http.Req
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -253,7 +335,7 @@ func (server *minioAPI) headBucketHandler(w http.ResponseWriter, req *http.Reque
 	}
 
 	// verify if bucket allows this operation
-	if !server.isValidOp(w, req, acceptsContentType) {
+	if !server.isValidOp(w, req, acceptsContentType, drivers.PermissionRead) {
 		return
 	}
 
@@ -31,6 +31,7 @@ type ObjectListResponse struct {
 	Name           string
 	Prefix         string
 	Marker         string
+	NextMarker     string `xml:",omitempty" json:",omitempty"`
 	MaxKeys        int
 	Delimiter      string
 	IsTruncated    bool
@@ -38,6 +39,23 @@ type ObjectListResponse struct {
 	CommonPrefixes []*Prefix
 }
 
+// ObjectListV2Response format
+type ObjectListV2Response struct {
+	XMLName               xml.Name `xml:"ListBucketResult" json:"-"`
+	Name                  string
+	Prefix                string
+	StartAfter            string `xml:",omitempty" json:",omitempty"`
+	ContinuationToken     string `xml:",omitempty" json:",omitempty"`
+	NextContinuationToken string `xml:",omitempty" json:",omitempty"`
+	KeyCount              int
+	MaxKeys               int
+	Delimiter             string
+	EncodingType          string `xml:",omitempty" json:",omitempty"`
+	IsTruncated           bool
+	Contents              []*Item
+	CommonPrefixes        []*Prefix
+}
+
 // BucketListResponse - bucket list response format
 type BucketListResponse struct {
 	XMLName xml.Name `xml:"ListAllMyBucketsResult" json:"-"`
@@ -65,7 +83,7 @@ type Item struct {
 	ETag         string
 	Size         int64
 	StorageClass string
-	Owner        Owner
+	Owner        *Owner `xml:",omitempty" json:",omitempty"`
 }
 
 // Owner - bucket owner/principal
@@ -74,6 +92,30 @@ type Owner struct {
 	DisplayName string
 }
 
+// AccessControlPolicy - bucket or object acl request and response format
+type AccessControlPolicy struct {
+	XMLName           xml.Name `xml:"AccessControlPolicy" json:"-"`
+	Owner             Owner
+	AccessControlList struct {
+		Grant []*Grant
+	} // Grants are nested
+}
+
+// Grant - permission granted to a grantee
+type Grant struct {
+	Grantee    Grantee
+	Permission string
+}
+
+// Grantee - canonical user or a predefined group
+type Grantee struct {
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr" json:",omitempty"`
+	ID           string `xml:",omitempty" json:",omitempty"`
+	DisplayName  string `xml:",omitempty" json:",omitempty"`
+	URI          string `xml:",omitempty" json:",omitempty"`
+	EmailAddress string `xml:",omitempty" json:",omitempty"`
+}
+
 // List of not implemented bucket queries
 var unimplementedBucketResourceNames = map[string]bool{
 	"policy":         true,
@@ -17,8 +17,14 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -38,6 +44,56 @@ type resourceHandler struct {
 	handler http.Handler
 }
 
+type requestIDHandler struct {
+	handler http.Handler
+}
+
+// response headers carrying request and host id
+const (
+	requestIDHeader = "x-amz-request-id"
+	hostIDHeader    = "x-amz-id-2"
+)
+
+// hostID identifies this server in every response, derived from its hostname
+var hostID = generateHostID()
+
+// generate a base64 encoded host id from hostname
+func generateHostID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	sum := sha256.Sum256([]byte(hostname))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// generate a unique request id, 16 uppercase hex characters like S3
+func generateRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		// fall back to current time, still unique enough to correlate logs
+		return strings.ToUpper(strconv.FormatInt(time.Now().UnixNano(), 16))
+	}
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// getRequestID - request id assigned by requestIDHandler
+func getRequestID(w http.ResponseWriter) string {
+	return w.Header().Get(requestIDHeader)
+}
+
+// getHostID - host id set by requestIDHandler
+func getHostID(w http.ResponseWriter) string {
+	return w.Header().Get(hostIDHeader)
+}
+
+// getRequestIDParams - request id as iodine error data, for correlating logs with responses
+func getRequestIDParams(w http.ResponseWriter) map[string]string {
+	return map[string]string{
+		"requestID": getRequestID(w),
+	}
+}
+
 // strip AccessKey from authorization header
 func stripAccessKey(r *http.Request) string {
 	fields := strings.Fields(r.Header.Get("Authorization"))
@@ -148,6 +204,20 @@ func (h validateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	*/
 }
 
+// Request ID handler is wrapper handler used to tag every request with a unique ID,
+// returned back to the client through 'x-amz-request-id', 'x-amz-id-2' headers and
+// error responses, it is logged along with any error encountered while serving the request
+func setRequestIDHandler(h http.Handler) http.Handler {
+	return requestIDHandler{h}
+}
+
+// Request ID handler ServeHTTP() wrapper
+func (h requestIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(requestIDHeader, generateRequestID())
+	w.Header().Set(hostIDHeader, hostID)
+	h.handler.ServeHTTP(w, r)
+}
+
 // Ignore resources handler is wrapper handler used for API request resource validation
 // Since we do not support all the S3 queries, it is necessary for us to throw back a
 // valid error message indicating such a feature to have been not implemented.
@@ -164,7 +234,7 @@ func (h resourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if ignoreUnImplementedObjectResources(r) || ignoreUnImplementedBucketResources(r) {
 		error := getErrorCode(NotImplemented)
-		errorResponse := getErrorResponse(error, "")
+		errorResponse := getErrorResponse(error, "", getRequestID(w), getHostID(w))
 		setCommonHeaders(w, getContentTypeString(acceptsContentType))
 		w.WriteHeader(error.HTTPStatusCode)
 		w.Write(encodeErrorResponse(errorResponse, acceptsContentType))
@@ -30,13 +30,19 @@ import (
 // This implementation of the GET operation retrieves object. To use GET,
 // you must have READ access to the object.
 func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Request) {
+	if isRequestACL(req.URL.Query()) {
+		server.getObjectACLHandler(w, req)
+		return
+	}
+
 	acceptsContentType := getContentType(req)
 	if acceptsContentType == unknownContentType {
 		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
 		return
 	}
 
-	if !server.isValidOp(w, req, acceptsContentType) {
+	bucketPolicy, ok := server.getBucketACLPolicy(w, req, acceptsContentType)
+	if !ok {
 		return
 	}
 
@@ -49,6 +55,10 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 	switch err := iodine.ToError(err).(type) {
 	case nil: // success
 		{
+			if !isObjectReadAllowed(req, bucketPolicy, metadata) {
+				writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+				return
+			}
 			httpRange, err := getRequestedRange(req, metadata.Size)
 			if err != nil {
 				writeErrorResponse(w, req, InvalidRange, acceptsContentType, req.URL.Path)
@@ -59,7 +69,7 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 				setObjectHeaders(w, metadata)
 				if _, err := server.driver.GetObject(w, bucket, object); err != nil {
 					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(err)
+					log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 				}
 			case false:
 				metadata.Size = httpRange.length
@@ -67,7 +77,7 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 				w.WriteHeader(http.StatusPartialContent)
 				if _, err := server.driver.GetPartialObject(w, bucket, object, httpRange.start, httpRange.length); err != nil {
 					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(iodine.New(err, nil))
+					log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 				}
 			}
 		}
@@ -81,7 +91,7 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -97,7 +107,8 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	if !server.isValidOp(w, req, acceptsContentType) {
+	bucketPolicy, ok := server.getBucketACLPolicy(w, req, acceptsContentType)
+	if !ok {
 		return
 	}
 
@@ -110,6 +121,10 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 	switch err := iodine.ToError(err).(type) {
 	case nil:
 		{
+			if !isObjectReadAllowed(req, bucketPolicy, metadata) {
+				writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+				return
+			}
 			setObjectHeaders(w, metadata)
 			w.WriteHeader(http.StatusOK)
 		}
@@ -123,7 +138,7 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -133,14 +148,19 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 // ----------
 // This implementation of the PUT operation adds an object to a bucket.
 func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Request) {
+	if isRequestACL(req.URL.Query()) {
+		server.putObjectACLHandler(w, req)
+		return
+	}
+
 	acceptsContentType := getContentType(req)
 	if acceptsContentType == unknownContentType {
 		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
 		return
 	}
 
-	// handle PublicRead ACL here
-	if !server.isValidOp(w, req, acceptsContentType) {
+	// verify if bucket allows this operation
+	if !server.isValidOp(w, req, acceptsContentType, drivers.PermissionWrite) {
 		return
 	}
 
@@ -155,16 +175,33 @@ func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Reques
 		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 		return
 	}
-	err := server.driver.CreateObject(bucket, object, "", md5, req.Body)
+	storageClass := req.Header.Get("x-amz-storage-class")
+	if !drivers.IsValidStorageClass(storageClass) {
+		writeErrorResponse(w, req, InvalidStorageClass, acceptsContentType, req.URL.Path)
+		return
+	}
+	// acl requested along with the object, validate before consuming the body, written
+	// together with the object
+	var policy *drivers.AccessControlPolicy
+	if isRequestGrantHeaders(req) || req.Header.Get("x-amz-acl") != "" {
+		requestPolicy, err := getACLPolicyFromRequest(req)
+		switch err {
+		case nil:
+		case errUnsupportedACL:
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		default:
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+		policy = &requestPolicy
+	}
+	err := server.driver.CreateObject(bucket, object, "", storageClass, md5, req.ContentLength, req.Body, policy)
 	switch err := iodine.ToError(err).(type) {
 	case nil:
 		w.Header().Set("Server", "Minio")
 		w.Header().Set("Connection", "close")
 		w.WriteHeader(http.StatusOK)
-	case drivers.ObjectExists:
-		{
-			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
-		}
 	case drivers.BadDigest:
 		{
 			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
@@ -177,14 +214,154 @@ func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Reques
 		{
 			writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 		}
+	case drivers.InvalidStorageClass:
+		{
+			writeErrorResponse(w, req, InvalidStorageClass, acceptsContentType, req.URL.Path)
+		}
+	case drivers.StorageFull:
+		{
+			writeErrorResponse(w, req, StorageFull, acceptsContentType, req.URL.Path)
+		}
 	case drivers.ImplementationError:
 		{
-			log.Error.Println(err)
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// isObjectReadAllowed - object is readable if either its own acl or its bucket acl grants read
+func isObjectReadAllowed(req *http.Request, bucketPolicy drivers.AccessControlPolicy, metadata drivers.ObjectMetadata) bool {
+	requester := getRequester(req)
+	if bucketPolicy.IsAllowed(requester, drivers.PermissionRead) {
+		return true
+	}
+	return getACLPolicy(metadata.AccessControlPolicy, drivers.BucketPrivate).IsAllowed(requester, drivers.PermissionRead)
+}
+
+// GET Object ACL
+// ----------
+// This implementation of the GET operation returns the access control policy of an object
+func (server *minioAPI) getObjectACLHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if acceptsContentType == unknownContentType {
+		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	if _, ok := server.getBucketACLPolicy(w, req, acceptsContentType); !ok {
+		return
+	}
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	metadata, err := server.driver.GetObjectMetadata(bucket, object, "")
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			policy := getACLPolicy(metadata.AccessControlPolicy, drivers.BucketPrivate)
+			if !policy.IsAllowed(getRequester(req), drivers.PermissionReadACP) {
+				writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+				return
+			}
+			response := generateAccessControlPolicyResponse(policy)
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType))
+			w.WriteHeader(http.StatusOK)
+			// write body
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
+	}
+}
+
+// PUT Object ACL
+// ----------
+// This implementation of the PUT operation modifies the access control policy of an object,
+// acl is read from 'x-amz-grant-*' headers, 'x-amz-acl' header or AccessControlPolicy body
+func (server *minioAPI) putObjectACLHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if acceptsContentType == unknownContentType {
+		writeErrorResponse(w, req, NotAcceptable, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	if _, ok := server.getBucketACLPolicy(w, req, acceptsContentType); !ok {
+		return
+	}
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	metadata, err := server.driver.GetObjectMetadata(bucket, object, "")
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+	case drivers.ObjectNotFound, drivers.ObjectNameInvalid:
+		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		return
+	default:
+		log.Error.Println(iodine.New(err, getRequestIDParams(w)))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !getACLPolicy(metadata.AccessControlPolicy, drivers.BucketPrivate).IsAllowed(getRequester(req), drivers.PermissionWriteACP) {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	policy, err := getACLPolicyFromRequest(req)
+	switch err {
+	case nil:
+	case errUnsupportedACL:
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	default:
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	err = server.driver.SetObjectACL(bucket, object, policy)
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			w.Header().Set("Server", "Minio")
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusOK)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
-			log.Error.Println(err)
+			log.Error.Println(iodine.New(err, getRequestIDParams(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
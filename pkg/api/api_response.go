@@ -89,7 +89,7 @@ func generateObjectsListResult(bucket string, objects []drivers.ObjectMetadata,
 		content.ETag = object.Md5
 		content.Size = object.Size
 		content.StorageClass = "STANDARD"
-		content.Owner = owner
+		content.Owner = &owner
 		contents = append(contents, content)
 	}
 	sort.Sort(itemKey(contents))
@@ -99,6 +99,7 @@ func generateObjectsListResult(bucket string, objects []drivers.ObjectMetadata,
 	data.Prefix = bucketResources.Prefix
 	data.Delimiter = bucketResources.Delimiter
 	data.Marker = bucketResources.Marker
+	data.NextMarker = bucketResources.NextMarker
 	data.IsTruncated = bucketResources.IsTruncated
 	for _, prefix := range bucketResources.CommonPrefixes {
 		var prefixItem = &Prefix{}
@@ -109,9 +110,87 @@ func generateObjectsListResult(bucket string, objects []drivers.ObjectMetadata,
 	return data
 }
 
+// takes a set of objects and prepares the objects for serialization as a version 2 list
+// input:
+// bucket name
+// array of object metadata
+// bucket resources returned by the driver
+// list objects version 2 resources
+//
+// output:
+// populated struct that can be serialized to match xml and json api spec output
+func generateObjectsListV2Result(bucket string, objects []drivers.ObjectMetadata, bucketResources drivers.BucketResourcesMetadata, listV2Resources listObjectsV2Resources) ObjectListV2Response {
+	var contents []*Item
+	var prefixes []*Prefix
+	var data = ObjectListV2Response{}
+
+	encode := func(key string) string { return key }
+	if listV2Resources.EncodingType == "url" {
+		encode = s3URLEncode
+	}
+
+	for _, object := range objects {
+		var content = &Item{}
+		if object.Key == "" {
+			continue
+		}
+		content.Key = encode(object.Key)
+		content.LastModified = object.Created.Format(iso8601Format)
+		content.ETag = object.Md5
+		content.Size = object.Size
+		content.StorageClass = "STANDARD"
+		if listV2Resources.FetchOwner {
+			content.Owner = &Owner{ID: "minio", DisplayName: "minio"}
+		}
+		contents = append(contents, content)
+	}
+	sort.Sort(itemKey(contents))
+	for _, prefix := range bucketResources.CommonPrefixes {
+		var prefixItem = &Prefix{}
+		prefixItem.Prefix = encode(prefix)
+		prefixes = append(prefixes, prefixItem)
+	}
+	data.Name = bucket
+	data.Contents = contents
+	data.CommonPrefixes = prefixes
+	data.KeyCount = len(contents) + len(prefixes)
+	data.MaxKeys = bucketResources.Maxkeys
+	data.Prefix = encode(bucketResources.Prefix)
+	data.Delimiter = encode(bucketResources.Delimiter)
+	data.StartAfter = encode(listV2Resources.StartAfter)
+	data.EncodingType = listV2Resources.EncodingType
+	data.ContinuationToken = listV2Resources.ContinuationToken
+	data.IsTruncated = bucketResources.IsTruncated
+	if bucketResources.IsTruncated {
+		data.NextContinuationToken = getContinuationToken(bucketResources.NextMarker)
+	}
+	return data
+}
+
+// takes an access control policy and prepares it for serialization
+func generateAccessControlPolicyResponse(policy drivers.AccessControlPolicy) AccessControlPolicy {
+	var data = AccessControlPolicy{}
+	data.Owner.ID = policy.Owner.ID
+	data.Owner.DisplayName = policy.Owner.DisplayName
+	for _, grant := range policy.Grants {
+		var responseGrant = &Grant{}
+		responseGrant.Permission = string(grant.Permission)
+		if grant.Grantee.IsGroup() {
+			responseGrant.Grantee.Type = groupGrantee
+			responseGrant.Grantee.URI = grant.Grantee.URI
+		} else {
+			responseGrant.Grantee.Type = canonicalUserGrantee
+			responseGrant.Grantee.ID = grant.Grantee.ID
+			responseGrant.Grantee.DisplayName = grant.Grantee.DisplayName
+		}
+		data.AccessControlList.Grant = append(data.AccessControlList.Grant, responseGrant)
+	}
+	return data
+}
+
 func writeErrorResponse(w http.ResponseWriter, req *http.Request, errorType int, acceptsContentType contentType, resource string) {
 	error := getErrorCode(errorType)
-	errorResponse := getErrorResponse(error, resource)
+	errorResponse := getErrorResponse(error, resource, getRequestID(w), getHostID(w))
 	// set headers
 	setCommonHeaders(w, getContentTypeString(acceptsContentType))
 	w.WriteHeader(error.HTTPStatusCode)
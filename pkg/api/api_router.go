@@ -100,5 +100,6 @@ func HTTPHandler(domain string, driver drivers.Driver) http.Handler {
 	h = quota.RequestLimit(h, 100, time.Duration(30*time.Minute))
 	h = quota.RequestLimit(h, 1000, time.Duration(24*time.Hour))
 	h = quota.ConnectionLimit(h, 5)
+	h = setRequestIDHandler(h)
 	return h
 }
@@ -32,9 +32,11 @@ import (
 	"time"
 
 	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 
 	"github.com/minio-io/minio/pkg/storage/drivers"
 	"github.com/minio-io/minio/pkg/storage/drivers/donut"
@@ -166,8 +168,8 @@ func (s *MySuite) TestEmptyObject(c *C) {
 		Md5:         "d41d8cd98f00b204e9800998ecf8427e",
 		Size:        0,
 	}
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
-	typedDriver.On("CreateObject", "bucket", "object", "", "", mock.Anything).Return(nil).Once()
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	typedDriver.On("CreateObject", "bucket", "object", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 	typedDriver.On("GetBucketMetadata", "bucket").Return(drivers.BucketMetadata{}, nil).Twice()
 	typedDriver.On("GetObjectMetadata", "bucket", "object", "").Return(metadata, nil).Once()
 	typedDriver.On("GetObject", mock.Anything, "bucket", "object").Return(int64(0), nil).Once()
@@ -177,8 +179,8 @@ func (s *MySuite) TestEmptyObject(c *C) {
 	defer testServer.Close()
 
 	buffer := bytes.NewBufferString("")
-	driver.CreateBucket("bucket", "private")
-	driver.CreateObject("bucket", "object", "", "", buffer)
+	driver.CreateBucket("bucket", "private", "")
+	driver.CreateObject("bucket", "object", "", "", "", int64(buffer.Len()), buffer, nil)
 
 	request, err := http.NewRequest("GET", testServer.URL+"/bucket/object", nil)
 	c.Assert(err, IsNil)
@@ -212,14 +214,14 @@ func (s *MySuite) TestBucket(c *C) {
 		Created: time.Now(),
 		ACL:     drivers.BucketACL("private"),
 	}
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
 	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
 
 	httpHandler := HTTPHandler("", driver)
 	testServer := httptest.NewServer(httpHandler)
 	defer testServer.Close()
 
-	driver.CreateBucket("bucket", "private")
+	driver.CreateBucket("bucket", "private", "")
 
 	request, err := http.NewRequest("HEAD", testServer.URL+"/bucket", nil)
 	c.Assert(err, IsNil)
@@ -248,8 +250,8 @@ func (s *MySuite) TestObject(c *C) {
 		Md5:         "5eb63bbbe01eeed093cb22bb8f5acdc3",
 		Size:        11,
 	}
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
-	typedDriver.On("CreateObject", "bucket", "object", "", "", mock.Anything).Return(nil).Once()
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	typedDriver.On("CreateObject", "bucket", "object", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 	typedDriver.On("GetBucketMetadata", "bucket").Return(drivers.BucketMetadata{}, nil).Twice()
 	typedDriver.On("GetObjectMetadata", "bucket", "object", "").Return(metadata, nil).Twice()
 	typedDriver.SetGetObjectWriter("bucket", "object", []byte("hello world"))
@@ -260,8 +262,8 @@ func (s *MySuite) TestObject(c *C) {
 	defer testServer.Close()
 
 	buffer := bytes.NewBufferString("hello world")
-	driver.CreateBucket("bucket", "private")
-	driver.CreateObject("bucket", "object", "", "", buffer)
+	driver.CreateBucket("bucket", "private", "")
+	driver.CreateObject("bucket", "object", "", "", "", int64(buffer.Len()), buffer, nil)
 
 	request, err := http.NewRequest("GET", testServer.URL+"/bucket/object", nil)
 	c.Assert(err, IsNil)
@@ -322,14 +324,14 @@ func (s *MySuite) TestMultipleObjects(c *C) {
 	buffer2 := bytes.NewBufferString("hello two")
 	buffer3 := bytes.NewBufferString("hello three")
 
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
-	driver.CreateBucket("bucket", "private")
-	typedDriver.On("CreateObject", "bucket", "object1", "", "", mock.Anything).Return(nil).Once()
-	driver.CreateObject("bucket", "object1", "", "", buffer1)
-	typedDriver.On("CreateObject", "bucket", "object2", "", "", mock.Anything).Return(nil).Once()
-	driver.CreateObject("bucket", "object2", "", "", buffer2)
-	typedDriver.On("CreateObject", "bucket", "object3", "", "", mock.Anything).Return(nil).Once()
-	driver.CreateObject("bucket", "object3", "", "", buffer3)
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	driver.CreateBucket("bucket", "private", "")
+	typedDriver.On("CreateObject", "bucket", "object1", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	driver.CreateObject("bucket", "object1", "", "", "", int64(buffer1.Len()), buffer1, nil)
+	typedDriver.On("CreateObject", "bucket", "object2", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	driver.CreateObject("bucket", "object2", "", "", "", int64(buffer2.Len()), buffer2, nil)
+	typedDriver.On("CreateObject", "bucket", "object3", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	driver.CreateObject("bucket", "object3", "", "", "", int64(buffer3.Len()), buffer3, nil)
 
 	// test non-existant object
 	typedDriver.On("GetBucketMetadata", "bucket").Return(drivers.BucketMetadata{}, nil).Once()
@@ -473,8 +475,8 @@ func (s *MySuite) TestHeader(c *C) {
 	testServer := httptest.NewServer(httpHandler)
 	defer testServer.Close()
 
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
-	err := driver.CreateBucket("bucket", "private")
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	err := driver.CreateBucket("bucket", "private", "")
 	c.Assert(err, IsNil)
 
 	bucketMetadata := drivers.BucketMetadata{
@@ -496,8 +498,8 @@ func (s *MySuite) TestHeader(c *C) {
 
 	buffer := bytes.NewBufferString("hello world")
 	typedDriver.On("GetBucketMetadata", "foo").Return(bucketMetadata, nil).Once()
-	typedDriver.On("CreateObject", "bucket", "object", "", "", mock.Anything).Return(nil).Once()
-	driver.CreateObject("bucket", "object", "", "", buffer)
+	typedDriver.On("CreateObject", "bucket", "object", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	driver.CreateObject("bucket", "object", "", "", "", int64(buffer.Len()), buffer, nil)
 
 	objectMetadata := drivers.ObjectMetadata{
 		Bucket:      "bucket",
@@ -547,7 +549,7 @@ func (s *MySuite) TestPutBucket(c *C) {
 	c.Assert(len(buckets), Equals, 0)
 	c.Assert(err, IsNil)
 
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
 	request, err := http.NewRequest("PUT", testServer.URL+"/bucket", bytes.NewBufferString(""))
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "private")
@@ -597,7 +599,7 @@ func (s *MySuite) TestPutObject(c *C) {
 	date1 := time.Now().Add(-time.Second)
 
 	// Put Bucket before - Put Object into a bucket
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
 	request, err := http.NewRequest("PUT", testServer.URL+"/bucket", nil)
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "private")
@@ -608,7 +610,7 @@ func (s *MySuite) TestPutObject(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(response.StatusCode, Equals, http.StatusOK)
 
-	typedDriver.On("CreateObject", "bucket", "two", "", "", mock.Anything).Return(nil).Once()
+	typedDriver.On("CreateObject", "bucket", "two", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 	request, err = http.NewRequest("PUT", testServer.URL+"/bucket/two", bytes.NewBufferString("hello world"))
 	c.Assert(err, IsNil)
 	setAuthHeader(request)
@@ -682,8 +684,8 @@ func (s *MySuite) TestListBuckets(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(len(listResponse.Buckets.Bucket), Equals, 0)
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(nil).Once()
-	err = driver.CreateBucket("foo", "private")
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(nil).Once()
+	err = driver.CreateBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	bucketMetadata := []drivers.BucketMetadata{
@@ -704,8 +706,8 @@ func (s *MySuite) TestListBuckets(c *C) {
 	c.Assert(len(listResponse.Buckets.Bucket), Equals, 1)
 	c.Assert(listResponse.Buckets.Bucket[0].Name, Equals, "foo")
 
-	typedDriver.On("CreateBucket", "bar", "private").Return(nil).Once()
-	err = driver.CreateBucket("bar", "private")
+	typedDriver.On("CreateBucket", "bar", "private", "").Return(nil).Once()
+	err = driver.CreateBucket("bar", "private", "")
 	c.Assert(err, IsNil)
 
 	bucketMetadata = []drivers.BucketMetadata{
@@ -806,8 +808,8 @@ func (s *MySuite) TestXMLNameNotInBucketListJson(c *C) {
 	testServer := httptest.NewServer(httpHandler)
 	defer testServer.Close()
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(nil).Once()
-	err := driver.CreateBucket("foo", "private")
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(nil).Once()
+	err := driver.CreateBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	typedDriver.On("ListBuckets").Return([]drivers.BucketMetadata{{Name: "foo", Created: time.Now()}}, nil)
@@ -839,8 +841,8 @@ func (s *MySuite) TestXMLNameNotInObjectListJson(c *C) {
 	testServer := httptest.NewServer(httpHandler)
 	defer testServer.Close()
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(nil).Once()
-	err := driver.CreateBucket("foo", "private")
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(nil).Once()
+	err := driver.CreateBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	resources := drivers.BucketResourcesMetadata{}
@@ -884,8 +886,8 @@ func (s *MySuite) TestContentTypePersists(c *C) {
 	testServer := httptest.NewServer(httpHandler)
 	defer testServer.Close()
 
-	typedDriver.On("CreateBucket", "bucket", "private").Return(nil).Once()
-	err := driver.CreateBucket("bucket", "private")
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	err := driver.CreateBucket("bucket", "private", "")
 	c.Assert(err, IsNil)
 
 	metadata := drivers.BucketMetadata{
@@ -894,7 +896,7 @@ func (s *MySuite) TestContentTypePersists(c *C) {
 		ACL:     drivers.BucketACL("private"),
 	}
 	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
-	typedDriver.On("CreateObject", "bucket", "one", "", "", mock.Anything).Return(nil).Once()
+	typedDriver.On("CreateObject", "bucket", "one", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 	request, err := http.NewRequest("PUT", testServer.URL+"/bucket/one", bytes.NewBufferString("hello world"))
 	delete(request.Header, "Content-Type")
 	c.Assert(err, IsNil)
@@ -940,7 +942,7 @@ func (s *MySuite) TestContentTypePersists(c *C) {
 	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
 
 	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
-	typedDriver.On("CreateObject", "bucket", "two", "", "", mock.Anything).Return(nil).Once()
+	typedDriver.On("CreateObject", "bucket", "two", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 	request, err = http.NewRequest("PUT", testServer.URL+"/bucket/two", bytes.NewBufferString("hello world"))
 	delete(request.Header, "Content-Type")
 	request.Header.Add("Content-Type", "application/json")
@@ -1006,12 +1008,12 @@ func (s *MySuite) TestPartialContent(c *C) {
 		Size:        11,
 	}
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(nil).Once()
-	typedDriver.On("CreateObject", "foo", "bar", "", "", mock.Anything).Return(nil).Once()
-	err := driver.CreateBucket("foo", "private")
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(nil).Once()
+	typedDriver.On("CreateObject", "foo", "bar", "", "", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	err := driver.CreateBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
-	driver.CreateObject("foo", "bar", "", "", bytes.NewBufferString("hello world"))
+	driver.CreateObject("foo", "bar", "", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"), nil)
 
 	// prepare for GET on range request
 	typedDriver.SetGetObjectWriter("foo", "bar", []byte("hello world"))
@@ -1104,6 +1106,494 @@ func (s *MySuite) TestListObjectsHandlerErrors(c *C) {
 	verifyError(c, response, "InternalError", "We encountered an internal error, please try again.", http.StatusInternalServerError)
 }
 
+func (s *MySuite) TestListObjectsPaging(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	default:
+		{
+			return
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+	client := http.Client{}
+
+	resources := drivers.BucketResourcesMetadata{
+		Marker:    "a",
+		Maxkeys:   1,
+		Delimiter: "/",
+	}
+	objects := []drivers.ObjectMetadata{{Bucket: "foo", Key: "b", Created: time.Now()}}
+	returnedResources := resources
+	returnedResources.IsTruncated = true
+	returnedResources.NextMarker = "b"
+
+	typedDriver.On("GetBucketMetadata", "foo").Return(drivers.BucketMetadata{}, nil).Once()
+	typedDriver.On("ListObjects", "foo", resources).Return(objects, returnedResources, nil).Once()
+	request, err := http.NewRequest("GET", testServer.URL+"/foo?marker=a&max-keys=1&delimiter=/", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	listResponse := ObjectListResponse{}
+	err = xml.NewDecoder(response.Body).Decode(&listResponse)
+	c.Assert(err, IsNil)
+	c.Assert(listResponse.Marker, Equals, "a")
+	c.Assert(listResponse.NextMarker, Equals, "b")
+	c.Assert(listResponse.IsTruncated, Equals, true)
+	c.Assert(len(listResponse.Contents), Equals, 1)
+	c.Assert(listResponse.Contents[0].Key, Equals, "b")
+}
+
+func (s *MySuite) TestListObjectsV2(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	default:
+		{
+			return
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+	client := http.Client{}
+
+	resources := drivers.BucketResourcesMetadata{
+		Marker:  "a",
+		Maxkeys: 1,
+	}
+	objects := []drivers.ObjectMetadata{{Bucket: "foo", Key: "b c/\x01", Created: time.Now()}}
+	returnedResources := resources
+	returnedResources.IsTruncated = true
+	returnedResources.NextMarker = "b c/\x01"
+
+	typedDriver.On("GetBucketMetadata", "foo").Return(drivers.BucketMetadata{}, nil).Once()
+	typedDriver.On("ListObjects", "foo", resources).Return(objects, returnedResources, nil).Once()
+	token := getContinuationToken("a")
+	request, err := http.NewRequest("GET", testServer.URL+"/foo?list-type=2&max-keys=1&encoding-type=url&start-after=z&continuation-token="+url.QueryEscape(token), nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	listResponse := ObjectListV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&listResponse)
+	c.Assert(err, IsNil)
+	c.Assert(listResponse.ContinuationToken, Equals, token)
+	c.Assert(listResponse.StartAfter, Equals, "z")
+	c.Assert(listResponse.EncodingType, Equals, "url")
+	c.Assert(listResponse.IsTruncated, Equals, true)
+	c.Assert(listResponse.KeyCount, Equals, 1)
+	c.Assert(len(listResponse.Contents), Equals, 1)
+	c.Assert(listResponse.Contents[0].Key, Equals, "b+c/%01")
+	c.Assert(listResponse.Contents[0].Owner, IsNil)
+
+	marker, err := getMarkerFromContinuationToken(listResponse.NextContinuationToken)
+	c.Assert(err, IsNil)
+	c.Assert(marker, Equals, "b c/\x01")
+
+	// fetch-owner with start-after and no continuation token
+	resources.Marker = "z"
+	returnedResources = resources
+	typedDriver.On("GetBucketMetadata", "foo").Return(drivers.BucketMetadata{}, nil).Once()
+	typedDriver.On("ListObjects", "foo", resources).Return(objects[:0], returnedResources, nil).Once()
+	request, err = http.NewRequest("GET", testServer.URL+"/foo?list-type=2&max-keys=1&start-after=z&fetch-owner=true", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	listResponse = ObjectListV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&listResponse)
+	c.Assert(err, IsNil)
+	c.Assert(listResponse.IsTruncated, Equals, false)
+	c.Assert(listResponse.KeyCount, Equals, 0)
+	c.Assert(listResponse.NextContinuationToken, Equals, "")
+
+	// invalid continuation token
+	typedDriver.On("GetBucketMetadata", "foo").Return(drivers.BucketMetadata{}, nil).Once()
+	request, err = http.NewRequest("GET", testServer.URL+"/foo?list-type=2&continuation-token=!!", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidArgument", "Invalid Argument", http.StatusBadRequest)
+}
+
+func (s *MySuite) TestPutObjectStorageClass(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+
+	typedDriver.On("CreateBucket", "bucket", "private", "").Return(nil).Once()
+	err := driver.CreateBucket("bucket", "private", "")
+	c.Assert(err, IsNil)
+
+	metadata := drivers.BucketMetadata{
+		Name:    "bucket",
+		Created: time.Now(),
+		ACL:     drivers.BucketACL("private"),
+	}
+	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
+	typedDriver.On("CreateObject", "bucket", "one", "", "REDUCED_REDUNDANCY", "", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	request, err := http.NewRequest("PUT", testServer.URL+"/bucket/one", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-storage-class", "REDUCED_REDUNDANCY")
+	setAuthHeader(request)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
+	request, err = http.NewRequest("PUT", testServer.URL+"/bucket/two", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-storage-class", "GLACIER")
+	setAuthHeader(request)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidStorageClass", "The storage class you specified is not valid.", http.StatusBadRequest)
+}
+
+func (s *MySuite) TestPutBucketStorageClass(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+
+	typedDriver.On("CreateBucket", "bucket", "private", "REDUCED_REDUNDANCY").Return(nil).Once()
+	request, err := http.NewRequest("PUT", testServer.URL+"/bucket", bytes.NewBufferString(""))
+	c.Assert(err, IsNil)
+	request.Header.Add("x-amz-acl", "private")
+	request.Header.Set("x-amz-storage-class", "REDUCED_REDUNDANCY")
+	setAuthHeader(request)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	metadata := drivers.BucketMetadata{
+		Name:         "bucket",
+		Created:      time.Now(),
+		ACL:          drivers.BucketACL("private"),
+		StorageClass: "REDUCED_REDUNDANCY",
+	}
+	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
+	bucketMetadata, err := driver.GetBucketMetadata("bucket")
+	c.Assert(err, IsNil)
+	c.Assert(bucketMetadata.StorageClass, Equals, "REDUCED_REDUNDANCY")
+
+	request, err = http.NewRequest("PUT", testServer.URL+"/glacier", bytes.NewBufferString(""))
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-storage-class", "GLACIER")
+	setAuthHeader(request)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidStorageClass", "The storage class you specified is not valid.", http.StatusBadRequest)
+}
+
+func (s *MySuite) TestPutObjectStorageFull(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	default:
+		{
+			return
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+
+	metadata := drivers.BucketMetadata{
+		Name:    "bucket",
+		Created: time.Now(),
+		ACL:     drivers.BucketACL("private"),
+	}
+	typedDriver.On("GetBucketMetadata", "bucket").Return(metadata, nil).Once()
+	// content length is handed to the driver to verify free space before writing
+	typedDriver.On("CreateObject", "bucket", "one", "", "", "", int64(11), mock.Anything, mock.Anything).Return(drivers.StorageFull{Bucket: "bucket", Object: "one"}).Once()
+	request, err := http.NewRequest("PUT", testServer.URL+"/bucket/one", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "XMinioStorageFull", "Storage backend has reached its minimum free disk threshold, please delete a few objects to proceed.", http.StatusInsufficientStorage)
+}
+
+func setAnonymousHeader(req *http.Request) {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+}
+
+func (s *MySuite) TestBucketAndObjectACL(c *C) {
+	switch s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			return
+		}
+	}
+	driver := s.Driver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+	client := http.Client{}
+
+	c.Assert(driver.CreateBucket("acl-bucket", "private", ""), IsNil)
+	c.Assert(driver.CreateObject("acl-bucket", "public", "", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"), nil), IsNil)
+	c.Assert(driver.CreateObject("acl-bucket", "private", "", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"), nil), IsNil)
+
+	// anonymous requests are denied on private buckets and objects
+	request, err := http.NewRequest("GET", testServer.URL+"/acl-bucket", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/public", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	// grant read on a single object through AccessControlPolicy body
+	acl := `<AccessControlPolicy>
+	<Owner><ID>minio</ID><DisplayName>minio</DisplayName></Owner>
+	<AccessControlList>
+		<Grant>
+			<Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>minio</ID></Grantee>
+			<Permission>FULL_CONTROL</Permission>
+		</Grant>
+		<Grant>
+			<Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee>
+			<Permission>READ</Permission>
+		</Grant>
+	</AccessControlList>
+</AccessControlPolicy>`
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket/public?acl", bytes.NewBufferString(acl))
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/public?acl", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	policy := AccessControlPolicy{}
+	err = xml.NewDecoder(response.Body).Decode(&policy)
+	c.Assert(err, IsNil)
+	c.Assert(policy.Owner.ID, Equals, "minio")
+	c.Assert(len(policy.AccessControlList.Grant), Equals, 2)
+	c.Assert(policy.AccessControlList.Grant[1].Grantee.Type, Equals, "Group")
+	c.Assert(policy.AccessControlList.Grant[1].Grantee.URI, Equals, "http://acs.amazonaws.com/groups/global/AllUsers")
+	c.Assert(policy.AccessControlList.Grant[1].Permission, Equals, "READ")
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/public", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	responseBody, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(responseBody), Equals, "hello world")
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/private", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	// acl given along with a new object is written with it
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket/created-public", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-acl", "public-read")
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/created-public", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// grant read on the bucket through 'x-amz-grant-*' headers
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket?acl", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-grant-read", `uri="http://acs.amazonaws.com/groups/global/AllUsers"`)
+	request.Header.Set("x-amz-grant-full-control", `id="minio"`)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket?acl", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	policy = AccessControlPolicy{}
+	err = xml.NewDecoder(response.Body).Decode(&policy)
+	c.Assert(err, IsNil)
+	c.Assert(len(policy.AccessControlList.Grant), Equals, 2)
+	c.Assert(policy.AccessControlList.Grant[0].Grantee.ID, Equals, "minio")
+	c.Assert(policy.AccessControlList.Grant[0].Permission, Equals, "FULL_CONTROL")
+
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// bucket read now covers every object in it
+	request, err = http.NewRequest("GET", testServer.URL+"/acl-bucket/private", nil)
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// anonymous writes are still denied
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket/anonymous", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	setAnonymousHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	// malformed and unsupported acls
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket?acl", bytes.NewBufferString("<AccessControlPolicy><AccessControlList><Grant><Permission>EVERYTHING</Permission></Grant></AccessControlList></AccessControlPolicy>"))
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest)
+
+	request, err = http.NewRequest("PUT", testServer.URL+"/acl-bucket?acl", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("x-amz-grant-read", `emailAddress="user@example.com"`)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "NotImplemented", "A header you provided implies functionality that is not implemented.", http.StatusNotImplemented)
+}
+
+func (s *MySuite) TestRequestID(c *C) {
+	switch driver := s.Driver.(type) {
+	case *mocks.Driver:
+		{
+			driver.AssertExpectations(c)
+		}
+	default:
+		{
+			return
+		}
+	}
+	driver := s.Driver
+	typedDriver := s.MockDriver
+
+	httpHandler := HTTPHandler("", driver)
+	testServer := httptest.NewServer(httpHandler)
+	defer testServer.Close()
+	client := http.Client{}
+
+	typedDriver.On("ListBuckets").Return([]drivers.BucketMetadata{}, nil).Twice()
+	request, err := http.NewRequest("GET", testServer.URL+"/", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	requestID := response.Header.Get("x-amz-request-id")
+	c.Assert(len(requestID), Equals, 16)
+	c.Assert(response.Header.Get("x-amz-id-2"), Not(Equals), "")
+
+	request, err = http.NewRequest("GET", testServer.URL+"/", nil)
+	c.Assert(err, IsNil)
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("x-amz-request-id"), Not(Equals), requestID)
+
+	// json error responses carry the request id as well
+	typedDriver.On("GetBucketMetadata", "foo").Return(drivers.BucketMetadata{}, drivers.BucketNotFound{}).Once()
+	request, err = http.NewRequest("GET", testServer.URL+"/foo", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Accept", "application/json")
+	setAuthHeader(request)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+	errorResponse := ErrorResponse{}
+	err = json.NewDecoder(response.Body).Decode(&errorResponse)
+	c.Assert(err, IsNil)
+	c.Assert(errorResponse.Code, Equals, "NoSuchBucket")
+	c.Assert(errorResponse.RequestID, Equals, response.Header.Get("x-amz-request-id"))
+	c.Assert(errorResponse.HostID, Equals, response.Header.Get("x-amz-id-2"))
+}
+
 func (s *MySuite) TestListBucketsErrors(c *C) {
 	switch driver := s.Driver.(type) {
 	case *mocks.Driver:
@@ -1160,7 +1650,7 @@ func (s *MySuite) TestPutBucketErrors(c *C) {
 	defer testServer.Close()
 	client := http.Client{}
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(drivers.BucketNameInvalid{}).Once()
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(drivers.BucketNameInvalid{}).Once()
 	request, err := http.NewRequest("PUT", testServer.URL+"/foo", bytes.NewBufferString(""))
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "private")
@@ -1170,7 +1660,7 @@ func (s *MySuite) TestPutBucketErrors(c *C) {
 	c.Assert(err, IsNil)
 	verifyError(c, response, "InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest)
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(drivers.BucketExists{}).Once()
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(drivers.BucketExists{}).Once()
 	request, err = http.NewRequest("PUT", testServer.URL+"/foo", bytes.NewBufferString(""))
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "private")
@@ -1180,7 +1670,7 @@ func (s *MySuite) TestPutBucketErrors(c *C) {
 	c.Assert(err, IsNil)
 	verifyError(c, response, "BucketAlreadyExists", "The requested bucket name is not available.", http.StatusConflict)
 
-	typedDriver.On("CreateBucket", "foo", "private").Return(drivers.BackendCorrupted{}).Once()
+	typedDriver.On("CreateBucket", "foo", "private", "").Return(drivers.BackendCorrupted{}).Once()
 	request, err = http.NewRequest("PUT", testServer.URL+"/foo", bytes.NewBufferString(""))
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "private")
@@ -1190,7 +1680,7 @@ func (s *MySuite) TestPutBucketErrors(c *C) {
 	c.Assert(err, IsNil)
 	verifyError(c, response, "InternalError", "We encountered an internal error, please try again.", http.StatusInternalServerError)
 
-	typedDriver.On("CreateBucket", "foo", "unknown").Return(nil).Once()
+	typedDriver.On("CreateBucket", "foo", "unknown", "").Return(nil).Once()
 	request, err = http.NewRequest("PUT", testServer.URL+"/foo", bytes.NewBufferString(""))
 	c.Assert(err, IsNil)
 	request.Header.Add("x-amz-acl", "unknown")
@@ -1324,6 +1814,9 @@ func verifyError(c *C, response *http.Response, code, description string, status
 	c.Assert(errorResponse.Code, Equals, code)
 	c.Assert(errorResponse.Message, Equals, description)
 	c.Assert(response.StatusCode, Equals, statusCode)
+	c.Assert(errorResponse.RequestID, Not(Equals), "")
+	c.Assert(errorResponse.RequestID, Equals, response.Header.Get("x-amz-request-id"))
+	c.Assert(errorResponse.HostID, Equals, response.Header.Get("x-amz-id-2"))
 }
 
 func startMockDriver() *mocks.Driver {
@@ -63,11 +63,14 @@ const (
 	SignatureDoesNotMatch
 	TooManyBuckets
 	MethodNotAllowed
+	InvalidArgument
+	InvalidStorageClass
+	StorageFull
 )
 
 // Error codes, non exhaustive list - standard HTTP errors
 const (
-	NotAcceptable = iota + 23
+	NotAcceptable = iota + 26
 )
 
 // Error code to Error structure map
@@ -182,6 +185,21 @@ var errorCodeResponse = map[int]Error{
 		Description:    "The specified method is not allowed against this resource.",
 		HTTPStatusCode: http.StatusMethodNotAllowed,
 	},
+	InvalidArgument: {
+		Code:           "InvalidArgument",
+		Description:    "Invalid Argument",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	InvalidStorageClass: {
+		Code:           "InvalidStorageClass",
+		Description:    "The storage class you specified is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	StorageFull: {
+		Code:           "XMinioStorageFull",
+		Description:    "Storage backend has reached its minimum free disk threshold, please delete a few objects to proceed.",
+		HTTPStatusCode: http.StatusInsufficientStorage,
+	},
 	NotAcceptable: {
 		Code:           "NotAcceptable",
 		Description:    "The requested resource is only capable of generating content not acceptable according to the Accept headers sent in the request.",
@@ -196,16 +214,15 @@ func getErrorCode(code int) Error {
 
 // getErrorResponse gets in standard error and resource value and
 // provides a encodable populated response values
-func getErrorResponse(err Error, resource string) ErrorResponse {
+func getErrorResponse(err Error, resource, requestID, hostID string) ErrorResponse {
 	var data = ErrorResponse{}
 	data.Code = err.Code
 	data.Message = err.Description
 	if resource != "" {
 		data.Resource = resource
 	}
-	// TODO implement this in future
-	data.RequestID = "3L137"
-	data.HostID = "3L137"
+	data.RequestID = requestID
+	data.HostID = hostID
 
 	return data
 }
@@ -56,7 +56,7 @@ const (
 
 func writeErrorResponse(w http.ResponseWriter, req *http.Request, errorType int, resource string) {
 	error := getErrorCode(errorType)
-	errorResponse := getErrorResponse(error, resource)
+	errorResponse := getErrorResponse(error, resource, w.Header().Get("x-amz-request-id"), w.Header().Get("x-amz-id-2"))
 	// set headers
 	writeErrorHeaders(w)
 	w.WriteHeader(error.HTTPStatusCode)
@@ -106,16 +106,15 @@ func getErrorCode(code int) Error {
 
 // getErrorResponse gets in standard error and resource value and
 // provides a encodable populated response values
-func getErrorResponse(err Error, resource string) ErrorResponse {
+func getErrorResponse(err Error, resource, requestID, hostID string) ErrorResponse {
 	var data = ErrorResponse{}
 	data.Code = err.Code
 	data.Message = err.Description
 	if resource != "" {
 		data.Resource = resource
 	}
-	// TODO implement this in future
-	data.RequestID = "3L137"
-	data.HostID = "3L137"
+	data.RequestID = requestID
+	data.HostID = hostID
 
 	return data
 }
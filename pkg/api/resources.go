@@ -17,8 +17,11 @@
 package api
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/minio-io/minio/pkg/storage/drivers"
 )
@@ -40,15 +43,63 @@ func getBucketResources(values url.Values) (v drivers.BucketResourcesMetadata) {
 	return
 }
 
-// check if req query values have acl
-func isRequestBucketACL(values url.Values) bool {
-	for key := range values {
+// listObjectsV2Resources - query parameters specific to ListObjects version 2
+type listObjectsV2Resources struct {
+	ContinuationToken string
+	StartAfter        string
+	FetchOwner        bool
+	EncodingType      string
+}
+
+// parse list objects version 2 url queries
+func getListObjectsV2Resources(values url.Values) (v listObjectsV2Resources) {
+	for key, value := range values {
 		switch true {
-		case key == "acl":
-			return true
-		default:
-			return false
+		case key == "continuation-token":
+			v.ContinuationToken = value[0]
+		case key == "start-after":
+			v.StartAfter = value[0]
+		case key == "fetch-owner":
+			v.FetchOwner, _ = strconv.ParseBool(value[0])
+		case key == "encoding-type":
+			v.EncodingType = value[0]
 		}
 	}
-	return false
+	return
+}
+
+// check if req query values ask for list objects version 2
+func isRequestListObjectsV2(values url.Values) bool {
+	return values.Get("list-type") == "2"
+}
+
+// continuation tokens are opaque to the client, they carry the marker of the next page
+func getContinuationToken(marker string) string {
+	if marker == "" {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(marker))
+}
+
+// get marker back from a continuation token
+func getMarkerFromContinuationToken(token string) (string, error) {
+	marker, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	if len(marker) == 0 {
+		return "", errors.New("empty continuation token")
+	}
+	return string(marker), nil
+}
+
+// url encode a key the way S3 does for encoding-type=url, path separators are left intact
+func s3URLEncode(key string) string {
+	return strings.Replace(url.QueryEscape(key), "%2F", "/", -1)
+}
+
+// check if req query values have acl
+func isRequestACL(values url.Values) bool {
+	_, ok := values["acl"]
+	return ok
 }
@@ -20,13 +20,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/minio-io/minio/pkg/api"
+	"github.com/minio-io/minio/pkg/api/config"
 	"github.com/minio-io/minio/pkg/api/web"
 	"github.com/minio-io/minio/pkg/iodine"
 	"github.com/minio-io/minio/pkg/server/httpserver"
+	donutstorage "github.com/minio-io/minio/pkg/storage/donut"
 	"github.com/minio-io/minio/pkg/storage/drivers/donut"
 	"github.com/minio-io/minio/pkg/storage/drivers/memory"
 	"github.com/minio-io/minio/pkg/utils/log"
 	"reflect"
+	"sort"
 )
 
 // MemoryFactory is used to build memory api servers
@@ -66,12 +69,86 @@ type DonutFactory struct {
 // GetStartServerFunc DonutFactory builds donut api servers
 func (f DonutFactory) GetStartServerFunc() StartServerFunc {
 	return func() (chan<- string, <-chan error) {
-		_, _, driver := donut.Start(f.Paths)
+		if err := SetRemoteDiskCredentials(f.Paths); err != nil {
+			log.Fatal(iodine.New(err, nil))
+		}
+		_, driverStatus, driver := donut.Start(f.Paths)
+		// a donut failing to open is not served
+		if err := <-driverStatus; err != nil {
+			log.Fatal(iodine.New(err, nil))
+		}
 		ctrl, status, _ := httpserver.Start(api.HTTPHandler(f.Domain, driver), f.Config)
 		return ctrl, status
 	}
 }
 
+// DiskFactory is used to build servers exporting local disks to donuts of other hosts
+type DiskFactory struct {
+	httpserver.Config
+	Paths []string
+}
+
+// GetStartServerFunc DiskFactory builds disk servers
+func (f DiskFactory) GetStartServerFunc() StartServerFunc {
+	return func() (chan<- string, <-chan error) {
+		credentials, err := getDiskCredentials()
+		if err != nil {
+			log.Fatal(iodine.New(err, nil))
+		}
+		handler, err := donutstorage.NewDiskServer(f.Paths, credentials)
+		if err != nil {
+			log.Fatal(iodine.New(err, nil))
+		}
+		ctrl, status, _ := httpserver.Start(handler, f.Config)
+		return ctrl, status
+	}
+}
+
+// getDiskCredentials - access keys of the configured users mapped to their secret keys, requests
+// to exported disks are signed with one of them
+func getDiskCredentials() (map[string]string, error) {
+	var conf = config.Config{}
+	if err := conf.SetupConfig(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if err := conf.ReadConfig(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	credentials := make(map[string]string)
+	for _, user := range conf.Users {
+		credentials[user.AccessKey] = user.SecretKey
+	}
+	if len(credentials) == 0 {
+		return nil, iodine.New(errors.New("no access keys configured to authenticate disk requests"), nil)
+	}
+	return credentials, nil
+}
+
+// SetRemoteDiskCredentials - sign requests to the remote disks among paths with the first of the
+// configured access keys, disk servers must be configured with the same key
+func SetRemoteDiskCredentials(paths []string) error {
+	remote := false
+	for _, p := range paths {
+		remote = remote || donutstorage.IsRemoteDisk(p)
+	}
+	if !remote {
+		return nil
+	}
+	credentials, err := getDiskCredentials()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	var accessKeys []string
+	for accessKey := range credentials {
+		accessKeys = append(accessKeys, accessKey)
+	}
+	sort.Strings(accessKeys)
+	if err := donutstorage.SetDiskCredentials(accessKeys[0], credentials[accessKeys[0]]); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
 // StartServerFunc describes a function that can be used to start a server with StartMinio
 type StartServerFunc func() (chan<- string, <-chan error)
 
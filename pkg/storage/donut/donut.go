@@ -18,15 +18,33 @@ package donut
 
 import (
 	"errors"
+	"path"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
 // donut struct internal data
 type donut struct {
 	name    string
+	id      string
 	buckets map[string]Bucket
 	nodes   map[string]Node
+	// protects buckets and nodes, background jobs work on a snapshot of them
+	lock *sync.RWMutex
+	// throttles I/O of background tasks like healing
+	healLimiter *rateLimiter
+	scrubber    *scrubber
+	rebalancer  *rebalancer
+	// percentage of every disk kept free
+	reserve *diskReserve
+	// serializes updates of bucket metadata against each other and repairs
+	bucketMetadataLock *sync.RWMutex
+	// opened without modifying its disks, see NewReadOnlyDonut
+	readOnly bool
 }
 
 // config files used inside Donut
@@ -34,25 +52,88 @@ const (
 	// donut object metadata and config
 	donutObjectMetadataConfig = "donutObjectMetadata.json"
 	donutConfig               = "donutMetadata.json"
+	nodeConfig                = "nodeMetadata.json"
+
+	// disk identity, in the root of every disk
+	diskFormatConfig = "format.json"
 
 	// bucket, object metadata
 	bucketMetadataConfig = "bucketMetadata.json"
 	objectMetadataConfig = "objectMetadata.json"
+	// object index of a bucket, in every bucket slice
+	objectIndexConfig = "objectIndex.log"
+
+	// scrub and rebalance progress, persisted across restarts
+	scrubProgressConfig     = "scrubProgress.json"
+	rebalanceProgressConfig = "rebalanceProgress.json"
+
+	// objects are staged here on every disk, before being swapped into their bucket
+	stagingDir = ".staging"
+	// a staged object <name> is marked committing by <name>.commit
+	commitMarkerSuffix = ".commit"
+)
+
+// renames of a commit are retried this many times, waiting a little longer every time
+const (
+	commitRetries    = 3
+	commitRetryDelay = 100 * time.Millisecond
+)
+
+// stagingGracePeriod - entries left in staging are swept on startup once untouched this long,
+// younger ones may belong to a write still in progress by another process sharing the disks
+const stagingGracePeriod = time.Hour
+
+// maxObjectNameLength - longest name of an object directory, most filesystems limit names to 255 bytes
+const maxObjectNameLength = 255
+
+// every encoded block is prefixed on disk with its checksum, recorded as "sys.blockChecksum"
+const (
+	blockChecksumCRC32C = "crc32c"
+	blockChecksumSize   = 4
 )
 
+// errBitrot - encoded block read back does not match its checksum
+var errBitrot = errors.New("bitrot detected, block checksum mismatch")
+
+// healBandwidth - bytes per second healing is allowed to read and write
+const healBandwidth = 32 * 1024 * 1024
+
 // attachDonutNode - wrapper function to instantiate a new node for associated donut
-// based on the provided configuration
-func (d donut) attachDonutNode(hostname string, disks []string) error {
+// based on the provided configuration, disks are ordered by their format. Blank disks are
+// formatted, unless the donut is read only
+func (d donut) attachDonutNode(hostname string, disks []string, formats map[string]*diskFormat) error {
 	node, err := NewNode(hostname)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	orders, err := getDiskOrders(hostname, disks, formats)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
 	for i, disk := range disks {
-		// Order is necessary for maps, keep order number separately
-		newDisk, err := NewDisk(disk, i)
+		newDisk, err := OpenDisk(disk, orders[i])
 		if err != nil {
 			return iodine.New(err, nil)
 		}
+		if formats[disk] == nil && d.readOnly {
+			return iodine.New(errors.New("disk not formatted"), map[string]string{"node": hostname, "disk": disk})
+		}
+		if d.readOnly {
+			if err := node.AttachDisk(newDisk); err != nil {
+				return iodine.New(err, nil)
+			}
+			continue
+		}
+		if formats[disk] == nil {
+			if err := d.formatDisk(hostname, newDisk); err != nil {
+				return iodine.New(err, nil)
+			}
+			// reopened to pick up its identity
+			newDisk, err = OpenDisk(disk, orders[i])
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+		}
 		if err := newDisk.MakeDir(d.name); err != nil {
 			return iodine.New(err, nil)
 		}
@@ -66,26 +147,239 @@ func (d donut) attachDonutNode(hostname string, disks []string) error {
 	return nil
 }
 
+// formatDisk - give a disk attached for the first time its identity
+func (d donut) formatDisk(hostname string, disk Disk) error {
+	diskID, err := newUUID()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	format := diskFormat{
+		Version:   "1.0",
+		DiskID:    diskID,
+		DonutID:   d.id,
+		Donut:     d.name,
+		Node:      hostname,
+		Order:     disk.GetOrder(),
+		Formatted: time.Now().UTC(),
+	}
+	if err := writeDiskFormat(disk, format); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// readDiskFormats - read formats of all disks, verifying the formatted ones belong to this donut
+// and are not duplicates of each other. Returns the id of the donut, a new one if none of
+// the disks were formatted yet
+func readDiskFormats(donutName string, nodeDiskMap map[string][]string) (string, map[string]*diskFormat, error) {
+	formats := make(map[string]*diskFormat)
+	donutIDs := make(map[string]int)
+	for _, disks := range nodeDiskMap {
+		for _, disk := range disks {
+			if _, ok := formats[disk]; ok {
+				return "", nil, iodine.New(errors.New("duplicate disk"), map[string]string{"disk": disk})
+			}
+			// order is not known before the format is read
+			formatDisk, err := OpenDisk(disk, 0)
+			if err != nil {
+				return "", nil, iodine.New(err, nil)
+			}
+			format, err := readDiskFormat(formatDisk)
+			if err != nil {
+				return "", nil, iodine.New(err, nil)
+			}
+			formats[disk] = format
+			if format != nil {
+				donutIDs[format.DonutID] = donutIDs[format.DonutID] + 1
+			}
+		}
+	}
+	// disks agreeing with most others belong to this donut
+	donutID := ""
+	for id, count := range donutIDs {
+		if count > donutIDs[donutID] {
+			donutID = id
+		}
+	}
+	if donutID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return "", nil, iodine.New(err, nil)
+		}
+		return id, formats, nil
+	}
+	diskIDs := make(map[string]string)
+	for hostname, disks := range nodeDiskMap {
+		for _, disk := range disks {
+			format := formats[disk]
+			if format == nil {
+				continue
+			}
+			errParams := map[string]string{"donut": donutName, "node": hostname, "disk": disk}
+			if format.DonutID != donutID || format.Donut != donutName {
+				return "", nil, iodine.New(errors.New("disk belongs to another donut"), errParams)
+			}
+			if format.Node != hostname {
+				return "", nil, iodine.New(errors.New("disk belongs to another node"), errParams)
+			}
+			if duplicate, ok := diskIDs[format.DiskID]; ok {
+				errParams["duplicate"] = duplicate
+				return "", nil, iodine.New(errors.New("duplicate disk"), errParams)
+			}
+			diskIDs[format.DiskID] = disk
+		}
+	}
+	return donutID, formats, nil
+}
+
+// getDiskOrders - order of every disk of a node, formatted disks keep the order they were
+// formatted with. Blank disks take the place of missing disks first, then extend the node
+func getDiskOrders(hostname string, disks []string, formats map[string]*diskFormat) ([]int, error) {
+	orders := make([]int, len(disks))
+	taken := make([]bool, len(disks))
+	var blankDisks []int
+	for i, disk := range disks {
+		format := formats[disk]
+		if format == nil {
+			blankDisks = append(blankDisks, i)
+			continue
+		}
+		errParams := map[string]string{"node": hostname, "disk": disk, "order": strconv.Itoa(format.Order)}
+		if format.Order < 0 || format.Order >= len(disks) {
+			return nil, iodine.New(errors.New("disk missing from node"), errParams)
+		}
+		if taken[format.Order] {
+			return nil, iodine.New(errors.New("duplicate disk order"), errParams)
+		}
+		taken[format.Order] = true
+		orders[i] = format.Order
+	}
+	order := 0
+	for _, i := range blankDisks {
+		for taken[order] {
+			order = order + 1
+		}
+		taken[order] = true
+		orders[i] = order
+	}
+	return orders, nil
+}
+
 // NewDonut - instantiate a new donut
 func NewDonut(donutName string, nodeDiskMap map[string][]string) (Donut, error) {
+	d, err := newDonut(donutName, nodeDiskMap, false)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if err := d.recoverStaging(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return d, nil
+}
+
+// NewReadOnlyDonut - instantiate a donut to be inspected while another process serves it.
+// Nothing is written to its disks, disks not formatted for this donut are refused and
+// staged objects are left to the process serving it
+func NewReadOnlyDonut(donutName string, nodeDiskMap map[string][]string) (Donut, error) {
+	d, err := newDonut(donutName, nodeDiskMap, true)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return d, nil
+}
+
+// newDonut - instantiate a donut attaching all its disks
+func newDonut(donutName string, nodeDiskMap map[string][]string, readOnly bool) (donut, error) {
 	if donutName == "" || len(nodeDiskMap) == 0 {
-		return nil, iodine.New(errors.New("invalid argument"), nil)
+		return donut{}, iodine.New(errors.New("invalid argument"), nil)
+	}
+	donutID, formats, err := readDiskFormats(donutName, nodeDiskMap)
+	if err != nil {
+		return donut{}, iodine.New(err, nil)
 	}
 	nodes := make(map[string]Node)
 	buckets := make(map[string]Bucket)
 	d := donut{
-		name:    donutName,
-		nodes:   nodes,
-		buckets: buckets,
+		name:               donutName,
+		id:                 donutID,
+		nodes:              nodes,
+		buckets:            buckets,
+		lock:               new(sync.RWMutex),
+		healLimiter:        newRateLimiter(healBandwidth),
+		scrubber:           newScrubber(),
+		rebalancer:         newRebalancer(),
+		reserve:            newDiskReserve(),
+		bucketMetadataLock: new(sync.RWMutex),
+		readOnly:           readOnly,
 	}
 	for k, v := range nodeDiskMap {
 		if len(v) == 0 {
-			return nil, iodine.New(errors.New("invalid number of disks per node"), nil)
+			return donut{}, iodine.New(errors.New("invalid number of disks per node"), nil)
 		}
-		err := d.attachDonutNode(k, v)
+		err := d.attachDonutNode(k, v, formats)
 		if err != nil {
-			return nil, iodine.New(err, nil)
+			return donut{}, iodine.New(err, nil)
 		}
 	}
 	return d, nil
 }
+
+// recoverStaging - complete commits interrupted by a crash, then sweep everything else left
+// in staging by writes which never completed and were not touched within the grace period
+func (d donut) recoverStaging() error {
+	if err := d.getDonutBuckets(); err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, bucketName := range d.getBucketNames() {
+		bucket, _ := d.getBucket(bucketName)
+		if err := bucket.RecoverStagedObjects(); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	for _, node := range d.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			sweepStaging(disk, time.Now().Add(-stagingGracePeriod))
+		}
+	}
+	return nil
+}
+
+// sweepStaging - remove entries left in staging on a disk which were last modified before
+// cutoff, along with every file below them
+func sweepStaging(disk Disk, cutoff time.Time) {
+	dirs, err := disk.ListDir(stagingDir)
+	if err != nil {
+		return
+	}
+	files, err := disk.ListFiles(stagingDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range append(dirs, files...) {
+		entryPath := path.Join(stagingDir, entry.Name())
+		if entry.ModTime().After(cutoff) {
+			continue
+		}
+		// a directory is not touched while data is appended to the files in it
+		if entry.IsDir() {
+			files, err := disk.ListFiles(entryPath)
+			if err != nil {
+				continue
+			}
+			touched := false
+			for _, file := range files {
+				touched = touched || file.ModTime().After(cutoff)
+			}
+			if touched {
+				continue
+			}
+		}
+		if err := disk.RemoveAll(entryPath); err != nil {
+			log.Error.Println(iodine.New(err, map[string]string{"disk": disk.GetPath(), "staging": entry.Name()}))
+		}
+	}
+}
@@ -18,18 +18,18 @@ package donut
 
 import (
 	"errors"
-	"fmt"
 	"io"
-	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"crypto/md5"
 	"encoding/hex"
 
 	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
 // internal struct carrying bucket specific information
@@ -38,12 +38,17 @@ type bucket struct {
 	acl       string
 	time      time.Time
 	donutName string
-	nodes     map[string]Node
-	objects   map[string]Object
+	// nodes of the donut, a snapshot as nodes are attached and detached
+	nodes func() map[string]Node
+	// serializes swapping in of new object slices against readers opening them
+	lock    *sync.RWMutex
+	indexes *objectIndexes
+	// free space of every disk writes may not use, shared with the donut
+	reserve *diskReserve
 }
 
 // NewBucket - instantiate a new bucket
-func NewBucket(bucketName, aclType, donutName string, nodes map[string]Node) (Bucket, map[string]string, error) {
+func NewBucket(bucketName, aclType, donutName string, nodes func() map[string]Node, reserve *diskReserve) (Bucket, map[string]string, error) {
 	errParams := map[string]string{
 		"bucketName": bucketName,
 		"donutName":  donutName,
@@ -60,80 +65,223 @@ func NewBucket(bucketName, aclType, donutName string, nodes map[string]Node) (Bu
 	b.acl = aclType
 	b.time = time.Now()
 	b.donutName = donutName
-	b.objects = make(map[string]Object)
 	b.nodes = nodes
+	b.lock = new(sync.RWMutex)
+	b.indexes = newObjectIndexes()
+	b.reserve = reserve
 	return b, bucketMetadata, nil
 }
 
-// ListObjects - list all objects
-func (b bucket) ListObjects() (map[string]Object, error) {
-	nodeSlice := 0
-	for _, node := range b.nodes {
-		disks, err := node.ListDisks()
+// ListObjects - list a single page of objects and common prefixes, in lexical order
+func (b bucket) ListObjects(prefix, marker, delimiter string, maxkeys int) ([]string, []string, bool, error) {
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return nil, nil, false, iodine.New(err, nil)
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.listIndexedObjects(sets, prefix, marker, delimiter, maxkeys)
+}
+
+// listObjects - list all objects of an erasure set by reading every object slice, caller is
+// expected to hold the bucket lock
+func (b bucket) listObjects(set erasureSet) (map[string]Object, error) {
+	objectList := make(map[string]Object)
+	for _, setDisk := range set.disks {
+		bucketPath := path.Join(b.donutName, setDisk.getBucketSlice(b.name))
+		// a failed disk or an unreadable object slice is skipped, objects are
+		// listed as long as they are readable on any of the other disks
+		objects, err := setDisk.disk.ListDir(bucketPath)
 		if err != nil {
-			return nil, iodine.New(err, nil)
+			continue
 		}
-		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
-			bucketPath := path.Join(b.donutName, bucketSlice)
-			objects, err := disk.ListDir(bucketPath)
+		for _, object := range objects {
+			newObject, err := NewObject(object.Name(), setDisk.disk, bucketPath)
 			if err != nil {
 				return nil, iodine.New(err, nil)
 			}
-			for _, object := range objects {
-				newObject, err := NewObject(object.Name(), path.Join(disk.GetPath(), bucketPath))
-				if err != nil {
-					return nil, iodine.New(err, nil)
-				}
-				newObjectMetadata, err := newObject.GetObjectMetadata()
-				if err != nil {
-					return nil, iodine.New(err, nil)
-				}
-				objectName, ok := newObjectMetadata["object"]
-				if !ok {
-					return nil, iodine.New(errors.New("object corrupted"), nil)
-				}
-				b.objects[objectName] = newObject
+			newObjectMetadata, err := newObject.GetObjectMetadata()
+			if err != nil {
+				continue
+			}
+			objectName, ok := newObjectMetadata["object"]
+			if !ok {
+				continue
+			}
+			if _, err := newObject.GetDonutObjectMetadata(); err != nil {
+				continue
 			}
+			objectList[objectName] = newObject
 		}
-		nodeSlice = nodeSlice + 1
 	}
-	return b.objects, nil
+	return objectList, nil
 }
 
 // GetObject - get object
 func (b bucket) GetObject(objectName string) (reader io.ReadCloser, size int64, err error) {
 	reader, writer := io.Pipe()
-	// get list of objects
-	objects, err := b.ListObjects()
+	// slices are opened while holding the lock, an object being replaced concurrently
+	// is either read entirely from its old slices or entirely from its new slices
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	size, readers, donutObjectMetadata, err := b.openObject(objectName)
 	if err != nil {
 		return nil, 0, iodine.New(err, nil)
 	}
-	// check if object exists
-	object, ok := objects[objectName]
-	if !ok {
-		return nil, 0, iodine.New(os.ErrNotExist, nil)
+	// read and reply back to GetObject() request in a go-routine
+	go b.readEncodedData(readers, writer, donutObjectMetadata)
+	return reader, size, nil
+}
+
+// GetPartialObject - get length bytes of an object starting at start, only the chunks
+// covering the range are read and decoded
+func (b bucket) GetPartialObject(objectName string, start, length int64) (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	size, readers, donutObjectMetadata, err := b.openObject(objectName)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if start < 0 || length < 0 || start+length > size {
+		closeObjectReaders(readers)
+		return nil, iodine.New(errors.New("invalid range"), map[string]string{
+			"start":  strconv.FormatInt(start, 10),
+			"length": strconv.FormatInt(length, 10),
+			"size":   strconv.FormatInt(size, 10),
+		})
+	}
+	// the md5sum of the object is verified along with a read of the whole object
+	if start == 0 && length == size {
+		go b.readEncodedData(readers, writer, donutObjectMetadata)
+		return reader, nil
 	}
-	// verify if objectMetadata is readable, before we serve the request
-	objectMetadata, err := object.GetObjectMetadata()
+	go b.readEncodedRange(readers, writer, donutObjectMetadata, start, length)
+	return reader, nil
+}
+
+// openObject - open slices of an object for reading, caller is expected to hold the bucket lock
+func (b bucket) openObject(objectName string) (int64, []io.ReadCloser, map[string]string, error) {
+	sets, err := getErasureSets(b.nodes())
 	if err != nil {
-		return nil, 0, iodine.New(err, nil)
+		return 0, nil, nil, iodine.New(err, nil)
 	}
-	if objectName == "" || writer == nil || len(objectMetadata) == 0 {
-		return nil, 0, iodine.New(errors.New("invalid argument"), nil)
+	objectMetadata, set, err := b.getIndexedObject(sets, objectName)
+	if err != nil {
+		return 0, nil, nil, iodine.New(err, nil)
 	}
-	size, err = strconv.ParseInt(objectMetadata["size"], 10, 64)
+	if objectName == "" || len(objectMetadata) == 0 {
+		return 0, nil, nil, iodine.New(errors.New("invalid argument"), nil)
+	}
+	size, err := strconv.ParseInt(objectMetadata["size"], 10, 64)
 	if err != nil {
-		return nil, 0, iodine.New(err, nil)
+		return 0, nil, nil, iodine.New(err, nil)
 	}
 	// verify if donutObjectMetadata is readable, before we server the request
-	donutObjectMetadata, err := object.GetDonutObjectMetadata()
+	donutObjectMetadata, err := b.readDonutObjectMetadata(set, encodeObjectName(objectName), objectMetadata["created"])
 	if err != nil {
-		return nil, 0, iodine.New(err, nil)
+		return 0, nil, nil, iodine.New(err, nil)
 	}
-	// read and reply back to GetObject() request in a go-routine
-	go b.readEncodedData(b.normalizeObjectName(objectName), writer, donutObjectMetadata)
-	return reader, size, nil
+	readers, err := b.getDiskReaders(set, encodeObjectName(objectName), "data")
+	if err != nil {
+		return 0, nil, nil, iodine.New(err, nil)
+	}
+	readers, err = trimObjectReaders(readers, donutObjectMetadata)
+	if err != nil {
+		return 0, nil, nil, iodine.New(err, nil)
+	}
+	if err := b.verifyReadQuorum(objectName, readers, donutObjectMetadata); err != nil {
+		closeObjectReaders(readers)
+		return 0, nil, nil, iodine.New(err, nil)
+	}
+	return size, readers, donutObjectMetadata, nil
+}
+
+// closeObjectReaders - close all opened slice readers
+func closeObjectReaders(readers []io.ReadCloser) {
+	for _, reader := range readers {
+		if reader != nil {
+			reader.Close()
+		}
+	}
+}
+
+// trimObjectReaders - close readers of disks an object does not span, readers of slices
+// not written along with the object are stale and closed as well
+func trimObjectReaders(readers []io.ReadCloser, donutObjectMetadata map[string]string) ([]io.ReadCloser, error) {
+	closeReaders := func(readers []io.ReadCloser) {
+		for _, reader := range readers {
+			if reader != nil {
+				reader.Close()
+			}
+		}
+	}
+	width, err := getObjectWidth(donutObjectMetadata, len(readers))
+	if err != nil {
+		closeReaders(readers)
+		return nil, iodine.New(err, nil)
+	}
+	closeReaders(readers[width:])
+	readers = readers[:width]
+	healthySlices, err := parseHealthySlices(donutObjectMetadata["sys.slices"], len(readers))
+	if err != nil {
+		closeReaders(readers)
+		return nil, iodine.New(err, nil)
+	}
+	for i, reader := range readers {
+		if reader != nil && !healthySlices[i] {
+			reader.Close()
+			readers[i] = nil
+		}
+	}
+	return readers, nil
+}
+
+// verifyReadQuorum - an object can be read as long as at least K of its slices are available
+func (b bucket) verifyReadQuorum(objectName string, readers []io.ReadCloser, donutObjectMetadata map[string]string) error {
+	available := 0
+	for _, reader := range readers {
+		if reader != nil {
+			available = available + 1
+		}
+	}
+	if available == len(readers) {
+		return nil
+	}
+	required := uint64(len(readers))
+	if len(readers) > 1 {
+		k, err := strconv.ParseUint(donutObjectMetadata["sys.erasureK"], 10, 8)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		required = k
+	}
+	errParams := map[string]string{
+		"bucket":    b.name,
+		"object":    objectName,
+		"available": strconv.Itoa(available),
+		"required":  strconv.FormatUint(required, 10),
+	}
+	if uint64(available) < required {
+		return iodine.New(errors.New("not enough object slices available"), errParams)
+	}
+	log.Error.Println(iodine.New(errors.New("degraded read, object slices missing"), errParams))
+	return nil
+}
+
+// GetObjectMetadata - get object metadata
+func (b bucket) GetObjectMetadata(objectName string) (map[string]string, error) {
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	objectMetadata, _, err := b.getIndexedObject(sets, objectName)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return objectMetadata, nil
 }
 
 // PutObject - put a new object
@@ -141,52 +289,133 @@ func (b bucket) PutObject(objectName string, objectData io.Reader, expectedMD5Su
 	if objectName == "" || objectData == nil {
 		return iodine.New(errors.New("invalid argument"), nil)
 	}
-	writers, err := b.getDiskWriters(b.normalizeObjectName(objectName), "data")
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// a copy left on the erasure set the object was placed on before is older, moved away by rebalance
+	set := sets[newPlacementRing(sets).getErasureSet(b.name, encodeObjectName(objectName))]
+	// fail early rather than halfway through writing a stripe, size is unknown without a content length
+	size := int64(-1)
+	if contentLength, ok := metadata["contentLength"]; ok {
+		size, err = strconv.ParseInt(contentLength, 10, 64)
+		if err != nil {
+			return iodine.New(errors.New("invalid argument"), map[string]string{"contentLength": contentLength})
+		}
+	}
+	class := metadata["storageClass"]
+	if class == "" {
+		class = StorageClassStandard
+	}
+	if err := b.verifyFreeSpace(set, class, size); err != nil {
+		return iodine.New(err, nil)
+	}
+	// write everything into a staging location first, swapped in only once complete
+	stagingName, err := newStagingName()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// partial slices left behind on failed disks, or all slices if write quorum was not reached
+	defer b.removeStagedObject(stagingName)
+	healthySlices, writeQuorum, err := b.writeStagedObject(set, stagingName, objectName, objectData, expectedMD5Sum, metadata)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.commitStagedObject(set, stagingName, encodeObjectName(objectName), healthySlices, writeQuorum); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// writeStagedObject - write object data and metadata slices into staging location on the disks
+// of an erasure set, slices failing to write are dropped as long as write quorum is met. Returns
+// the healthy slices and the write quorum they are committed with
+func (b bucket) writeStagedObject(set erasureSet, stagingName, objectName string, objectData io.Reader, expectedMD5Sum string, metadata map[string]string) ([]bool, int, error) {
+	class := metadata["storageClass"]
+	if class == "" {
+		class = StorageClassStandard
+	}
+	sc, err := getStorageClass(class)
+	if err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	writers, err := b.getStagingWriters(set, stagingName, "data", nil)
+	if err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	// close all writers, when control flow reaches here
+	defer func() {
+		for _, writer := range writers {
+			if writer != nil {
+				writer.Close()
+			}
+		}
+	}()
 	summer := md5.New()
 	objectMetadata := make(map[string]string)
 	donutObjectMetadata := make(map[string]string)
 	objectMetadata["version"] = "1.0"
 	donutObjectMetadata["version"] = "1.0"
+	// storage class is recorded even if the object is too small to be erasure coded
+	donutObjectMetadata["sys.storageClass"] = class
+	writeQuorum := 1
 	// if total writers are only '1' do not compute erasure
 	switch len(writers) == 1 {
 	case true:
+		if writers[0] == nil {
+			return nil, 0, iodine.New(errors.New("write quorum not reached"), nil)
+		}
 		mw := io.MultiWriter(writers[0], summer)
 		totalLength, err := io.Copy(mw, objectData)
 		if err != nil {
-			return iodine.New(err, nil)
+			return nil, 0, iodine.New(err, nil)
 		}
 		donutObjectMetadata["sys.size"] = strconv.FormatInt(totalLength, 10)
 		objectMetadata["size"] = strconv.FormatInt(totalLength, 10)
 	case false:
 		// calculate data and parity dictated by total number of writers
-		k, m, err := b.getDataAndParity(len(writers))
+		k, m, err := sc.getDataAndParity(len(writers), set.getNodeWidth())
 		if err != nil {
-			return iodine.New(err, nil)
+			return nil, 0, iodine.New(err, nil)
 		}
+		writeQuorum = b.getWriteQuorum(len(writers), k)
 		// encoded data with k, m and write
-		chunkCount, totalLength, err := b.writeEncodedData(k, m, writers, objectData, summer)
+		chunkCount, totalLength, err := b.writeEncodedData(k, m, sc, writers, objectData, summer)
 		if err != nil {
-			return iodine.New(err, nil)
+			return nil, 0, iodine.New(err, nil)
 		}
 		/// donutMetadata section
-		donutObjectMetadata["sys.blockSize"] = strconv.Itoa(10 * 1024 * 1024)
+		donutObjectMetadata["sys.blockSize"] = strconv.Itoa(getBlockSize(k))
 		donutObjectMetadata["sys.chunkCount"] = strconv.Itoa(chunkCount)
 		donutObjectMetadata["sys.erasureK"] = strconv.FormatUint(uint64(k), 10)
 		donutObjectMetadata["sys.erasureM"] = strconv.FormatUint(uint64(m), 10)
-		donutObjectMetadata["sys.erasureTechnique"] = "Cauchy"
+		donutObjectMetadata["sys.erasureTechnique"] = sc.technique
+		donutObjectMetadata["sys.blockChecksum"] = blockChecksumCRC32C
 		donutObjectMetadata["sys.size"] = strconv.Itoa(totalLength)
 		// keep size inside objectMetadata as well for Object API requests
 		objectMetadata["size"] = strconv.Itoa(totalLength)
 	}
+	// data slices are durable before any metadata refers to them, remember disks holding
+	// good slices, heal reconstructs the rest later
+	healthySlices := syncSlices(b.name, writers)
+	totalHealthy := 0
+	for _, healthy := range healthySlices {
+		if healthy {
+			totalHealthy = totalHealthy + 1
+		}
+	}
+	if totalHealthy < writeQuorum {
+		return nil, 0, iodine.New(errors.New("write quorum not reached"), nil)
+	}
 	objectMetadata["bucket"] = b.name
 	objectMetadata["object"] = objectName
 	// store all user provided metadata
 	for k, v := range metadata {
 		objectMetadata[k] = v
 	}
+	objectMetadata["storageClass"] = class
 	dataMd5sum := summer.Sum(nil)
 	objectMetadata["created"] = time.Now().Format(time.RFC3339Nano)
 
@@ -198,20 +427,101 @@ func (b bucket) PutObject(objectName string, objectData io.Reader, expectedMD5Su
 	// Verify if the written object is equal to what is expected, only if it is requested as such
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), objectMetadata["md5"]); err != nil {
-			return iodine.New(err, nil)
+			return nil, 0, iodine.New(err, nil)
 		}
 	}
+	donutObjectMetadata["sys.slices"] = formatHealthySlices(healthySlices)
 	// write donut specific metadata
-	if err := b.writeDonutObjectMetadata(b.normalizeObjectName(objectName), donutObjectMetadata); err != nil {
-		return iodine.New(err, nil)
+	donutObjectMetadataWriters, err := b.getStagingWriters(set, stagingName, donutObjectMetadataConfig, healthySlices)
+	if err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	if err := b.writeDonutObjectMetadata(donutObjectMetadataWriters, donutObjectMetadata); err != nil {
+		return nil, 0, iodine.New(err, nil)
 	}
 	// write object specific metadata
-	if err := b.writeObjectMetadata(b.normalizeObjectName(objectName), objectMetadata); err != nil {
+	objectMetadataWriters, err := b.getStagingWriters(set, stagingName, objectMetadataConfig, healthySlices)
+	if err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	if err := b.writeObjectMetadata(objectMetadataWriters, objectMetadata); err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	return healthySlices, writeQuorum, nil
+}
+
+// SetObjectMetadata - merge metadata into existing object metadata
+func (b bucket) SetObjectMetadata(objectName string, metadata map[string]string) error {
+	if objectName == "" || len(metadata) == 0 {
+		return iodine.New(errors.New("invalid argument"), nil)
+	}
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
 		return iodine.New(err, nil)
 	}
-	// close all writers, when control flow reaches here
-	for _, writer := range writers {
-		writer.Close()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	objectMetadata, set, err := b.getIndexedObject(sets, objectName)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	donutObjectMetadata, err := b.readDonutObjectMetadata(set, encodeObjectName(objectName), objectMetadata["created"])
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for k, v := range metadata {
+		objectMetadata[k] = v
+	}
+	slices := b.getObjectSlices(set, encodeObjectName(objectName))
+	width, err := getObjectWidth(donutObjectMetadata, len(slices))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	healthySlices, err := parseHealthySlices(donutObjectMetadata["sys.slices"], width)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// metadata is staged and renamed over the existing file, never left half written
+	stagingName, err := newStagingName()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer b.removeStagedObject(stagingName)
+	for i, slice := range slices[:width] {
+		if !healthySlices[i] {
+			continue
+		}
+		stagingPath := path.Join(stagingDir, stagingName, objectMetadataConfig)
+		writer, err := slice.disk.MakeFile(stagingPath)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := b.writeObjectMetadata([]io.WriteCloser{writer}, objectMetadata); err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := slice.disk.Rename(stagingPath, path.Join(slice.objectPath, objectMetadataConfig)); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := b.indexObject(set, objectName, objectMetadata); err != nil {
+		return iodine.New(err, nil)
 	}
 	return nil
 }
+
+// readDonutObjectMetadata - donut object metadata of the slice on an erasure set written along
+// with the object metadata created at the given time
+func (b bucket) readDonutObjectMetadata(set erasureSet, objectName, created string) (map[string]string, error) {
+	for _, slice := range b.getObjectSlices(set, objectName) {
+		objectMetadata, err := readSliceMetadata(slice, objectMetadataConfig)
+		if err != nil || objectMetadata["created"] != created {
+			continue
+		}
+		donutObjectMetadata, err := readSliceMetadata(slice, donutObjectMetadataConfig)
+		if err != nil {
+			continue
+		}
+		return donutObjectMetadata, nil
+	}
+	return nil, iodine.New(errObjectUnrecoverable, map[string]string{"bucket": b.name, "object": objectName})
+}
@@ -19,18 +19,25 @@ package donut
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
+	"os"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/minio-io/minio/pkg/iodine"
-	"github.com/minio-io/minio/pkg/utils/split"
+	"github.com/minio-io/minio/pkg/utils/checksum/crc32c"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
 /// This file contains all the internal functions used by Bucket interface
@@ -55,114 +62,314 @@ func (b bucket) isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) error {
 }
 
 // writeObjectMetadata - write additional object metadata
-func (b bucket) writeObjectMetadata(objectName string, objectMetadata map[string]string) error {
-	if len(objectMetadata) == 0 {
-		return iodine.New(errors.New("invalid argument"), nil)
+func (b bucket) writeObjectMetadata(objectMetadataWriters []io.WriteCloser, objectMetadata map[string]string) error {
+	return writeSliceMetadata(objectMetadataWriters, objectMetadata)
+}
+
+// writeDonutObjectMetadata - write donut related object metadata
+func (b bucket) writeDonutObjectMetadata(objectMetadataWriters []io.WriteCloser, objectMetadata map[string]string) error {
+	return writeSliceMetadata(objectMetadataWriters, objectMetadata)
+}
+
+// writeSliceMetadata - encode metadata into every writer, synced to stable storage once written
+func writeSliceMetadata(writers []io.WriteCloser, metadata map[string]string) error {
+	closeWriters := func() {
+		for _, writer := range writers {
+			if writer != nil {
+				writer.Close()
+			}
+		}
 	}
-	objectMetadataWriters, err := b.getDiskWriters(objectName, objectMetadataConfig)
-	if err != nil {
-		return iodine.New(err, nil)
+	if len(metadata) == 0 {
+		closeWriters()
+		return iodine.New(errors.New("invalid argument"), nil)
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		defer objectMetadataWriter.Close()
+	for _, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		jenc := json.NewEncoder(writer)
+		if err := jenc.Encode(metadata); err != nil {
+			closeWriters()
+			return iodine.New(err, nil)
+		}
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		jenc := json.NewEncoder(objectMetadataWriter)
-		if err := jenc.Encode(objectMetadata); err != nil {
+	for i, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		if err := syncAndClose(writer); err != nil {
+			for _, writer := range writers[i+1:] {
+				if writer != nil {
+					writer.Close()
+				}
+			}
 			return iodine.New(err, nil)
 		}
 	}
 	return nil
 }
 
-// writeDonutObjectMetadata - write donut related object metadata
-func (b bucket) writeDonutObjectMetadata(objectName string, objectMetadata map[string]string) error {
-	if len(objectMetadata) == 0 {
-		return iodine.New(errors.New("invalid argument"), nil)
-	}
-	objectMetadataWriters, err := b.getDiskWriters(objectName, donutObjectMetadataConfig)
-	if err != nil {
-		return iodine.New(err, nil)
-	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		defer objectMetadataWriter.Close()
-	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		jenc := json.NewEncoder(objectMetadataWriter)
-		if err := jenc.Encode(objectMetadata); err != nil {
+// syncer - files which can be flushed to stable storage, remote files are flushed when closed
+type syncer interface {
+	Sync() error
+}
+
+// syncAndClose - flush a written file to stable storage before closing it
+func syncAndClose(writer io.WriteCloser) error {
+	if file, ok := writer.(syncer); ok {
+		if err := file.Sync(); err != nil {
+			writer.Close()
 			return iodine.New(err, nil)
 		}
 	}
+	if err := writer.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
 	return nil
 }
 
-// TODO - This a temporary normalization of objectNames, need to find a better way
-//
-// normalizedObjectName - all objectNames with "/" get normalized to a simple objectName
+// encodeObjectName - name of the directory holding an object in its bucket slice. Every byte
+// outside of [A-Za-z0-9_.-] and a leading dot are escaped as %XX, names never collide nor
+// nest. Names too long for a filesystem are replaced by "+" followed by their sha256sum,
+// the object name itself is always kept in its metadata
 //
 // example:
 // user provided value - "this/is/my/deep/directory/structure"
-// donut normalized value - "this-is-my-deep-directory-structure"
-//
-func (b bucket) normalizeObjectName(objectName string) string {
-	// replace every '/' with '-'
-	return strings.Replace(objectName, "/", "-", -1)
+// donut encoded value - "this%2Fis%2Fmy%2Fdeep%2Fdirectory%2Fstructure"
+func encodeObjectName(objectName string) string {
+	var encoded bytes.Buffer
+	for i := 0; i < len(objectName); i++ {
+		c := objectName[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+			encoded.WriteByte(c)
+		case c == '.' && i > 0:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	if encoded.Len() > maxObjectNameLength {
+		sum := sha256.Sum256([]byte(objectName))
+		return "+" + hex.EncodeToString(sum[:])
+	}
+	return encoded.String()
 }
 
-// getDataAndParity - calculate k, m (data and parity) values from number of disks
-func (b bucket) getDataAndParity(totalWriters int) (k uint8, m uint8, err error) {
-	if totalWriters <= 1 {
-		return 0, 0, iodine.New(errors.New("invalid argument"), nil)
+// MigrateObjectNames - move objects stored under names of an older encoding to their
+// current name on every disk, their names are recovered from their metadata
+func (b bucket) MigrateObjectNames() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.migrateObjectNames()
+}
+
+// migrateObjectNames - caller is expected to hold the bucket lock
+func (b bucket) migrateObjectNames() error {
+	donutDisks, err := getDonutDisks(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
 	}
-	quotient := totalWriters / 2 // not using float or abs to let integer round off to lower value
-	// quotient cannot be bigger than (255 / 2) = 127
-	if quotient > 127 {
-		return 0, 0, iodine.New(errors.New("parity over flow"), nil)
+	for _, setDisk := range donutDisks {
+		disk := setDisk.disk
+		bucketPath := path.Join(b.donutName, setDisk.getBucketSlice(b.name))
+		objects, err := disk.ListDir(bucketPath)
+		if err != nil {
+			continue
+		}
+		for _, object := range objects {
+			slice := objectSlice{disk: disk, objectPath: path.Join(bucketPath, object.Name())}
+			objectMetadata, err := readSliceMetadata(slice, objectMetadataConfig)
+			if err != nil {
+				continue
+			}
+			encodedName := encodeObjectName(objectMetadata["object"])
+			if encodedName == object.Name() {
+				continue
+			}
+			objectPath := path.Join(bucketPath, encodedName)
+			// only found under its current name if written again since, the old copy is stale
+			if _, err := disk.ListFiles(objectPath); err == nil {
+				if err := disk.RemoveAll(slice.objectPath); err != nil {
+					return iodine.New(err, nil)
+				}
+				continue
+			}
+			if err := disk.Rename(slice.objectPath, objectPath); err != nil {
+				return iodine.New(err, nil)
+			}
+		}
 	}
-	remainder := totalWriters % 2 // will be 1 for odd and 0 for even numbers
-	k = uint8(quotient + remainder)
-	m = uint8(quotient)
-	return k, m, nil
+	return nil
 }
 
-// writeEncodedData -
-func (b bucket) writeEncodedData(k, m uint8, writers []io.WriteCloser, objectData io.Reader, summer hash.Hash) (int, int, error) {
-	chunks := split.Stream(objectData, 10*1024*1024)
-	encoder, err := NewEncoder(k, m, "Cauchy")
+// writeEncodedData - split object data into blocks erasure coded with k, m and the technique of
+// the storage class. Every slice is written in its own goroutine, the next chunk is encoded while
+// previous ones are being written
+func (b bucket) writeEncodedData(k, m uint8, sc storageClass, writers []io.WriteCloser, objectData io.Reader, summer hash.Hash) (int, int, error) {
+	encoder, err := NewEncoder(k, m, sc.technique)
 	if err != nil {
 		return 0, 0, iodine.New(err, nil)
 	}
+	blockSize := getBlockSize(k)
+	encodedBlockLen, err := encoder.GetEncodedBlockLen(blockSize)
+	if err != nil {
+		return 0, 0, iodine.New(err, nil)
+	}
+	writeQuorum := b.getWriteQuorum(len(writers), k)
+	if err := verifyWriteQuorum(writers, writeQuorum); err != nil {
+		return 0, 0, iodine.New(err, nil)
+	}
+	slices := newSliceWriters(b.name, writers)
+	chunkCount, totalLength, err := b.encodeChunks(encoder, encodedBlockLen*int(k+m), blockSize, slices, writeQuorum, objectData, summer)
+	slices.close()
+	if err != nil {
+		return 0, 0, iodine.New(err, nil)
+	}
+	if err := verifyWriteQuorum(writers, writeQuorum); err != nil {
+		return 0, 0, iodine.New(err, nil)
+	}
+	return chunkCount, totalLength, nil
+}
+
+// encodeChunks - read, encode and queue chunks of blockSize bytes until object data is exhausted.
+// Chunks are read into pooled buffers of bufferSize bytes, large enough to be encoded in place
+func (b bucket) encodeChunks(encoder Encoder, bufferSize, blockSize int, slices *sliceWriters, writeQuorum int, objectData io.Reader, summer hash.Hash) (int, int, error) {
 	chunkCount := 0
 	totalLength := 0
-	for chunk := range chunks {
-		if chunk.Err == nil {
-			totalLength = totalLength + len(chunk.Data)
-			encodedBlocks, _ := encoder.Encode(chunk.Data)
-			summer.Write(chunk.Data)
-			for blockIndex, block := range encodedBlocks {
-				_, err := io.Copy(writers[blockIndex], bytes.NewBuffer(block))
-				if err != nil {
-					return 0, 0, iodine.New(err, nil)
-				}
+	for {
+		buffer := getChunkBuffer(bufferSize)
+		n, err := io.ReadFull(objectData, (*buffer)[:blockSize])
+		// object data readers may wrap EOF
+		switch iodine.ToError(err) {
+		case nil, io.ErrUnexpectedEOF:
+		case io.EOF:
+			if n == 0 {
+				chunkBufferPool.Put(buffer)
+				return chunkCount, totalLength, nil
 			}
+		default:
+			chunkBufferPool.Put(buffer)
+			return 0, 0, iodine.New(err, nil)
+		}
+		data := (*buffer)[:n]
+		summer.Write(data)
+		encodedBlocks, err := encoder.Encode(data)
+		if err != nil {
+			chunkBufferPool.Put(buffer)
+			return 0, 0, iodine.New(err, nil)
+		}
+		slices.write(&encodedChunk{blocks: encodedBlocks, buffer: buffer})
+		if slices.healthy() < writeQuorum {
+			return 0, 0, iodine.New(errors.New("write quorum not reached"), map[string]string{
+				"available":   strconv.Itoa(slices.healthy()),
+				"writeQuorum": strconv.Itoa(writeQuorum),
+			})
 		}
 		chunkCount = chunkCount + 1
+		totalLength = totalLength + n
+		if n < blockSize {
+			return chunkCount, totalLength, nil
+		}
 	}
-	return chunkCount, totalLength, nil
+}
+
+// getWriteQuorum - number of slices which need to be written for a write to succeed
+func (b bucket) getWriteQuorum(totalWriters int, k uint8) int {
+	writeQuorum := int(k) + 1
+	if writeQuorum > totalWriters {
+		writeQuorum = totalWriters
+	}
+	return writeQuorum
+}
+
+// verifyWriteQuorum - returns error if fewer than writeQuorum writers are left
+func verifyWriteQuorum(writers []io.WriteCloser, writeQuorum int) error {
+	available := 0
+	for _, writer := range writers {
+		if writer != nil {
+			available = available + 1
+		}
+	}
+	if available < writeQuorum {
+		return iodine.New(errors.New("write quorum not reached"), map[string]string{
+			"available":   strconv.Itoa(available),
+			"writeQuorum": strconv.Itoa(writeQuorum),
+		})
+	}
+	return nil
+}
+
+// formatHealthySlices - comma separated list of healthy slices, stored as "sys.slices"
+func formatHealthySlices(healthySlices []bool) string {
+	var slices []string
+	for i, healthy := range healthySlices {
+		if healthy {
+			slices = append(slices, strconv.Itoa(i))
+		}
+	}
+	return strings.Join(slices, ",")
+}
+
+// parseHealthySlices - parse "sys.slices", objects written before it was recorded have all slices healthy
+func parseHealthySlices(slices string, totalSlices int) ([]bool, error) {
+	healthySlices := make([]bool, totalSlices)
+	if slices == "" {
+		for i := range healthySlices {
+			healthySlices[i] = true
+		}
+		return healthySlices, nil
+	}
+	for _, slice := range strings.Split(slices, ",") {
+		i, err := strconv.Atoi(slice)
+		if err != nil || i < 0 || i >= totalSlices {
+			return nil, iodine.New(errors.New("invalid healthy slices"), map[string]string{"slices": slices})
+		}
+		healthySlices[i] = true
+	}
+	return healthySlices, nil
+}
+
+// getObjectWidth - number of disks an object is spread over, recorded through its erasure
+// parameters. Objects written before more disks were attached keep spanning only the disks
+// with the lowest order until rebalanced
+func getObjectWidth(donutObjectMetadata map[string]string, totalDisks int) (int, error) {
+	width := 1
+	if _, ok := donutObjectMetadata["sys.erasureK"]; ok {
+		k, err := strconv.ParseUint(donutObjectMetadata["sys.erasureK"], 10, 8)
+		if err != nil {
+			return 0, iodine.New(err, nil)
+		}
+		m, err := strconv.ParseUint(donutObjectMetadata["sys.erasureM"], 10, 8)
+		if err != nil {
+			return 0, iodine.New(err, nil)
+		}
+		width = int(k + m)
+	}
+	if width > totalDisks {
+		return 0, iodine.New(errObjectTooWide, map[string]string{
+			"width": strconv.Itoa(width),
+			"disks": strconv.Itoa(totalDisks),
+		})
+	}
+	return width, nil
 }
 
 // readEncodedData -
-func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutObjectMetadata map[string]string) {
+func (b bucket) readEncodedData(readers []io.ReadCloser, writer *io.PipeWriter, donutObjectMetadata map[string]string) {
+	defer func() {
+		for _, reader := range readers {
+			if reader != nil {
+				reader.Close()
+			}
+		}
+	}()
 	expectedMd5sum, err := hex.DecodeString(donutObjectMetadata["sys.md5"])
 	if err != nil {
 		writer.CloseWithError(iodine.New(err, nil))
 		return
 	}
-	readers, err := b.getDiskReaders(objectName, "data")
-	if err != nil {
-		writer.CloseWithError(iodine.New(err, nil))
-		return
-	}
 	hasher := md5.New()
 	mwriter := io.MultiWriter(writer, hasher)
 	switch len(readers) == 1 {
@@ -183,8 +390,16 @@ func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutO
 			writer.CloseWithError(iodine.New(err, nil))
 			return
 		}
+		checksummed := donutObjectMetadata["sys.blockChecksum"] == blockChecksumCRC32C
+		blockLens, err := getEncodedBlockLens(encoder, totalLeft, blockSize, totalChunks)
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		slices := newSliceReaders(readers, blockLens, checksummed)
+		defer slices.close()
 		for i := 0; i < totalChunks; i++ {
-			decodedData, err := b.decodeEncodedData(totalLeft, blockSize, readers, encoder, writer)
+			decodedData, err := b.decodeEncodedData(totalLeft, blockSize, slices, readers, encoder)
 			if err != nil {
 				writer.CloseWithError(iodine.New(err, nil))
 				return
@@ -197,7 +412,11 @@ func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutO
 			totalLeft = totalLeft - int64(blockSize)
 		}
 	case true:
-		_, err := io.Copy(writer, readers[0])
+		if readers[0] == nil {
+			writer.CloseWithError(iodine.New(errors.New("object slice missing"), nil))
+			return
+		}
+		_, err := io.Copy(mwriter, readers[0])
 		if err != nil {
 			writer.CloseWithError(iodine.New(err, nil))
 			return
@@ -213,26 +432,175 @@ func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutO
 	return
 }
 
-// decodeEncodedData -
-func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadCloser, encoder Encoder, writer *io.PipeWriter) ([]byte, error) {
+// readEncodedRange - decode length bytes of an object starting at start. Every slice is
+// positioned at the first chunk covering the range, chunks past the range are never read.
+// Blocks are verified against their checksums, the md5sum of the object cannot be verified
+func (b bucket) readEncodedRange(readers []io.ReadCloser, writer *io.PipeWriter, donutObjectMetadata map[string]string, start, length int64) {
+	defer closeObjectReaders(readers)
+	if length == 0 {
+		writer.Close()
+		return
+	}
+	switch len(readers) == 1 {
+	case false:
+		_, size, blockSize, k, m, err := b.donutMetadata2Values(donutObjectMetadata)
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		technique, ok := donutObjectMetadata["sys.erasureTechnique"]
+		if !ok {
+			err := errors.New("missing erasure Technique")
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		encoder, err := NewEncoder(uint8(k), uint8(m), technique)
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		checksummed := donutObjectMetadata["sys.blockChecksum"] == blockChecksumCRC32C
+		// all chunks but the last one are encoded from a whole block
+		encodedBlockLen, err := encoder.GetEncodedBlockLen(int(blockSize))
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		if checksummed {
+			encodedBlockLen = encodedBlockLen + blockChecksumSize
+		}
+		startChunk := start / blockSize
+		endChunk := (start + length - 1) / blockSize
+		for i, reader := range readers {
+			if reader == nil {
+				continue
+			}
+			if err := skipSliceData(reader, startChunk*int64(encodedBlockLen)); err != nil {
+				log.Error.Println(iodine.New(errors.New("degraded read, object slice unreadable"), map[string]string{
+					"bucket": b.name,
+					"slice":  strconv.Itoa(i),
+					"error":  err.Error(),
+				}))
+				reader.Close()
+				readers[i] = nil
+			}
+		}
+		totalLeft := size - startChunk*blockSize
+		skip := start - startChunk*blockSize
+		remaining := length
+		blockLens, err := getEncodedBlockLens(encoder, totalLeft, blockSize, int(endChunk-startChunk+1))
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		slices := newSliceReaders(readers, blockLens, checksummed)
+		defer slices.close()
+		for chunk := startChunk; chunk <= endChunk; chunk++ {
+			decodedData, err := b.decodeEncodedData(totalLeft, blockSize, slices, readers, encoder)
+			if err != nil {
+				writer.CloseWithError(iodine.New(err, nil))
+				return
+			}
+			decodedData = decodedData[skip:]
+			skip = 0
+			if int64(len(decodedData)) > remaining {
+				decodedData = decodedData[:remaining]
+			}
+			if _, err := writer.Write(decodedData); err != nil {
+				writer.CloseWithError(iodine.New(err, nil))
+				return
+			}
+			remaining = remaining - int64(len(decodedData))
+			totalLeft = totalLeft - blockSize
+		}
+	case true:
+		if readers[0] == nil {
+			writer.CloseWithError(iodine.New(errors.New("object slice missing"), nil))
+			return
+		}
+		if err := skipSliceData(readers[0], start); err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		if _, err := io.CopyN(writer, readers[0], length); err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+	}
+	writer.Close()
+}
+
+// skipSliceData - position a slice reader offset bytes into the slice, seeking if possible
+func skipSliceData(reader io.Reader, offset int64) error {
+	if seeker, ok := reader.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, os.SEEK_SET); err != nil {
+			return iodine.New(err, nil)
+		}
+		return nil
+	}
+	if _, err := io.CopyN(ioutil.Discard, reader, offset); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// getEncodedBlockLens - length of the encoded blocks of the next chunks, totalLeft bytes of object
+// data are left to be decoded
+func getEncodedBlockLens(encoder Encoder, totalLeft, blockSize int64, chunks int) ([]int, error) {
+	blockLens := make([]int, 0, chunks)
+	for i := 0; i < chunks && totalLeft > 0; i++ {
+		curBlockSize := blockSize
+		if totalLeft < blockSize {
+			curBlockSize = totalLeft
+		}
+		blockLen, err := encoder.GetEncodedBlockLen(int(curBlockSize))
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		blockLens = append(blockLens, blockLen)
+		totalLeft = totalLeft - curBlockSize
+	}
+	return blockLens, nil
+}
+
+// decodeEncodedData - decode the next chunk from blocks read ahead by every slice
+func (b bucket) decodeEncodedData(totalLeft, blockSize int64, slices *sliceReaders, readers []io.ReadCloser, encoder Encoder) ([]byte, error) {
 	var curBlockSize int64
 	if blockSize < totalLeft {
 		curBlockSize = blockSize
 	} else {
 		curBlockSize = totalLeft // cast is safe, blockSize in if protects
 	}
-	curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
-	if err != nil {
-		return nil, iodine.New(err, nil)
-	}
+	// missing slices, slices failing to read and blocks failing their checksum are passed
+	// to the decoder as erasures
 	encodedBytes := make([][]byte, len(readers))
 	for i, reader := range readers {
-		var bytesBuffer bytes.Buffer
-		_, err := io.CopyN(&bytesBuffer, reader, int64(curChunkSize))
-		if err != nil {
-			return nil, iodine.New(err, nil)
+		if reader == nil {
+			continue
 		}
-		encodedBytes[i] = bytesBuffer.Bytes()
+		block, ok := slices.next(i)
+		if !ok {
+			block.err = io.ErrUnexpectedEOF
+		}
+		if iodine.ToError(block.err) == errBitrot {
+			log.Error.Println(iodine.New(block.err, map[string]string{
+				"bucket": b.name,
+				"slice":  strconv.Itoa(i),
+			}))
+			continue
+		}
+		if block.err != nil {
+			log.Error.Println(iodine.New(errors.New("degraded read, object slice unreadable"), map[string]string{
+				"bucket": b.name,
+				"slice":  strconv.Itoa(i),
+				"error":  block.err.Error(),
+			}))
+			slices.drop(i)
+			reader.Close()
+			readers[i] = nil
+			continue
+		}
+		encodedBytes[i] = block.block
 	}
 	decodedData, err := encoder.Decode(encodedBytes, int(curBlockSize))
 	if err != nil {
@@ -241,6 +609,41 @@ func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadC
 	return decodedData, nil
 }
 
+// writeEncodedBlock - write an encoded block prefixed with its crc32c checksum
+func writeEncodedBlock(writer io.Writer, block []byte) error {
+	checksum := make([]byte, blockChecksumSize)
+	binary.BigEndian.PutUint32(checksum, crc32c.Sum32(block))
+	if _, err := writer.Write(checksum); err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := io.Copy(writer, bytes.NewBuffer(block)); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// readEncodedBlock - read an encoded block of blockLen bytes, verifying its checksum if
+// checksummed. A block failing its checksum returns errBitrot, the reader is still
+// positioned at the next block
+func readEncodedBlock(reader io.Reader, blockLen int, checksummed bool) ([]byte, error) {
+	if !checksummed {
+		var bytesBuffer bytes.Buffer
+		if _, err := io.CopyN(&bytesBuffer, reader, int64(blockLen)); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		return bytesBuffer.Bytes(), nil
+	}
+	buffer := make([]byte, blockChecksumSize+blockLen)
+	if _, err := io.ReadFull(reader, buffer); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	block := buffer[blockChecksumSize:]
+	if binary.BigEndian.Uint32(buffer[:blockChecksumSize]) != crc32c.Sum32(block) {
+		return nil, iodine.New(errBitrot, nil)
+	}
+	return block, nil
+}
+
 // donutMetadata2Values -
 func (b bucket) donutMetadata2Values(donutObjectMetadata map[string]string) (totalChunks int, totalLeft, blockSize int64, k, m uint64, err error) {
 	totalChunks, err = strconv.Atoi(donutObjectMetadata["sys.chunkCount"])
@@ -266,50 +669,298 @@ func (b bucket) donutMetadata2Values(donutObjectMetadata map[string]string) (tot
 	return totalChunks, totalLeft, blockSize, k, m, nil
 }
 
-// getDiskReaders -
-func (b bucket) getDiskReaders(objectName, objectMeta string) ([]io.ReadCloser, error) {
-	var readers []io.ReadCloser
-	nodeSlice := 0
-	for _, node := range b.nodes {
-		disks, err := node.ListDisks()
+// getDiskReaders - readers for an object on every disk of its erasure set, slices which cannot be
+// opened are left nil
+func (b bucket) getDiskReaders(set erasureSet, objectName, objectMeta string) ([]io.ReadCloser, error) {
+	readers := make([]io.ReadCloser, len(set.disks))
+	for i, setDisk := range set.disks {
+		objectPath := path.Join(b.donutName, setDisk.getBucketSlice(b.name), objectName, objectMeta)
+		objectSlice, err := setDisk.disk.OpenFile(objectPath)
 		if err != nil {
-			return nil, iodine.New(err, nil)
+			continue
 		}
-		readers = make([]io.ReadCloser, len(disks))
-		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
-			objectPath := path.Join(b.donutName, bucketSlice, objectName, objectMeta)
-			objectSlice, err := disk.OpenFile(objectPath)
-			if err != nil {
-				return nil, iodine.New(err, nil)
+		readers[i] = objectSlice
+	}
+	return readers, nil
+}
+
+// newStagingName - unique name for staging an object on every disk
+func newStagingName() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// getStagingWriters - writers for an object being staged, outside of the bucket on every disk of
+// its erasure set in healthySlices, or every disk if nil. Disks failing to create their file are left nil
+func (b bucket) getStagingWriters(set erasureSet, stagingName, objectMeta string, healthySlices []bool) ([]io.WriteCloser, error) {
+	writers := make([]io.WriteCloser, len(set.disks))
+	for i, setDisk := range set.disks {
+		if healthySlices != nil && (i >= len(healthySlices) || !healthySlices[i]) {
+			continue
+		}
+		objectSlice, err := setDisk.disk.MakeFile(path.Join(stagingDir, stagingName, objectMeta))
+		if err != nil {
+			log.Error.Println(iodine.New(err, map[string]string{"bucket": b.name, "disk": setDisk.disk.GetPath()}))
+			continue
+		}
+		writers[i] = objectSlice
+	}
+	return writers, nil
+}
+
+// commitStagedObject - swap staged object into its bucket slice on every disk holding a healthy
+// slice, stale slices of an existing object are removed from the remaining disks once every
+// slice is swapped in. The staged object is marked committing first, renames failing are retried
+// from the staged copies left next to the markers. A commit reaching write quorum drops slices
+// still failing, otherwise the markers are kept and the next heal or startup rolls it forward,
+// so readers never see a mix of old and new slices afterwards
+func (b bucket) commitStagedObject(set erasureSet, stagingName, objectName string, healthySlices []bool, writeQuorum int) error {
+	if err := b.writeCommitMarkers(set, stagingName, objectName, healthySlices, false); err != nil {
+		return iodine.New(err, nil)
+	}
+	pending := make(map[int]error)
+	for i := range set.disks {
+		if i < len(healthySlices) && healthySlices[i] {
+			pending[i] = nil
+		}
+	}
+	committed := 0
+	for attempt := 0; attempt < commitRetries && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * commitRetryDelay)
+		}
+		for i := range pending {
+			setDisk := set.disks[i]
+			objectPath := path.Join(b.donutName, setDisk.getBucketSlice(b.name), objectName)
+			if err := commitStagedSlice(setDisk.disk, path.Join(stagingDir, stagingName), objectPath); err != nil {
+				pending[i] = err
+				continue
 			}
-			readers[disk.GetOrder()] = objectSlice
+			delete(pending, i)
+			committed = committed + 1
 		}
-		nodeSlice = nodeSlice + 1
 	}
-	return readers, nil
+	if committed < writeQuorum {
+		return iodine.New(errors.New("commit incomplete, rolled forward by heal or on startup"), map[string]string{
+			"bucket":      b.name,
+			"object":      objectName,
+			"committed":   strconv.Itoa(committed),
+			"writeQuorum": strconv.Itoa(writeQuorum),
+		})
+	}
+	// an old slice left on a disk failing its rename is recorded as healthy by the new metadata,
+	// it must be gone before the commit completes, heal reconstructs the slice later
+	for i, err := range pending {
+		log.Error.Println(iodine.New(err, map[string]string{"bucket": b.name, "disk": set.disks[i].disk.GetPath()}))
+		objectPath := path.Join(b.donutName, set.disks[i].getBucketSlice(b.name), objectName)
+		if err := set.disks[i].disk.RemoveAll(objectPath); err != nil {
+			return iodine.New(errors.New("commit incomplete, rolled forward by heal or on startup"), map[string]string{
+				"bucket": b.name,
+				"object": objectName,
+				"disk":   set.disks[i].disk.GetPath(),
+			})
+		}
+	}
+	for i, setDisk := range set.disks {
+		if i < len(healthySlices) && healthySlices[i] {
+			continue
+		}
+		// best effort, reads and heal ignore slices not recorded as healthy
+		setDisk.disk.RemoveAll(path.Join(b.donutName, setDisk.getBucketSlice(b.name), objectName))
+	}
+	// indexed before the markers are removed, so that recovery indexes it otherwise
+	if err := b.indexCommittedObject(set, objectName); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := b.removeCommitMarkers(stagingName); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// commitStagedSlice - swap a staged slice into place on a single disk, an existing slice is moved
+// aside first and removed once the new one is in place
+func commitStagedSlice(disk Disk, stagingPath, objectPath string) error {
+	oldObjectPath := stagingPath + ".old"
+	if err := disk.Rename(objectPath, oldObjectPath); err != nil && !os.IsNotExist(iodine.ToError(err)) {
+		return iodine.New(err, nil)
+	}
+	if err := disk.Rename(stagingPath, objectPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := disk.RemoveAll(oldObjectPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// removeStagedObject - remove leftovers of a staged object from every disk, an object marked
+// committing is left for its commit to be completed on startup
+func (b bucket) removeStagedObject(stagingName string) {
+	if b.isCommitMarked(stagingName) {
+		log.Error.Println(iodine.New(errors.New("commit incomplete, completed on startup"), map[string]string{
+			"bucket":  b.name,
+			"staging": stagingName,
+		}))
+		return
+	}
+	donutDisks, err := getDonutDisks(b.nodes())
+	if err != nil {
+		return
+	}
+	for _, setDisk := range donutDisks {
+		setDisk.disk.RemoveAll(path.Join(stagingDir, stagingName))
+		setDisk.disk.RemoveAll(path.Join(stagingDir, stagingName+".old"))
+	}
+}
+
+// commitMarker - written next to a staged object before it is swapped into its bucket,
+// a swap interrupted by a crash is completed from it on startup. Healed slices of an object
+// leave its index as it is
+type commitMarker struct {
+	Bucket string
+	Object string
+	Healed bool `json:",omitempty"`
 }
 
-// getDiskWriters -
-func (b bucket) getDiskWriters(objectName, objectMeta string) ([]io.WriteCloser, error) {
-	var writers []io.WriteCloser
-	nodeSlice := 0
-	for _, node := range b.nodes {
-		disks, err := node.ListDisks()
+// writeCommitMarkers - mark a staged object as committing on every disk holding a healthy slice
+func (b bucket) writeCommitMarkers(set erasureSet, stagingName, objectName string, healthySlices []bool, healed bool) error {
+	marker := commitMarker{Bucket: b.name, Object: objectName, Healed: healed}
+	for i, setDisk := range set.disks {
+		if i >= len(healthySlices) || !healthySlices[i] {
+			continue
+		}
+		writer, err := setDisk.disk.MakeFile(path.Join(stagingDir, stagingName+commitMarkerSuffix))
 		if err != nil {
-			return nil, iodine.New(err, nil)
+			return iodine.New(err, nil)
 		}
-		writers = make([]io.WriteCloser, len(disks))
-		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
-			objectPath := path.Join(b.donutName, bucketSlice, objectName, objectMeta)
-			objectSlice, err := disk.MakeFile(objectPath)
-			if err != nil {
-				return nil, iodine.New(err, nil)
+		jenc := json.NewEncoder(writer)
+		if err := jenc.Encode(marker); err != nil {
+			writer.Close()
+			return iodine.New(err, nil)
+		}
+		if err := syncAndClose(writer); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// readCommitMarker - read and decode a commit marker from a single disk
+func readCommitMarker(disk Disk, markerName string) (commitMarker, error) {
+	var marker commitMarker
+	reader, err := disk.OpenFile(path.Join(stagingDir, markerName))
+	if err != nil {
+		return marker, iodine.New(err, nil)
+	}
+	defer reader.Close()
+	jdec := json.NewDecoder(reader)
+	if err := jdec.Decode(&marker); err != nil {
+		return marker, iodine.New(err, nil)
+	}
+	return marker, nil
+}
+
+// RecoverStagedObjects - complete swapping in objects of this bucket interrupted by a crash,
+// slices still staged next to a commit marker are swapped in
+func (b bucket) RecoverStagedObjects() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	markers := make(map[string]commitMarker)
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	donutDisks, err := getDonutDisks(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, setDisk := range donutDisks {
+		files, err := setDisk.disk.ListFiles(stagingDir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), commitMarkerSuffix) {
+				continue
+			}
+			stagingName := strings.TrimSuffix(file.Name(), commitMarkerSuffix)
+			if _, ok := markers[stagingName]; ok {
+				continue
+			}
+			marker, err := readCommitMarker(setDisk.disk, file.Name())
+			if err != nil || marker.Bucket != b.name {
+				continue
 			}
-			writers[disk.GetOrder()] = objectSlice
+			markers[stagingName] = marker
 		}
-		nodeSlice = nodeSlice + 1
 	}
-	return writers, nil
+	ring := newPlacementRing(sets)
+	for stagingName, marker := range markers {
+		// staged on the erasure set the object is placed on, indexed there even if all its slices
+		// were swapped in before the crash
+		placed := ring.getErasureSet(b.name, marker.Object)
+		for _, set := range sets {
+			committed := set.id == placed
+			for _, setDisk := range set.disks {
+				stagingPath := path.Join(stagingDir, stagingName)
+				// slices swapped in before the crash have no staged copy left
+				if _, err := setDisk.disk.ListFiles(stagingPath); err != nil {
+					continue
+				}
+				objectPath := path.Join(b.donutName, setDisk.getBucketSlice(b.name), marker.Object)
+				commit := commitStagedSlice
+				if marker.Healed {
+					commit = commitHealedSlice
+				}
+				if err := commit(setDisk.disk, stagingPath, objectPath); err != nil {
+					return iodine.New(err, nil)
+				}
+				committed = true
+			}
+			if !committed || marker.Healed {
+				continue
+			}
+			if err := b.indexCommittedObject(set, marker.Object); err != nil {
+				return iodine.New(err, nil)
+			}
+		}
+		b.removeCommitMarkers(stagingName)
+		b.removeStagedObject(stagingName)
+	}
+	return nil
+}
+
+// removeCommitMarkers - remove commit markers of a staged object from every disk
+func (b bucket) removeCommitMarkers(stagingName string) error {
+	donutDisks, err := getDonutDisks(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, setDisk := range donutDisks {
+		// failed disks never carried a marker
+		if err := setDisk.disk.RemoveAll(path.Join(stagingDir, stagingName+commitMarkerSuffix)); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+	}
+	return nil
+}
+
+// isCommitMarked - verify if a staged object is marked committing on any disk
+func (b bucket) isCommitMarked(stagingName string) bool {
+	donutDisks, err := getDonutDisks(b.nodes())
+	if err != nil {
+		return false
+	}
+	for _, setDisk := range donutDisks {
+		reader, err := setDisk.disk.OpenFile(path.Join(stagingDir, stagingName+commitMarkerSuffix))
+		if err == nil {
+			reader.Close()
+			return true
+		}
+	}
+	return false
 }
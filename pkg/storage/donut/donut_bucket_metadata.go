@@ -0,0 +1,249 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path"
+	"strconv"
+
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+// donutBucketMetadata - metadata of all buckets, kept on every disk. Generation increments with
+// every update, copies of an older generation are stale
+type donutBucketMetadata struct {
+	Version    string
+	Generation int64
+	// disks the generation was written to, zero for copies written before they were counted
+	Disks   int `json:",omitempty"`
+	Buckets map[string]map[string]string
+}
+
+// newDonutBucketMetadata - metadata of a donut without buckets
+func newDonutBucketMetadata() donutBucketMetadata {
+	return donutBucketMetadata{
+		Version: "1.0",
+		Buckets: make(map[string]map[string]string),
+	}
+}
+
+// readDonutBucketMetadata - read and decode bucket metadata from a single disk. Metadata written
+// before it was versioned is the bare map of buckets, read as generation zero
+func readDonutBucketMetadata(disk Disk, donutName string) (donutBucketMetadata, error) {
+	reader, err := disk.OpenFile(path.Join(donutName, bucketMetadataConfig))
+	if err != nil {
+		return donutBucketMetadata{}, iodine.New(err, nil)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return donutBucketMetadata{}, iodine.New(err, nil)
+	}
+	metadata := newDonutBucketMetadata()
+	if err := json.Unmarshal(data, &metadata); err == nil && metadata.Version != "" && metadata.Buckets != nil {
+		return metadata, nil
+	}
+	metadata = newDonutBucketMetadata()
+	if err := json.Unmarshal(data, &metadata.Buckets); err != nil {
+		return donutBucketMetadata{}, iodine.New(err, nil)
+	}
+	return metadata, nil
+}
+
+// writeDonutBucketMetadata - encode bucket metadata on a single disk, staged and synced first then
+// swapped in by rename so a crash never leaves a partial copy behind
+func writeDonutBucketMetadata(disk Disk, donutName string, metadata donutBucketMetadata) error {
+	if err := writeConfigFile(disk, path.Join(donutName, bucketMetadataConfig), metadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// bucketMetadataQuorum - bucket metadata agreed on by most disks, along with the disks whose
+// copy is missing, unreadable or differs
+type bucketMetadataQuorum struct {
+	metadata   donutBucketMetadata
+	votes      int
+	totalDisks int
+	staleDisks []Disk
+}
+
+// readBucketMetadataQuorum - read bucket metadata from every disk, copies are compared by their
+// generation and content. The copy held by most disks wins, the newer generation on a tie
+func (d donut) readBucketMetadataQuorum() (bucketMetadataQuorum, error) {
+	donutDisks, err := getDonutDisks(d.getNodes())
+	if err != nil {
+		return bucketMetadataQuorum{}, iodine.New(err, nil)
+	}
+	quorum := bucketMetadataQuorum{
+		metadata:   newDonutBucketMetadata(),
+		totalDisks: len(donutDisks),
+	}
+	copies := make(map[string]donutBucketMetadata)
+	votes := make(map[string]int)
+	diskCopies := make([]string, len(donutDisks))
+	for i, setDisk := range donutDisks {
+		metadata, err := readDonutBucketMetadata(setDisk.disk, d.name)
+		if err != nil {
+			continue
+		}
+		buckets, err := json.Marshal(metadata.Buckets)
+		if err != nil {
+			continue
+		}
+		key := strconv.FormatInt(metadata.Generation, 10) + "$" + string(buckets)
+		copies[key] = metadata
+		votes[key] = votes[key] + 1
+		diskCopies[i] = key
+	}
+	winner := ""
+	for key, metadata := range copies {
+		if winner == "" || votes[key] > votes[winner] ||
+			(votes[key] == votes[winner] && metadata.Generation > copies[winner].Generation) {
+			winner = key
+		}
+	}
+	// no bucket was ever made
+	if winner == "" {
+		return quorum, nil
+	}
+	quorum.metadata = copies[winner]
+	quorum.votes = votes[winner]
+	for i, setDisk := range donutDisks {
+		if diskCopies[i] != winner {
+			quorum.staleDisks = append(quorum.staleDisks, setDisk.disk)
+		}
+	}
+	return quorum, nil
+}
+
+// hasReadQuorum - true if a majority of the disks the bucket metadata was written to agree on it,
+// an update rejected short of write quorum is never read back. Disks attached since carry no copy
+func (q bucketMetadataQuorum) hasReadQuorum() bool {
+	disks := q.metadata.Disks
+	if disks == 0 || disks > q.totalDisks {
+		disks = q.totalDisks
+	}
+	return q.votes == 0 || q.votes >= disks/2+1
+}
+
+// repairBucketMetadata - rewrite stale copies of bucket metadata with the one agreed on, disks
+// failing to be written are left for the next repair
+func (d donut) repairBucketMetadata(quorum bucketMetadataQuorum) int {
+	repaired := 0
+	for _, disk := range quorum.staleDisks {
+		if err := writeDonutBucketMetadata(disk, d.name, quorum.metadata); err == nil {
+			repaired = repaired + 1
+		}
+	}
+	return repaired
+}
+
+// getDonutBucketMetadata - bucket metadata agreed on by the disks, stale copies are repaired on
+// the way. Empty if no bucket was ever made
+func (d donut) getDonutBucketMetadata() (map[string]map[string]string, error) {
+	d.bucketMetadataLock.RLock()
+	quorum, err := d.readBucketMetadataQuorum()
+	d.bucketMetadataLock.RUnlock()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if len(quorum.staleDisks) > 0 {
+		// read again, an update may have been written in between
+		d.bucketMetadataLock.Lock()
+		defer d.bucketMetadataLock.Unlock()
+		quorum, err = d.readBucketMetadataQuorum()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		if !quorum.hasReadQuorum() {
+			return nil, iodine.New(errors.New("bucket metadata read quorum not reached"), map[string]string{
+				"votes": strconv.Itoa(quorum.votes),
+				"disks": strconv.Itoa(quorum.totalDisks),
+			})
+		}
+		d.repairBucketMetadata(quorum)
+	}
+	return quorum.metadata.Buckets, nil
+}
+
+// updateDonutBucketMetadata - apply update to the bucket metadata agreed on by the disks and write
+// it as the next generation on every disk. Updates are serialized, the write needs a majority of
+// all disks to succeed
+func (d donut) updateDonutBucketMetadata(update func(buckets map[string]map[string]string) error) error {
+	d.bucketMetadataLock.Lock()
+	defer d.bucketMetadataLock.Unlock()
+	quorum, err := d.readBucketMetadataQuorum()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	errParams := map[string]string{
+		"votes": strconv.Itoa(quorum.votes),
+		"disks": strconv.Itoa(quorum.totalDisks),
+	}
+	if !quorum.hasReadQuorum() {
+		return iodine.New(errors.New("bucket metadata read quorum not reached"), errParams)
+	}
+	metadata := quorum.metadata
+	if err := update(metadata.Buckets); err != nil {
+		return iodine.New(err, nil)
+	}
+	donutDisks, err := getDonutDisks(d.getNodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	metadata.Version = "1.0"
+	metadata.Generation = metadata.Generation + 1
+	metadata.Disks = len(donutDisks)
+	written := 0
+	for _, setDisk := range donutDisks {
+		if err := writeDonutBucketMetadata(setDisk.disk, d.name, metadata); err == nil {
+			written = written + 1
+		}
+	}
+	// disks missing the update are stale, repaired on a later read
+	if written < len(donutDisks)/2+1 {
+		errParams["written"] = strconv.Itoa(written)
+		return iodine.New(errors.New("bucket metadata write quorum not reached"), errParams)
+	}
+	return nil
+}
+
+// healDonutBucketMetadata - rewrite bucket metadata on disks where it is missing, unreadable or
+// stale. Copies left are trusted even without read quorum, there is nothing else to heal from
+func (d donut) healDonutBucketMetadata() error {
+	d.bucketMetadataLock.Lock()
+	defer d.bucketMetadataLock.Unlock()
+	quorum, err := d.readBucketMetadataQuorum()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// no buckets were ever made, or nothing left to heal from
+	if quorum.votes == 0 {
+		return nil
+	}
+	if repaired := d.repairBucketMetadata(quorum); repaired < len(quorum.staleDisks) {
+		return iodine.New(errors.New("unable to heal bucket metadata on all disks"), map[string]string{
+			"stale":    strconv.Itoa(len(quorum.staleDisks)),
+			"repaired": strconv.Itoa(repaired),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	encoding "github.com/minio-io/minio/pkg/erasure"
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+// ErrStorageFull - a disk an object is written to has no room left for its slice
+var ErrStorageFull = errors.New("storage full, not enough free space left on disk")
+
+// defaultDiskReserve - percentage of every disk kept free unless configured otherwise
+const defaultDiskReserve = 5
+
+// diskReserve - percentage of every disk writes are not allowed to fill, shared by all buckets
+type diskReserve struct {
+	lock    *sync.RWMutex
+	percent int
+}
+
+// newDiskReserve - instantiate a reserve of the default percentage
+func newDiskReserve() *diskReserve {
+	return &diskReserve{
+		lock:    new(sync.RWMutex),
+		percent: defaultDiskReserve,
+	}
+}
+
+// get - current reserve percentage
+func (r *diskReserve) get() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.percent
+}
+
+// set - change the reserve percentage, at most 99 leaving some room to write into
+func (r *diskReserve) set(percent int) error {
+	if percent < 0 || percent > 99 {
+		return iodine.New(errors.New("invalid argument"), map[string]string{"percent": strconv.Itoa(percent)})
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.percent = percent
+	return nil
+}
+
+// getDiskSpace - total and available bytes of a disk, false if the disk did not report them
+func getDiskSpace(disk Disk) (int64, int64, bool) {
+	fsInfo := disk.GetFSInfo()
+	if fsInfo == nil {
+		return 0, 0, false
+	}
+	total, err := strconv.ParseInt(fsInfo["TotalBytes"], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	available, err := strconv.ParseInt(fsInfo["AvailableBytes"], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return total, available, true
+}
+
+// getSliceSize - bytes written to every disk for an object of size encoded with k, m. Every
+// block is encoded on its own, prefixed with its checksum
+func getSliceSize(size int64, k, m uint8) int64 {
+	blockSize := int64(getBlockSize(k))
+	fullBlocks := size / blockSize
+	lastBlock := size % blockSize
+	blockCount := fullBlocks
+	sliceSize := fullBlocks * int64(encoding.GetEncodedBlocksLen(int(blockSize), k, m)/int(k+m))
+	if lastBlock > 0 {
+		blockCount = blockCount + 1
+		sliceSize = sliceSize + int64(encoding.GetEncodedBlocksLen(int(lastBlock), k, m)/int(k+m))
+	}
+	return sliceSize + blockCount*blockChecksumSize
+}
+
+// verifyFreeSpace - verify every disk of an erasure set has room for its slice of an object of
+// size written with a storage class, without eating into the reserve. A negative size is not
+// known upfront, only disks already inside their reserve are full. Disks not reporting their
+// usage are left to fail the write
+func (b bucket) verifyFreeSpace(set erasureSet, class string, size int64) error {
+	sc, err := getStorageClass(class)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	sliceSize := int64(0)
+	switch {
+	case size <= 0:
+	case len(set.disks) == 1:
+		sliceSize = size
+	default:
+		k, m, err := sc.getDataAndParity(len(set.disks), set.getNodeWidth())
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		sliceSize = getSliceSize(size, k, m)
+	}
+	reserve := int64(b.reserve.get())
+	for _, setDisk := range set.disks {
+		total, available, ok := getDiskSpace(setDisk.disk)
+		if !ok {
+			continue
+		}
+		if available-sliceSize < total*reserve/100 {
+			return iodine.New(ErrStorageFull, map[string]string{
+				"disk":      setDisk.disk.GetPath(),
+				"available": strconv.FormatInt(available, 10),
+				"required":  strconv.FormatInt(sliceSize, 10),
+				"reserve":   strconv.FormatInt(reserve, 10),
+			})
+		}
+	}
+	return nil
+}
@@ -17,10 +17,16 @@
 package donut
 
 import (
+	"crypto/rand"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"strconv"
 	"syscall"
+	"time"
 
 	"io/ioutil"
 
@@ -31,9 +37,22 @@ import (
 type disk struct {
 	root       string
 	order      int
+	id         string
 	filesystem map[string]string
 }
 
+// diskFormat - identity of a disk, written into its root when first attached to a donut
+type diskFormat struct {
+	Version string
+	DiskID  string
+	DonutID string
+	Donut   string
+	Node    string
+	Order   int
+	// disks formatted after the donut config was saved replace a missing disk
+	Formatted time.Time
+}
+
 // NewDisk - instantiate new disk
 func NewDisk(diskPath string, diskOrder int) (Disk, error) {
 	if diskPath == "" || diskOrder < 0 {
@@ -56,6 +75,13 @@ func NewDisk(diskPath string, diskOrder int) (Disk, error) {
 		order:      diskOrder,
 		filesystem: make(map[string]string),
 	}
+	format, err := readDiskFormat(d)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if format != nil {
+		d.id = format.DiskID
+	}
 	if fsType := d.getFSType(s.Type); fsType != "UNKNOWN" {
 		d.filesystem["FSType"] = fsType
 		d.filesystem["MountPoint"] = d.root
@@ -69,6 +95,11 @@ func (d disk) GetPath() string {
 	return d.root
 }
 
+// GetID - get unique id of disk from its format, empty if not formatted
+func (d disk) GetID() string {
+	return d.id
+}
+
 // GetOrder - get order of disk present in graph
 func (d disk) GetOrder() int {
 	return d.order
@@ -81,9 +112,17 @@ func (d disk) GetFSInfo() map[string]string {
 	if err != nil {
 		return nil
 	}
-	d.filesystem["Total"] = d.formatBytes(s.Bsize * int64(s.Blocks))
-	d.filesystem["Free"] = d.formatBytes(s.Bsize * int64(s.Bfree))
-	return d.filesystem
+	// a new map every time, usage is read by concurrent writes
+	fsInfo := make(map[string]string)
+	for k, v := range d.filesystem {
+		fsInfo[k] = v
+	}
+	fsInfo["Total"] = d.formatBytes(s.Bsize * int64(s.Blocks))
+	fsInfo["Free"] = d.formatBytes(s.Bsize * int64(s.Bfree))
+	// raw bytes for free space checks, available excludes blocks reserved for root
+	fsInfo["TotalBytes"] = strconv.FormatInt(s.Bsize*int64(s.Blocks), 10)
+	fsInfo["AvailableBytes"] = strconv.FormatInt(s.Bsize*int64(s.Bavail), 10)
+	return fsInfo
 }
 
 // MakeDir - make a directory inside disk root path
@@ -124,7 +163,7 @@ func (d disk) ListFiles(dirname string) ([]os.FileInfo, error) {
 }
 
 // MakeFile - create a file inside disk root path
-func (d disk) MakeFile(filename string) (*os.File, error) {
+func (d disk) MakeFile(filename string) (io.WriteCloser, error) {
 	if filename == "" {
 		return nil, iodine.New(errors.New("Invalid argument"), nil)
 	}
@@ -133,7 +172,7 @@ func (d disk) MakeFile(filename string) (*os.File, error) {
 	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	dataFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0600)
+	dataFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
@@ -141,7 +180,7 @@ func (d disk) MakeFile(filename string) (*os.File, error) {
 }
 
 // OpenFile - read a file inside disk root path
-func (d disk) OpenFile(filename string) (*os.File, error) {
+func (d disk) OpenFile(filename string) (io.ReadCloser, error) {
 	if filename == "" {
 		return nil, iodine.New(errors.New("Invalid argument"), nil)
 	}
@@ -151,3 +190,135 @@ func (d disk) OpenFile(filename string) (*os.File, error) {
 	}
 	return dataFile, nil
 }
+
+// AppendFile - open a file inside disk root path for appending, created if it does not exist
+func (d disk) AppendFile(filename string) (io.WriteCloser, error) {
+	if filename == "" {
+		return nil, iodine.New(errors.New("Invalid argument"), nil)
+	}
+	filePath := path.Join(d.root, filename)
+	// Create directories if they don't exist
+	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	dataFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return dataFile, nil
+}
+
+// Rename - rename a file or directory inside disk root path
+func (d disk) Rename(oldpath, newpath string) error {
+	if oldpath == "" || newpath == "" {
+		return iodine.New(errors.New("Invalid argument"), nil)
+	}
+	newFullPath := path.Join(d.root, newpath)
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(path.Dir(newFullPath), 0700); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(path.Join(d.root, oldpath), newFullPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	// make the rename durable, along with the entries of a renamed directory
+	if st, err := os.Stat(newFullPath); err == nil && st.IsDir() {
+		if err := syncDir(newFullPath); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := syncDir(path.Dir(newFullPath)); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// syncDir - flush directory entries to stable storage
+func syncDir(dirPath string) error {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// RemoveAll - remove a file or directory and its contents inside disk root path
+func (d disk) RemoveAll(filename string) error {
+	if filename == "" {
+		return iodine.New(errors.New("Invalid argument"), nil)
+	}
+	if err := os.RemoveAll(path.Join(d.root, filename)); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// readDiskFormat - read format of a disk, nil if the disk was never formatted
+func readDiskFormat(disk Disk) (*diskFormat, error) {
+	reader, err := disk.OpenFile(diskFormatConfig)
+	if err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return nil, nil
+		}
+		return nil, iodine.New(err, nil)
+	}
+	defer reader.Close()
+	format := new(diskFormat)
+	jdec := json.NewDecoder(reader)
+	if err := jdec.Decode(format); err != nil {
+		return nil, iodine.New(err, map[string]string{"disk": disk.GetPath()})
+	}
+	return format, nil
+}
+
+// writeDiskFormat - write format of a disk, replacing an existing format atomically
+func writeDiskFormat(disk Disk, format diskFormat) error {
+	if err := writeConfigFile(disk, diskFormatConfig, format); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// writeConfigFile - encode a config file on a disk, staged and synced first then swapped in by
+// rename so a crash never leaves a partial copy behind
+func writeConfigFile(disk Disk, filePath string, v interface{}) error {
+	stagingName, err := newStagingName()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	stagingPath := path.Join(stagingDir, stagingName)
+	writer, err := disk.MakeFile(stagingPath)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	jenc := json.NewEncoder(writer)
+	if err := jenc.Encode(v); err != nil {
+		writer.Close()
+		disk.RemoveAll(stagingPath)
+		return iodine.New(err, nil)
+	}
+	if err := syncAndClose(writer); err != nil {
+		disk.RemoveAll(stagingPath)
+		return iodine.New(err, nil)
+	}
+	if err := disk.Rename(stagingPath, filePath); err != nil {
+		disk.RemoveAll(stagingPath)
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// newUUID - random (version 4) UUID
+func newUUID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), nil
+}
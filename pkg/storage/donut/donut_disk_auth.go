@@ -0,0 +1,114 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+/// This file contains the authentication of requests to exported disks, signed by the client
+/// with an access key the disk server shares
+
+const (
+	diskAuthScheme = "MINIODISK"
+	diskDateHeader = "X-Minio-Disk-Date"
+	// requests signed longer ago than this are refused, bounding replays
+	diskAuthMaxSkew = 15 * time.Minute
+)
+
+// ErrDiskAuth - request to an exported disk is not signed with a shared access key
+var ErrDiskAuth = errors.New("disk request not authenticated")
+
+// diskCredentials - access key and secret key requests to remote disks are signed with
+type diskCredentials struct {
+	lock      *sync.RWMutex
+	accessKey string
+	secretKey string
+}
+
+var remoteDiskCredentials = diskCredentials{lock: new(sync.RWMutex)}
+
+// SetDiskCredentials - access key and secret key requests to remote disks are signed with,
+// the disk servers exporting them must share the key
+func SetDiskCredentials(accessKey, secretKey string) error {
+	if accessKey == "" || secretKey == "" {
+		return iodine.New(errors.New("invalid argument"), nil)
+	}
+	remoteDiskCredentials.lock.Lock()
+	defer remoteDiskCredentials.lock.Unlock()
+	remoteDiskCredentials.accessKey = accessKey
+	remoteDiskCredentials.secretKey = secretKey
+	return nil
+}
+
+// getDiskSignature - hex encoded HMAC-SHA256 over the method, date and request uri
+func getDiskSignature(secretKey, method, date, requestURI string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(method + "\n" + date + "\n" + requestURI))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signDiskRequest - sign a request to a remote disk with the configured credentials
+func signDiskRequest(req *http.Request) error {
+	remoteDiskCredentials.lock.RLock()
+	accessKey, secretKey := remoteDiskCredentials.accessKey, remoteDiskCredentials.secretKey
+	remoteDiskCredentials.lock.RUnlock()
+	if accessKey == "" {
+		return iodine.New(errors.New("remote disk credentials not set"), nil)
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set(diskDateHeader, date)
+	signature := getDiskSignature(secretKey, req.Method, date, req.URL.RequestURI())
+	req.Header.Set("Authorization", diskAuthScheme+" "+accessKey+":"+signature)
+	return nil
+}
+
+// verifyDiskRequest - verify a request is signed by one of the access keys shared with the server
+// and was signed recently
+func verifyDiskRequest(req *http.Request, credentials map[string]string) error {
+	auth := strings.TrimPrefix(req.Header.Get("Authorization"), diskAuthScheme+" ")
+	colon := strings.Index(auth, ":")
+	if colon <= 0 {
+		return iodine.New(ErrDiskAuth, nil)
+	}
+	secretKey, ok := credentials[auth[:colon]]
+	if !ok {
+		return iodine.New(ErrDiskAuth, nil)
+	}
+	date := req.Header.Get(diskDateHeader)
+	signed, err := time.Parse(http.TimeFormat, date)
+	if err != nil {
+		return iodine.New(ErrDiskAuth, nil)
+	}
+	if skew := time.Since(signed); skew > diskAuthMaxSkew || skew < -diskAuthMaxSkew {
+		return iodine.New(ErrDiskAuth, map[string]string{"date": date})
+	}
+	signature := getDiskSignature(secretKey, req.Method, date, req.URL.RequestURI())
+	if !hmac.Equal([]byte(signature), []byte(auth[colon+1:])) {
+		return iodine.New(ErrDiskAuth, nil)
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+/// This file contains the client side of disks exported by another minio process, see NewDiskServer
+
+const (
+	remoteDiskDialTimeout = 10 * time.Second
+	// time a disk has to reply once a request is sent, files written are synced before replying
+	remoteDiskResponseTimeout = 60 * time.Second
+	// time a connection may sit without reading or writing anything, a stalled slice fails
+	// instead of blocking the whole object
+	remoteDiskIdleTimeout = 30 * time.Second
+)
+
+// remoteDiskClient - shared by all remote disks, every slice of an object streams over its
+// own connection. Timeouts fail the slice, reads and writes carry on with the others
+var remoteDiskClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		Dial:                  dialRemoteDisk,
+		ResponseHeaderTimeout: remoteDiskResponseTimeout,
+		IdleConnTimeout:       remoteDiskIdleTimeout,
+		MaxIdleConnsPerHost:   64,
+	},
+}
+
+// dialRemoteDisk - connect to a disk server, every read and write on the connection must make
+// progress within the idle timeout
+func dialRemoteDisk(network, address string) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, address, remoteDiskDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return idleTimeoutConn{Conn: conn}, nil
+}
+
+// idleTimeoutConn - connection failing reads and writes which stall for the idle timeout
+type idleTimeoutConn struct {
+	net.Conn
+}
+
+func (c idleTimeoutConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(remoteDiskIdleTimeout))
+	return c.Conn.Read(p)
+}
+
+func (c idleTimeoutConn) Write(p []byte) (int, error) {
+	c.Conn.SetWriteDeadline(time.Now().Add(remoteDiskIdleTimeout))
+	return c.Conn.Write(p)
+}
+
+// remote disk struct
+type remoteDisk struct {
+	address string
+	root    string
+	order   int
+	id      string
+}
+
+// remoteFileInfo - os.FileInfo of a file on a remote disk
+type remoteFileInfo struct {
+	FileName    string
+	FileSize    int64
+	FileMode    os.FileMode
+	FileModTime time.Time
+}
+
+func (f remoteFileInfo) Name() string       { return f.FileName }
+func (f remoteFileInfo) Size() int64        { return f.FileSize }
+func (f remoteFileInfo) Mode() os.FileMode  { return f.FileMode }
+func (f remoteFileInfo) ModTime() time.Time { return f.FileModTime }
+func (f remoteFileInfo) IsDir() bool        { return f.FileMode.IsDir() }
+func (f remoteFileInfo) Sys() interface{}   { return nil }
+
+// IsRemoteDisk - disks exported by other hosts are given as host:port/path
+func IsRemoteDisk(diskPath string) bool {
+	colon := strings.Index(diskPath, ":")
+	slash := strings.Index(diskPath, "/")
+	return colon > 0 && slash > colon
+}
+
+// OpenDisk - instantiate a local or a remote disk by its path
+func OpenDisk(diskPath string, diskOrder int) (Disk, error) {
+	if IsRemoteDisk(diskPath) {
+		slash := strings.Index(diskPath, "/")
+		return NewRemoteDisk(diskPath[:slash], diskPath[slash:], diskOrder)
+	}
+	return NewDisk(diskPath, diskOrder)
+}
+
+// NewRemoteDisk - instantiate a disk exported by the minio process listening on address
+func NewRemoteDisk(address, diskPath string, diskOrder int) (Disk, error) {
+	if address == "" || diskPath == "" || diskOrder < 0 {
+		return nil, iodine.New(errors.New("invalid argument"), nil)
+	}
+	d := remoteDisk{
+		address: address,
+		root:    diskPath,
+		order:   diskOrder,
+	}
+	// verify the disk is exported before using it
+	if _, err := d.getFSInfo(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	format, err := readDiskFormat(d)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if format != nil {
+		d.id = format.DiskID
+	}
+	return d, nil
+}
+
+// GetPath - get disk path, including the address it is exported on
+func (d remoteDisk) GetPath() string {
+	return d.address + d.root
+}
+
+// GetID - get unique id of disk from its format, empty if not formatted
+func (d remoteDisk) GetID() string {
+	return d.id
+}
+
+// GetOrder - get order of disk present in graph
+func (d remoteDisk) GetOrder() int {
+	return d.order
+}
+
+// GetFSInfo - get disk filesystem and its usage information
+func (d remoteDisk) GetFSInfo() map[string]string {
+	fsInfo, err := d.getFSInfo()
+	if err != nil {
+		return nil
+	}
+	return fsInfo
+}
+
+// getFSInfo - get disk filesystem and its usage information, fails if the disk is not exported
+func (d remoteDisk) getFSInfo() (map[string]string, error) {
+	var fsInfo map[string]string
+	if err := d.call("GET", "fsinfo", nil, &fsInfo); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return fsInfo, nil
+}
+
+// MakeDir - make a directory inside disk root path
+func (d remoteDisk) MakeDir(dirname string) error {
+	if err := d.call("PUT", "dir", url.Values{"path": {dirname}}, nil); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// ListDir - list a directory inside disk root path, get only directories
+func (d remoteDisk) ListDir(dirname string) ([]os.FileInfo, error) {
+	return d.list(dirname, "dirs")
+}
+
+// ListFiles - list a directory inside disk root path, get only files
+func (d remoteDisk) ListFiles(dirname string) ([]os.FileInfo, error) {
+	return d.list(dirname, "files")
+}
+
+// list - list directories or files of a directory
+func (d remoteDisk) list(dirname, only string) ([]os.FileInfo, error) {
+	var files []remoteFileInfo
+	if err := d.call("GET", "dir", url.Values{"path": {dirname}, "only": {only}}, &files); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var contents []os.FileInfo
+	for _, file := range files {
+		contents = append(contents, file)
+	}
+	return contents, nil
+}
+
+// MakeFile - create a file inside disk root path
+func (d remoteDisk) MakeFile(filename string) (io.WriteCloser, error) {
+	if filename == "" {
+		return nil, iodine.New(errors.New("Invalid argument"), nil)
+	}
+	return d.writeFile(url.Values{"path": {filename}}), nil
+}
+
+// AppendFile - open a file inside disk root path for appending, created if it does not exist
+func (d remoteDisk) AppendFile(filename string) (io.WriteCloser, error) {
+	if filename == "" {
+		return nil, iodine.New(errors.New("Invalid argument"), nil)
+	}
+	return d.writeFile(url.Values{"path": {filename}, "append": {"true"}}), nil
+}
+
+// OpenFile - read a file inside disk root path
+func (d remoteDisk) OpenFile(filename string) (io.ReadCloser, error) {
+	if filename == "" {
+		return nil, iodine.New(errors.New("Invalid argument"), nil)
+	}
+	reader := &remoteFileReader{disk: d, name: filename}
+	if err := reader.open(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return reader, nil
+}
+
+// Rename - rename a file or directory inside disk root path
+func (d remoteDisk) Rename(oldpath, newpath string) error {
+	if oldpath == "" || newpath == "" {
+		return iodine.New(errors.New("Invalid argument"), nil)
+	}
+	if err := d.call("POST", "rename", url.Values{"path": {oldpath}, "to": {newpath}}, nil); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// RemoveAll - remove a file or directory and its contents inside disk root path
+func (d remoteDisk) RemoveAll(filename string) error {
+	if filename == "" {
+		return iodine.New(errors.New("Invalid argument"), nil)
+	}
+	if err := d.call("DELETE", "file", url.Values{"path": {filename}}, nil); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// getURL - url of an operation on the disk
+func (d remoteDisk) getURL(op string, params url.Values) string {
+	if params == nil {
+		params = make(url.Values)
+	}
+	params.Set("disk", d.root)
+	return "http://" + d.address + diskServerPath + op + "?" + params.Encode()
+}
+
+// call - call an operation on the disk, decoding the JSON response into result if not nil
+func (d remoteDisk) call(method, op string, params url.Values, result interface{}) error {
+	req, err := http.NewRequest(method, d.getURL(op, params), nil)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := signDiskRequest(req); err != nil {
+		return iodine.New(err, nil)
+	}
+	resp, err := remoteDiskClient.Do(req)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return iodine.New(readDiskError(resp, op, params.Get("path")), nil)
+	}
+	if result == nil {
+		return nil
+	}
+	jdec := json.NewDecoder(resp.Body)
+	if err := jdec.Decode(result); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// writeFile - stream a file to the disk, written once the writer is closed
+func (d remoteDisk) writeFile(params url.Values) io.WriteCloser {
+	reader, writer := io.Pipe()
+	w := &remoteFileWriter{PipeWriter: writer, done: make(chan error, 1)}
+	go func() {
+		req, err := http.NewRequest("PUT", d.getURL("file", params), reader)
+		if err == nil {
+			err = signDiskRequest(req)
+		}
+		if err != nil {
+			reader.CloseWithError(err)
+			w.done <- err
+			return
+		}
+		resp, err := remoteDiskClient.Do(req)
+		if err != nil {
+			reader.CloseWithError(err)
+			w.done <- err
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = readDiskError(resp, "file", params.Get("path"))
+		}
+		// fail writes still in progress if the disk stopped reading early
+		reader.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+// remoteFileWriter - file streamed to a remote disk, closing waits for the disk to sync it
+type remoteFileWriter struct {
+	*io.PipeWriter
+	done chan error
+	once sync.Once
+	err  error
+}
+
+// Close - finish the file, returns an error if it was not written and synced
+func (w *remoteFileWriter) Close() error {
+	w.once.Do(func() {
+		w.PipeWriter.Close()
+		if err := <-w.done; err != nil {
+			w.err = iodine.New(err, nil)
+		}
+	})
+	return w.err
+}
+
+// remoteFileReader - file streamed from a remote disk, seeking reopens it at the new offset
+type remoteFileReader struct {
+	disk   remoteDisk
+	name   string
+	body   io.ReadCloser
+	offset int64
+}
+
+// open - request the file from the current offset onwards
+func (r *remoteFileReader) open() error {
+	req, err := http.NewRequest("GET", r.disk.getURL("file", url.Values{"path": {r.name}}), nil)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := signDiskRequest(req); err != nil {
+		return iodine.New(err, nil)
+	}
+	if r.offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(r.offset, 10)+"-")
+	}
+	resp, err := remoteDiskClient.Do(req)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		r.body = resp.Body
+	case http.StatusRequestedRangeNotSatisfiable:
+		// positioned at or past the end of the file
+		resp.Body.Close()
+		r.body = ioutil.NopCloser(strings.NewReader(""))
+	default:
+		defer resp.Body.Close()
+		return iodine.New(readDiskError(resp, "file", r.name), nil)
+	}
+	return nil
+}
+
+// Read - read from the current offset
+func (r *remoteFileReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset = r.offset + int64(n)
+	return n, err
+}
+
+// Seek - position the reader relative to its start or current offset
+func (r *remoteFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset = r.offset + offset
+	default:
+		return r.offset, iodine.New(errors.New("unsupported seek"), nil)
+	}
+	if offset < 0 {
+		return r.offset, iodine.New(errors.New("invalid argument"), nil)
+	}
+	if offset == r.offset {
+		return r.offset, nil
+	}
+	r.body.Close()
+	r.offset = offset
+	if err := r.open(); err != nil {
+		r.body = ioutil.NopCloser(strings.NewReader(""))
+		return r.offset, iodine.New(err, nil)
+	}
+	return r.offset, nil
+}
+
+// Close - stop reading
+func (r *remoteFileReader) Close() error {
+	return r.body.Close()
+}
+
+// readDiskError - error returned by the disk server, files missing are reported as os.ErrNotExist
+// and disks locked by another process as ErrDiskLocked
+func readDiskError(resp *http.Response, op, name string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return ErrDiskLocked
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return errors.New(strings.TrimSpace(string(message)))
+}
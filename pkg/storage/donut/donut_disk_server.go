@@ -0,0 +1,319 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+/// This file contains the server side of remote disks, exporting local disks to other minio processes
+
+// diskServerPath - operations on exported disks are served under this path
+const diskServerPath = "/minio/disk/"
+
+// diskServer - local disks exported by path, along with the access keys requests are signed with
+type diskServer struct {
+	disks       map[string]Disk
+	locks       map[string]*exportedDiskLock
+	credentials map[string]string
+	router      *mux.Router
+}
+
+// NewDiskServer - export local disks to donuts of other hosts, which attach them as
+// host:port/path. Every request must be signed with one of the access keys in credentials,
+// mapped to their secret keys, see SetDiskCredentials
+func NewDiskServer(diskPaths []string, credentials map[string]string) (http.Handler, error) {
+	if len(diskPaths) == 0 {
+		return nil, iodine.New(errors.New("invalid argument"), nil)
+	}
+	if len(credentials) == 0 {
+		return nil, iodine.New(errors.New("no access keys to authenticate disk requests"), nil)
+	}
+	server := diskServer{
+		disks:       make(map[string]Disk),
+		locks:       make(map[string]*exportedDiskLock),
+		credentials: credentials,
+	}
+	for _, diskPath := range diskPaths {
+		if !path.IsAbs(diskPath) {
+			return nil, iodine.New(errors.New("exported disk path must be absolute"), map[string]string{"disk": diskPath})
+		}
+		disk, err := NewDisk(diskPath, 0)
+		if err != nil {
+			return nil, iodine.New(err, map[string]string{"disk": diskPath})
+		}
+		server.disks[path.Clean(diskPath)] = disk
+		server.locks[path.Clean(diskPath)] = &exportedDiskLock{lock: new(sync.Mutex)}
+	}
+	mux := mux.NewRouter()
+	mux.HandleFunc(diskServerPath+"fsinfo", server.fsInfoHandler).Methods("GET")
+	mux.HandleFunc(diskServerPath+"dir", server.makeDirHandler).Methods("PUT")
+	mux.HandleFunc(diskServerPath+"dir", server.listDirHandler).Methods("GET")
+	mux.HandleFunc(diskServerPath+"file", server.writeFileHandler).Methods("PUT")
+	mux.HandleFunc(diskServerPath+"file", server.readFileHandler).Methods("GET")
+	mux.HandleFunc(diskServerPath+"file", server.removeAllHandler).Methods("DELETE")
+	mux.HandleFunc(diskServerPath+"rename", server.renameHandler).Methods("POST")
+	mux.HandleFunc(diskServerPath+"lock", server.lockHandler).Methods("POST")
+	mux.HandleFunc(diskServerPath+"lock", server.unlockHandler).Methods("DELETE")
+	server.router = mux
+	return server, nil
+}
+
+// ServeHTTP - serve requests signed with a shared access key, refuse all others
+func (s diskServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := verifyDiskRequest(req, s.credentials); err != nil {
+		http.Error(w, ErrDiskAuth.Error(), http.StatusForbidden)
+		return
+	}
+	s.router.ServeHTTP(w, req)
+}
+
+// getDisk - exported disk a request operates on
+func (s diskServer) getDisk(w http.ResponseWriter, req *http.Request) (Disk, bool) {
+	disk, ok := s.disks[path.Clean(req.URL.Query().Get("disk"))]
+	if !ok {
+		http.Error(w, "disk not exported", http.StatusForbidden)
+		return nil, false
+	}
+	return disk, true
+}
+
+// getPath - path a request operates on, confined to the disk root
+func getPath(req *http.Request, name string) string {
+	return strings.TrimPrefix(path.Clean("/"+req.URL.Query().Get(name)), "/")
+}
+
+// writeDiskError - report an error of an exported disk, files missing are reported as not found
+func writeDiskError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(iodine.ToError(err)) {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	log.Error.Println(iodine.New(err, nil))
+	http.Error(w, iodine.ToError(err).Error(), http.StatusInternalServerError)
+}
+
+// writeJSON - reply with a JSON encoded result
+func writeJSON(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	jenc := json.NewEncoder(w)
+	jenc.Encode(result)
+}
+
+func (s diskServer) fsInfoHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	fsInfo := disk.GetFSInfo()
+	if fsInfo == nil {
+		http.Error(w, "disk unavailable", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, fsInfo)
+}
+
+func (s diskServer) makeDirHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	if err := disk.MakeDir(getPath(req, "path")); err != nil {
+		writeDiskError(w, err)
+	}
+}
+
+func (s diskServer) listDirHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	var contents []os.FileInfo
+	var err error
+	switch req.URL.Query().Get("only") {
+	case "files":
+		contents, err = disk.ListFiles(getPath(req, "path"))
+	default:
+		contents, err = disk.ListDir(getPath(req, "path"))
+	}
+	if err != nil {
+		writeDiskError(w, err)
+		return
+	}
+	files := make([]remoteFileInfo, 0, len(contents))
+	for _, content := range contents {
+		files = append(files, remoteFileInfo{
+			FileName:    content.Name(),
+			FileSize:    content.Size(),
+			FileMode:    content.Mode(),
+			FileModTime: content.ModTime(),
+		})
+	}
+	writeJSON(w, files)
+}
+
+// writeFileHandler - write a file streamed in the request body, synced before replying
+func (s diskServer) writeFileHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	var writer io.WriteCloser
+	var err error
+	switch req.URL.Query().Get("append") {
+	case "true":
+		writer, err = disk.AppendFile(getPath(req, "path"))
+	default:
+		writer, err = disk.MakeFile(getPath(req, "path"))
+	}
+	if err != nil {
+		writeDiskError(w, err)
+		return
+	}
+	if _, err := io.Copy(writer, req.Body); err != nil {
+		writer.Close()
+		writeDiskError(w, err)
+		return
+	}
+	if err := syncAndClose(writer); err != nil {
+		writeDiskError(w, err)
+	}
+}
+
+// readFileHandler - stream a file, byte ranges are served for readers seeking into it
+func (s diskServer) readFileHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	reader, err := disk.OpenFile(getPath(req, "path"))
+	if err != nil {
+		writeDiskError(w, err)
+		return
+	}
+	defer reader.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, req, "", time.Time{}, seeker)
+		return
+	}
+	io.Copy(w, reader)
+}
+
+func (s diskServer) removeAllHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	if err := disk.RemoveAll(getPath(req, "path")); err != nil {
+		writeDiskError(w, err)
+	}
+}
+
+func (s diskServer) renameHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	if err := disk.Rename(getPath(req, "path"), getPath(req, "to")); err != nil {
+		writeDiskError(w, err)
+	}
+}
+
+// exportedDiskLock - lock of an exported disk held on behalf of a remote owner, released once
+// its lease is not refreshed in time
+type exportedDiskLock struct {
+	lock     *sync.Mutex
+	owner    string
+	diskLock DiskLock
+	timer    *time.Timer
+}
+
+// acquire - lock the disk for owner, or refresh the lease owner already holds
+func (l *exportedDiskLock) acquire(diskPath, owner string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.diskLock != nil {
+		if l.owner != owner {
+			return iodine.New(ErrDiskLocked, nil)
+		}
+		l.timer.Reset(diskLockLease)
+		return nil
+	}
+	diskLock, err := lockLocalDisk(diskPath)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	l.diskLock = diskLock
+	l.owner = owner
+	l.timer = time.AfterFunc(diskLockLease, func() { l.release(owner) })
+	return nil
+}
+
+// release - unlock the disk if held by owner
+func (l *exportedDiskLock) release(owner string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.diskLock == nil || l.owner != owner {
+		return
+	}
+	l.timer.Stop()
+	if err := l.diskLock.Unlock(); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+	}
+	l.diskLock = nil
+	l.owner = ""
+}
+
+func (s diskServer) lockHandler(w http.ResponseWriter, req *http.Request) {
+	disk, ok := s.getDisk(w, req)
+	if !ok {
+		return
+	}
+	owner := req.URL.Query().Get("owner")
+	if owner == "" {
+		http.Error(w, "invalid argument", http.StatusBadRequest)
+		return
+	}
+	diskLock := s.locks[path.Clean(req.URL.Query().Get("disk"))]
+	if err := diskLock.acquire(disk.GetPath(), owner); err != nil {
+		if iodine.ToError(err) == ErrDiskLocked {
+			http.Error(w, ErrDiskLocked.Error(), http.StatusConflict)
+			return
+		}
+		writeDiskError(w, err)
+	}
+}
+
+func (s diskServer) unlockHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := s.getDisk(w, req); !ok {
+		return
+	}
+	s.locks[path.Clean(req.URL.Query().Get("disk"))].release(req.URL.Query().Get("owner"))
+}
@@ -19,6 +19,7 @@ package donut
 import (
 	"errors"
 	"strconv"
+	"strings"
 
 	encoding "github.com/minio-io/minio/pkg/erasure"
 	"github.com/minio-io/minio/pkg/iodine"
@@ -29,6 +30,10 @@ type encoder struct {
 	encoder   *encoding.Erasure
 	k, m      uint8
 	technique encoding.Technique
+	params    *encoding.ErasureParams
+	// erasure caches its decode matrix, which depends on the blocks missing,
+	// keep one decoder per set of missing blocks
+	decoders map[string]*encoding.Erasure
 }
 
 // getErasureTechnique - convert technique string into Technique type
@@ -37,7 +42,7 @@ func getErasureTechnique(technique string) (encoding.Technique, error) {
 	case technique == "Cauchy":
 		return encoding.Cauchy, nil
 	case technique == "Vandermonde":
-		return encoding.Cauchy, nil
+		return encoding.Vandermonde, nil
 	default:
 		return encoding.None, iodine.New(errors.New("Invalid erasure technique"), nil)
 	}
@@ -60,6 +65,8 @@ func NewEncoder(k, m uint8, technique string) (Encoder, error) {
 		return nil, iodine.New(err, errParams)
 	}
 	e.encoder = encoding.NewErasure(params)
+	e.params = params
+	e.decoders = make(map[string]*encoding.Erasure)
 	e.k = k
 	e.m = m
 	e.technique = t
@@ -89,7 +96,19 @@ func (e encoder) Encode(data []byte) (encodedData [][]byte, err error) {
 
 // Decode - erasure decode input encoded bytes
 func (e encoder) Decode(encodedData [][]byte, dataLength int) (data []byte, err error) {
-	decodedData, err := e.encoder.Decode(encodedData, dataLength)
+	var missingBlocks []string
+	for i, block := range encodedData {
+		if len(block) == 0 {
+			missingBlocks = append(missingBlocks, strconv.Itoa(i))
+		}
+	}
+	decoderKey := strings.Join(missingBlocks, ",")
+	decoder, ok := e.decoders[decoderKey]
+	if !ok {
+		decoder = encoding.NewErasure(e.params)
+		e.decoders[decoderKey] = decoder
+	}
+	decodedData, err := decoder.Decode(encodedData, dataLength)
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
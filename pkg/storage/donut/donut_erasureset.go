@@ -0,0 +1,187 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+/// This file contains erasure sets, the disks an object is erasure coded over, and the placement of
+/// objects on them
+
+const (
+	// maxErasureSetSize - most disks of all nodes an erasure set spans
+	maxErasureSetSize = 16
+	// minErasureSetSize - disks an erasure set other than the first needs before objects are placed on it
+	minErasureSetSize = 4
+	// erasureSetRingPoints - points every disk of an erasure set holds on the placement ring
+	erasureSetRingPoints = 8
+)
+
+// erasureSetDisk - a disk of an erasure set, along with the position of its node
+type erasureSetDisk struct {
+	disk Disk
+	node int
+}
+
+// erasureSet - disks an object is erasure coded over, ordered by node then disk order, slice i
+// of an object is kept on disk i. Nodes are ordered by hostname
+type erasureSet struct {
+	id    int
+	disks []erasureSetDisk
+}
+
+// getDonutDisks - all disks of all nodes, ordered by node then disk order
+func getDonutDisks(nodes map[string]Node) ([]erasureSetDisk, error) {
+	var hostnames []string
+	for hostname := range nodes {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	var donutDisks []erasureSetDisk
+	for nodeSlice, hostname := range hostnames {
+		disks, err := nodes[hostname].ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		nodeDisks := make([]Disk, len(disks))
+		for _, disk := range disks {
+			if disk.GetOrder() >= len(nodeDisks) || nodeDisks[disk.GetOrder()] != nil {
+				return nil, iodine.New(errors.New("disk order inconsistent"), map[string]string{
+					"node":  hostname,
+					"disk":  disk.GetPath(),
+					"order": strconv.Itoa(disk.GetOrder()),
+				})
+			}
+			nodeDisks[disk.GetOrder()] = disk
+		}
+		for _, disk := range nodeDisks {
+			donutDisks = append(donutDisks, erasureSetDisk{disk: disk, node: nodeSlice})
+		}
+	}
+	return donutDisks, nil
+}
+
+// getErasureSets - split the disks of all nodes into erasure sets of at most maxErasureSetSize
+// disks. Every set takes the same range of disk orders from every node, so that disks attached
+// to all nodes make up new sets while existing sets keep their disks. Sets only stay the same
+// as long as the number of nodes does
+func getErasureSets(nodes map[string]Node) ([]erasureSet, error) {
+	donutDisks, err := getDonutDisks(nodes)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	nodeSetSize := maxErasureSetSize / len(nodes)
+	if nodeSetSize == 0 {
+		nodeSetSize = 1
+	}
+	var sets []erasureSet
+	for _, setDisk := range donutDisks {
+		id := setDisk.disk.GetOrder() / nodeSetSize
+		for len(sets) <= id {
+			sets = append(sets, erasureSet{id: len(sets)})
+		}
+		sets[id].disks = append(sets[id].disks, setDisk)
+	}
+	if len(sets) == 0 {
+		return nil, iodine.New(errors.New("no disks attached"), nil)
+	}
+	return sets, nil
+}
+
+// getBucketSlice - name of the slice of a bucket kept on a disk of the set
+func (s erasureSetDisk) getBucketSlice(bucketName string) string {
+	return fmt.Sprintf("%s$%d$%d", bucketName, s.node, s.disk.GetOrder())
+}
+
+// getNodeWidth - most disks the set has on a single node, zero if all disks are on one node.
+// Parity of objects written over the set covers losing a whole node
+func (s erasureSet) getNodeWidth() int {
+	nodeDisks := make(map[int]int)
+	for _, setDisk := range s.disks {
+		nodeDisks[setDisk.node] = nodeDisks[setDisk.node] + 1
+	}
+	if len(nodeDisks) < 2 {
+		return 0
+	}
+	width := 0
+	for _, disks := range nodeDisks {
+		if disks > width {
+			width = disks
+		}
+	}
+	return width
+}
+
+// ringPoint - a point an erasure set holds on the placement ring
+type ringPoint struct {
+	hash uint64
+	set  int
+}
+
+// placementRing - consistent hashing of objects onto erasure sets. Every set holds points in
+// proportion to its disks, a set attached or grown only takes over objects from the arcs its
+// new points split, all other objects stay where they are
+type placementRing []ringPoint
+
+func (r placementRing) Len() int           { return len(r) }
+func (r placementRing) Less(i, j int) bool { return r[i].hash < r[j].hash }
+func (r placementRing) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// newPlacementRing - ring of all erasure sets objects can be placed on
+func newPlacementRing(sets []erasureSet) placementRing {
+	var ring placementRing
+	for _, set := range sets {
+		if set.id > 0 && len(set.disks) < minErasureSetSize {
+			continue
+		}
+		for point := 0; point < len(set.disks)*erasureSetRingPoints; point++ {
+			ring = append(ring, ringPoint{
+				hash: getRingHash(strconv.Itoa(set.id) + "$" + strconv.Itoa(point)),
+				set:  set.id,
+			})
+		}
+	}
+	sort.Sort(ring)
+	return ring
+}
+
+// getErasureSet - id of the erasure set an object of a bucket is placed on
+func (r placementRing) getErasureSet(bucketName, objectName string) int {
+	if len(r) == 0 {
+		return 0
+	}
+	hash := getRingHash(bucketName + "/" + objectName)
+	i := sort.Search(len(r), func(i int) bool { return r[i].hash >= hash })
+	if i == len(r) {
+		i = 0
+	}
+	return r[i].set
+}
+
+// getRingHash - position of a key on the placement ring
+func getRingHash(key string) uint64 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
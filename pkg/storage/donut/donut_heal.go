@@ -0,0 +1,634 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+/// This file contains all the functions used to heal objects inside a bucket
+
+// errObjectUnrecoverable - too many slices of an object are lost to reconstruct it
+var errObjectUnrecoverable = errors.New("object unrecoverable, not enough slices left")
+
+// errObjectTooWide - object spans more disks than its erasure set has attached, its slices
+// cannot be placed to be read or reconstructed
+var errObjectTooWide = errors.New("object spans more disks than attached")
+
+// objectSlice - location of one slice of an object on a disk
+type objectSlice struct {
+	disk       Disk
+	objectPath string
+}
+
+// Heal - reconstruct missing or unreadable slices of every object in this bucket
+func (b bucket) Heal(limiter *rateLimiter) (HealReport, error) {
+	report := HealReport{}
+	// a disk offline while names were migrated comes back with objects under their old names
+	if err := b.MigrateObjectNames(); err != nil {
+		return report, iodine.New(err, nil)
+	}
+	// commits which failed partway are rolled forward before their slices are looked at
+	if err := b.RecoverStagedObjects(); err != nil {
+		return report, iodine.New(err, nil)
+	}
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return report, iodine.New(err, nil)
+	}
+	b.lock.RLock()
+	objectNames, objectSets := b.listObjectSlices(sets)
+	b.lock.RUnlock()
+	for _, objectName := range objectNames {
+		// an object left behind on another erasure set is healed there as well, until
+		// rebalance moves it
+		for _, set := range objectSets[objectName] {
+			healed, _, err := b.healObject(set, objectName, limiter, false)
+			switch {
+			case err == nil && healed:
+				report.Healed = report.Healed + 1
+			case err == nil:
+				continue
+			case iodine.ToError(err) == errObjectUnrecoverable:
+				report.Unrecoverable = report.Unrecoverable + 1
+			default:
+				report.Failed = report.Failed + 1
+			}
+		}
+	}
+	return report, nil
+}
+
+// getObjectSlices - slices of an object on every disk of an erasure set, ordered by their position in the set
+func (b bucket) getObjectSlices(set erasureSet, objectName string) []objectSlice {
+	slices := make([]objectSlice, len(set.disks))
+	for i, setDisk := range set.disks {
+		slices[i] = objectSlice{
+			disk:       setDisk.disk,
+			objectPath: path.Join(b.donutName, setDisk.getBucketSlice(b.name), objectName),
+		}
+	}
+	return slices
+}
+
+// listObjectSlices - names of all objects present on at least one disk in lexical order, along
+// with the erasure sets holding slices of them. A bucket slice missing on a disk is treated as empty
+func (b bucket) listObjectSlices(sets []erasureSet) ([]string, map[string][]erasureSet) {
+	objectSets := make(map[string][]erasureSet)
+	for _, set := range sets {
+		for _, setDisk := range set.disks {
+			objects, err := setDisk.disk.ListDir(path.Join(b.donutName, setDisk.getBucketSlice(b.name)))
+			if err != nil {
+				continue
+			}
+			for _, object := range objects {
+				holding := objectSets[object.Name()]
+				if len(holding) > 0 && holding[len(holding)-1].id == set.id {
+					continue
+				}
+				objectSets[object.Name()] = append(holding, set)
+			}
+		}
+	}
+	var sortedObjectNames []string
+	for objectName := range objectSets {
+		sortedObjectNames = append(sortedObjectNames, objectName)
+	}
+	sort.Strings(sortedObjectNames)
+	return sortedObjectNames, objectSets
+}
+
+// ListTooWideObjects - objects spanning more disks than the erasure set holding them has,
+// left after disks they were spread over were detached
+func (b bucket) ListTooWideObjects() ([]string, error) {
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	objectNames, objectSets := b.listObjectSlices(sets)
+	var tooWide []string
+	for _, objectName := range objectNames {
+		for _, set := range objectSets[objectName] {
+			// metadata is read before the width is verified, it carries the name of the object
+			inspection, err := b.inspectObject(set, objectName, nil, false)
+			if iodine.ToError(err) == errObjectTooWide {
+				tooWide = append(tooWide, inspection.objectMetadata["object"])
+				break
+			}
+		}
+	}
+	return tooWide, nil
+}
+
+// readSliceMetadata - read and decode a metadata file of an object slice
+func readSliceMetadata(slice objectSlice, metadataConfig string) (map[string]string, error) {
+	reader, err := slice.disk.OpenFile(path.Join(slice.objectPath, metadataConfig))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	defer reader.Close()
+	metadataBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	metadata := make(map[string]string)
+	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if len(metadata) == 0 {
+		return nil, iodine.New(errors.New("empty metadata"), nil)
+	}
+	return metadata, nil
+}
+
+// getSliceDataLength - expected length of each data slice of an object
+func (b bucket) getSliceDataLength(totalSlices int, donutObjectMetadata map[string]string) (int64, error) {
+	if totalSlices == 1 {
+		size, err := strconv.ParseInt(donutObjectMetadata["sys.size"], 10, 64)
+		if err != nil {
+			return 0, iodine.New(err, nil)
+		}
+		return size, nil
+	}
+	totalChunks, totalLeft, blockSize, k, m, err := b.donutMetadata2Values(donutObjectMetadata)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	encoder, err := NewEncoder(uint8(k), uint8(m), donutObjectMetadata["sys.erasureTechnique"])
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	var sliceLength int64
+	for i := 0; i < totalChunks && totalLeft > 0; i++ {
+		curBlockSize := blockSize
+		if totalLeft < blockSize {
+			curBlockSize = totalLeft
+		}
+		curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
+		if err != nil {
+			return 0, iodine.New(err, nil)
+		}
+		if donutObjectMetadata["sys.blockChecksum"] == blockChecksumCRC32C {
+			curChunkSize = curChunkSize + blockChecksumSize
+		}
+		sliceLength = sliceLength + int64(curChunkSize)
+		totalLeft = totalLeft - curBlockSize
+	}
+	return sliceLength, nil
+}
+
+// isSliceDataHealthy - verify if data slice is present, readable and of expected length
+func isSliceDataHealthy(slice objectSlice, expectedLength int64) bool {
+	files, err := slice.disk.ListFiles(slice.objectPath)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if file.Name() == "data" {
+			return file.Size() == expectedLength
+		}
+	}
+	return false
+}
+
+// verifySliceBlocks - read back every block of a data slice verifying its checksum,
+// returns false if any block is corrupted or unreadable
+func (b bucket) verifySliceBlocks(slice objectSlice, donutObjectMetadata map[string]string, limiter *rateLimiter) bool {
+	// without checksums or erasure coding there is nothing to verify against
+	if donutObjectMetadata["sys.blockChecksum"] != blockChecksumCRC32C {
+		return true
+	}
+	totalChunks, totalLeft, blockSize, k, m, err := b.donutMetadata2Values(donutObjectMetadata)
+	if err != nil {
+		return false
+	}
+	encoder, err := NewEncoder(uint8(k), uint8(m), donutObjectMetadata["sys.erasureTechnique"])
+	if err != nil {
+		return false
+	}
+	reader, err := slice.disk.OpenFile(path.Join(slice.objectPath, "data"))
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+	limitedReader := rateLimitedReader{reader: reader, limiter: limiter}
+	for i := 0; i < totalChunks && totalLeft > 0; i++ {
+		curBlockSize := blockSize
+		if totalLeft < blockSize {
+			curBlockSize = totalLeft
+		}
+		curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
+		if err != nil {
+			return false
+		}
+		if _, err := readEncodedBlock(limitedReader, curChunkSize, true); err != nil {
+			log.Error.Println(iodine.New(err, map[string]string{
+				"bucket": b.name,
+				"slice":  path.Join(slice.disk.GetPath(), slice.objectPath),
+			}))
+			return false
+		}
+		totalLeft = totalLeft - curBlockSize
+	}
+	return true
+}
+
+// objectInspection - state of every slice of an object
+type objectInspection struct {
+	slices              []objectSlice
+	objectMetadata      map[string]string
+	donutObjectMetadata map[string]string
+	badData             []bool
+	badMetadata         []bool
+	bitrotSlices        int
+}
+
+// needsHeal - verify if any slice of the object needs to be repaired
+func (o objectInspection) needsHeal() bool {
+	for i := range o.slices {
+		if o.badData[i] || o.badMetadata[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectObject - verify every slice of an object, finding the ones missing or unreadable.
+// With verifyBlocks every block of every slice is read back and verified against its
+// checksum as well. Nothing is written, caller is expected to hold the bucket lock
+func (b bucket) inspectObject(set erasureSet, objectName string, limiter *rateLimiter, verifyBlocks bool) (objectInspection, error) {
+	inspection := objectInspection{}
+	slices := b.getObjectSlices(set, objectName)
+	inspection.slices = slices
+	// metadata of the most recently written version of the object is authoritative,
+	// a disk which missed an overwrite may still carry the previous version
+	sliceObjectMetadata := make([]map[string]string, len(slices))
+	sliceDonutObjectMetadata := make([]map[string]string, len(slices))
+	var created time.Time
+	for i, slice := range slices {
+		sliceObjectMetadata[i], _ = readSliceMetadata(slice, objectMetadataConfig)
+		sliceDonutObjectMetadata[i], _ = readSliceMetadata(slice, donutObjectMetadataConfig)
+		if sliceObjectMetadata[i] == nil || sliceDonutObjectMetadata[i] == nil {
+			continue
+		}
+		sliceCreated, _ := time.Parse(time.RFC3339Nano, sliceObjectMetadata[i]["created"])
+		if inspection.objectMetadata == nil || sliceCreated.After(created) {
+			inspection.objectMetadata = sliceObjectMetadata[i]
+			inspection.donutObjectMetadata = sliceDonutObjectMetadata[i]
+			created = sliceCreated
+		}
+	}
+	if inspection.objectMetadata == nil || inspection.donutObjectMetadata == nil {
+		return inspection, iodine.New(errObjectUnrecoverable, nil)
+	}
+	// disks attached after the object was written hold no slices of it
+	width, err := getObjectWidth(inspection.donutObjectMetadata, len(slices))
+	if err != nil {
+		return inspection, iodine.New(err, nil)
+	}
+	slices = slices[:width]
+	inspection.slices = slices
+	healthySlices, err := parseHealthySlices(inspection.donutObjectMetadata["sys.slices"], len(slices))
+	if err != nil {
+		return inspection, iodine.New(err, nil)
+	}
+	sliceLength, err := b.getSliceDataLength(len(slices), inspection.donutObjectMetadata)
+	if err != nil {
+		return inspection, iodine.New(err, nil)
+	}
+	inspection.badMetadata = make([]bool, len(slices))
+	inspection.badData = make([]bool, len(slices))
+	for i, slice := range slices {
+		// slices missed by a write are never trusted, even if a stale copy is present
+		if !healthySlices[i] || !isSliceDataHealthy(slice, sliceLength) {
+			inspection.badData[i] = true
+		} else if verifyBlocks && !b.verifySliceBlocks(slice, inspection.donutObjectMetadata, limiter) {
+			inspection.badData[i] = true
+			inspection.bitrotSlices = inspection.bitrotSlices + 1
+		}
+		if !reflect.DeepEqual(sliceObjectMetadata[i], inspection.objectMetadata) ||
+			!reflect.DeepEqual(sliceDonutObjectMetadata[i], inspection.donutObjectMetadata) {
+			inspection.badMetadata[i] = true
+		}
+	}
+	return inspection, nil
+}
+
+// healObject - verify every slice of an object, reconstructing the ones found missing
+// or unreadable from the surviving slices. With verifyBlocks slices with bitrot are
+// reconstructed as well. Slices are verified and reconstructed into staging without the
+// bucket lock, it is only held to read the metadata of the object before and to verify it
+// did not change before the healed slices are swapped in. Returns true if anything was
+// repaired along with the number of slices found with bitrot, caller must not hold the
+// bucket lock
+func (b bucket) healObject(set erasureSet, objectName string, limiter *rateLimiter, verifyBlocks bool) (bool, int, error) {
+	before, beforeErr := b.inspectObjectMetadata(set, objectName)
+	inspection, err := b.inspectObject(set, objectName, limiter, verifyBlocks)
+	// slices read while being replaced by a write look damaged, an object written or updated
+	// meanwhile is verified by the next pass
+	if err != nil {
+		if !b.isObjectUnchanged(set, objectName, before, beforeErr) {
+			return false, 0, nil
+		}
+		return false, 0, iodine.New(err, nil)
+	}
+	if beforeErr != nil || !isSameObject(inspection, before) {
+		return false, 0, nil
+	}
+	if !inspection.needsHeal() {
+		return false, inspection.bitrotSlices, nil
+	}
+	stagingName, err := newStagingName()
+	if err != nil {
+		return false, 0, iodine.New(err, nil)
+	}
+	// left behind if marked committing, completed by the next heal or on startup
+	defer b.removeStagedObject(stagingName)
+	staged, err := b.stageHealedSlices(inspection, stagingName, limiter)
+	if err != nil {
+		if !b.isObjectUnchanged(set, objectName, before, nil) {
+			return false, 0, nil
+		}
+		return false, 0, iodine.New(err, nil)
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	current, err := b.inspectObject(set, objectName, nil, false)
+	if err != nil || !isSameObject(current, before) {
+		return false, 0, nil
+	}
+	if err := b.commitHealedSlices(set, stagingName, objectName, inspection.slices, staged); err != nil {
+		return false, 0, iodine.New(err, nil)
+	}
+	return true, inspection.bitrotSlices, nil
+}
+
+// inspectObjectMetadata - inspect an object without verifying its blocks, with writes held off
+func (b bucket) inspectObjectMetadata(set erasureSet, objectName string) (objectInspection, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.inspectObject(set, objectName, nil, false)
+}
+
+// isObjectUnchanged - verify an object is still the one inspected earlier, an object which could
+// not be inspected then is unchanged as long as it still cannot be
+func (b bucket) isObjectUnchanged(set erasureSet, objectName string, inspection objectInspection, inspectErr error) bool {
+	current, err := b.inspectObjectMetadata(set, objectName)
+	if err != nil || inspectErr != nil {
+		return err != nil && inspectErr != nil
+	}
+	return isSameObject(current, inspection)
+}
+
+// isSameObject - verify two inspections found the same version of an object
+func isSameObject(a, b objectInspection) bool {
+	return reflect.DeepEqual(a.objectMetadata, b.objectMetadata) &&
+		reflect.DeepEqual(a.donutObjectMetadata, b.donutObjectMetadata)
+}
+
+// stageHealedSlices - reconstruct data of bad slices and write metadata of every slice needing
+// heal into staging location. Returns the slices staged
+func (b bucket) stageHealedSlices(inspection objectInspection, stagingName string, limiter *rateLimiter) ([]bool, error) {
+	slices := inspection.slices
+	badData := inspection.badData
+	badMetadata := make([]bool, len(slices))
+	copy(badMetadata, inspection.badMetadata)
+	donutObjectMetadata := make(map[string]string)
+	for k, v := range inspection.donutObjectMetadata {
+		donutObjectMetadata[k] = v
+	}
+	badDataCount := 0
+	for _, bad := range badData {
+		if bad {
+			badDataCount = badDataCount + 1
+		}
+	}
+	// every slice is healthy once healed, the list of healthy slices changes on all disks
+	if badDataCount > 0 {
+		allSlices := make([]bool, len(slices))
+		for i := range allSlices {
+			allSlices[i] = true
+		}
+		donutObjectMetadata["sys.slices"] = formatHealthySlices(allSlices)
+		for i := range badMetadata {
+			badMetadata[i] = true
+		}
+		if err := b.reconstructSliceData(slices, badData, stagingName, donutObjectMetadata, limiter); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+	}
+	staged := make([]bool, len(slices))
+	for i, slice := range slices {
+		if !badData[i] && !badMetadata[i] {
+			continue
+		}
+		if err := stageSliceMetadata(slice, stagingName, inspection.objectMetadata, donutObjectMetadata); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		staged[i] = true
+	}
+	return staged, nil
+}
+
+// commitHealedSlices - swap healed slices into place through the same commit markers as writes,
+// a swap interrupted is completed by the next heal or on startup. Slices with only their metadata
+// healed have their data moved into staging beside it first. Caller is expected to hold the
+// bucket lock
+func (b bucket) commitHealedSlices(set erasureSet, stagingName, objectName string, slices []objectSlice, staged []bool) error {
+	if err := b.writeCommitMarkers(set, stagingName, objectName, staged, true); err != nil {
+		return iodine.New(err, nil)
+	}
+	for i, slice := range slices {
+		if !staged[i] {
+			continue
+		}
+		if err := commitHealedSlice(slice.disk, path.Join(stagingDir, stagingName), slice.objectPath); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := b.removeCommitMarkers(stagingName); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// stageSliceMetadata - write both metadata files of an object slice into staging location
+func stageSliceMetadata(slice objectSlice, stagingName string, objectMetadata, donutObjectMetadata map[string]string) error {
+	metadataFiles := map[string]map[string]string{
+		objectMetadataConfig:      objectMetadata,
+		donutObjectMetadataConfig: donutObjectMetadata,
+	}
+	for metadataConfig, metadata := range metadataFiles {
+		writer, err := slice.disk.MakeFile(path.Join(stagingDir, stagingName, metadataConfig))
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := writeSliceMetadata([]io.WriteCloser{writer}, metadata); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// reconstructSliceData - decode object from healthy data slices and write the missing
+// slices into staging location, the decoded object is verified against its md5sum
+func (b bucket) reconstructSliceData(slices []objectSlice, badData []bool, stagingName string, donutObjectMetadata map[string]string, limiter *rateLimiter) error {
+	// without erasure coding there is nothing left to reconstruct from
+	if len(slices) == 1 {
+		return iodine.New(errObjectUnrecoverable, nil)
+	}
+	totalChunks, totalLeft, blockSize, k, m, err := b.donutMetadata2Values(donutObjectMetadata)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	badDataCount := 0
+	for _, bad := range badData {
+		if bad {
+			badDataCount = badDataCount + 1
+		}
+	}
+	if badDataCount > int(m) {
+		return iodine.New(errObjectUnrecoverable, nil)
+	}
+	expectedMd5sum, err := hex.DecodeString(donutObjectMetadata["sys.md5"])
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	readers := make([]io.Reader, len(slices))
+	writers := make([]io.Writer, len(slices))
+	var files []io.WriteCloser
+	for i, slice := range slices {
+		if badData[i] {
+			writer, err := slice.disk.MakeFile(path.Join(stagingDir, stagingName, "data"))
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+			defer writer.Close()
+			files = append(files, writer)
+			writers[i] = rateLimitedWriter{writer: writer, limiter: limiter}
+			continue
+		}
+		reader, err := slice.disk.OpenFile(path.Join(slice.objectPath, "data"))
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		defer reader.Close()
+		readers[i] = rateLimitedReader{reader: reader, limiter: limiter}
+	}
+	encoder, err := NewEncoder(uint8(k), uint8(m), donutObjectMetadata["sys.erasureTechnique"])
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	checksummed := donutObjectMetadata["sys.blockChecksum"] == blockChecksumCRC32C
+	hasher := md5.New()
+	for i := 0; i < totalChunks && totalLeft > 0; i++ {
+		curBlockSize := blockSize
+		if totalLeft < blockSize {
+			curBlockSize = totalLeft
+		}
+		curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		encodedBlocks := make([][]byte, len(slices))
+		for j, reader := range readers {
+			if reader == nil {
+				continue
+			}
+			block, err := readEncodedBlock(reader, curChunkSize, checksummed)
+			if err != nil && iodine.ToError(err) != errBitrot {
+				return iodine.New(err, nil)
+			}
+			// a block failing its checksum is reconstructed like a missing one
+			encodedBlocks[j] = block
+		}
+		decodedData, err := encoder.Decode(encodedBlocks, int(curBlockSize))
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		hasher.Write(decodedData)
+		// encoding is deterministic, re-encoding yields the lost blocks as originally written
+		reEncodedBlocks, err := encoder.Encode(decodedData)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for j, writer := range writers {
+			if writer == nil {
+				continue
+			}
+			if checksummed {
+				err = writeEncodedBlock(writer, reEncodedBlocks[j])
+			} else {
+				_, err = io.Copy(writer, bytes.NewBuffer(reEncodedBlocks[j]))
+			}
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+		}
+		totalLeft = totalLeft - curBlockSize
+	}
+	if !bytes.Equal(expectedMd5sum, hasher.Sum(nil)) {
+		return iodine.New(errors.New("checksum mismatch"), nil)
+	}
+	// reconstructed slices are durable before being renamed into place
+	for _, file := range files {
+		if err := syncAndClose(file); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// commitHealedSlice - swap a healed slice into place, a slice only its metadata was healed for
+// takes its data along
+func commitHealedSlice(disk Disk, stagingPath, objectPath string) error {
+	files, err := disk.ListFiles(stagingPath)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	stagedData := false
+	for _, file := range files {
+		if file.Name() == "data" {
+			stagedData = true
+		}
+	}
+	if !stagedData {
+		if err := disk.Rename(path.Join(objectPath, "data"), path.Join(stagingPath, "data")); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := commitStagedSlice(disk, stagingPath, objectPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
@@ -0,0 +1,525 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/storage/drivers"
+)
+
+/// This file contains the object index, object names of a bucket in lexical order along with their
+/// metadata. Every erasure set keeps an index of the objects it holds
+
+// objectIndexCompactThreshold - the log is compacted once it holds this many more records than objects
+const objectIndexCompactThreshold = 1024
+
+// objectIndexRecord - a single record of the object index log. A compacted log starts with a
+// snapshot of all objects sharing one sequence, records appended afterwards carry consecutive
+// sequences. A disk which missed a record is detected by the gap and compacted on load
+type objectIndexRecord struct {
+	Sequence uint64
+	Snapshot bool
+	Object   string            `json:",omitempty"`
+	Metadata map[string]string `json:",omitempty"`
+	// object moved to another erasure set
+	Removed bool `json:",omitempty"`
+}
+
+// objectIndexes - object indexes of a bucket, one for every erasure set
+type objectIndexes struct {
+	lock *sync.Mutex
+	sets []*objectIndex
+}
+
+// newObjectIndexes - instantiate object indexes, every index is loaded from disks on first use
+func newObjectIndexes() *objectIndexes {
+	return &objectIndexes{lock: new(sync.Mutex)}
+}
+
+// get - object index of an erasure set
+func (x *objectIndexes) get(set erasureSet) *objectIndex {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for len(x.sets) <= set.id {
+		x.sets = append(x.sets, newObjectIndex())
+	}
+	return x.sets[set.id]
+}
+
+// objectIndex - sorted index of the objects of an erasure set, kept as an append only log in
+// every bucket slice of the set
+type objectIndex struct {
+	// protects everything below, acquired after the bucket lock
+	lock    *sync.Mutex
+	loaded  bool
+	names   []string
+	objects map[string]map[string]string
+	// last record appended, records appended since the log was compacted
+	sequence uint64
+	records  int
+}
+
+// newObjectIndex - instantiate a new object index, loaded from disks on first use
+func newObjectIndex() *objectIndex {
+	return &objectIndex{
+		lock:    new(sync.Mutex),
+		objects: make(map[string]map[string]string),
+	}
+}
+
+// set - add or replace an object, keeping names in lexical order
+func (i *objectIndex) set(objectName string, metadata map[string]string) {
+	if _, ok := i.objects[objectName]; !ok {
+		n := sort.SearchStrings(i.names, objectName)
+		i.names = append(i.names, "")
+		copy(i.names[n+1:], i.names[n:])
+		i.names[n] = objectName
+	}
+	i.objects[objectName] = metadata
+}
+
+// remove - remove an object, if present
+func (i *objectIndex) remove(objectName string) {
+	if _, ok := i.objects[objectName]; !ok {
+		return
+	}
+	n := sort.SearchStrings(i.names, objectName)
+	i.names = append(i.names[:n], i.names[n+1:]...)
+	delete(i.objects, objectName)
+}
+
+// replace - replace all objects at once
+func (i *objectIndex) replace(objects map[string]map[string]string) {
+	i.objects = objects
+	i.names = make([]string, 0, len(objects))
+	for objectName := range objects {
+		i.names = append(i.names, objectName)
+	}
+	sort.Strings(i.names)
+}
+
+// list - names of objects starting with prefix and lexically greater than marker, in lexical order
+func (i *objectIndex) list(prefix, marker string) []string {
+	start := prefix
+	if marker > start {
+		start = marker
+	}
+	names := i.names[sort.SearchStrings(i.names, start):]
+	// names sharing prefix are adjacent, and come first
+	end := sort.Search(len(names), func(n int) bool {
+		return !strings.HasPrefix(names[n], prefix)
+	})
+	return names[:end]
+}
+
+// getIndexedObject - metadata of the most recently written copy of an object on any erasure set,
+// along with the set holding it. An object is only found on more than one set while being moved
+// between them, os.ErrNotExist if not found. Caller is expected to hold the bucket lock
+func (b bucket) getIndexedObject(sets []erasureSet, objectName string) (map[string]string, erasureSet, error) {
+	var objectMetadata map[string]string
+	var objectSet erasureSet
+	var created time.Time
+	for _, set := range sets {
+		metadata, err := b.getSetIndexedObject(set, objectName)
+		if err != nil {
+			if os.IsNotExist(iodine.ToError(err)) {
+				continue
+			}
+			return nil, erasureSet{}, iodine.New(err, nil)
+		}
+		setCreated, _ := time.Parse(time.RFC3339Nano, metadata["created"])
+		if objectMetadata == nil || setCreated.After(created) {
+			objectMetadata = metadata
+			objectSet = set
+			created = setCreated
+		}
+	}
+	if objectMetadata == nil {
+		return nil, erasureSet{}, iodine.New(os.ErrNotExist, nil)
+	}
+	return objectMetadata, objectSet, nil
+}
+
+// getSetIndexedObject - metadata of an object in the index of a single erasure set, os.ErrNotExist
+// if not found. Caller is expected to hold the bucket lock
+func (b bucket) getSetIndexedObject(set erasureSet, objectName string) (map[string]string, error) {
+	index := b.indexes.get(set)
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if err := b.loadObjectIndex(set, index); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	metadata, ok := index.objects[objectName]
+	if !ok {
+		return nil, iodine.New(os.ErrNotExist, nil)
+	}
+	objectMetadata := make(map[string]string)
+	for k, v := range metadata {
+		objectMetadata[k] = v
+	}
+	return objectMetadata, nil
+}
+
+// listIndexedObjects - a single page of objects and common prefixes, in lexical order. Objects
+// of all erasure sets are merged. Caller is expected to hold the bucket lock
+func (b bucket) listIndexedObjects(sets []erasureSet, prefix, marker, delimiter string, maxkeys int) ([]string, []string, bool, error) {
+	var setObjectNames [][]string
+	for _, set := range sets {
+		index := b.indexes.get(set)
+		index.lock.Lock()
+		if err := b.loadObjectIndex(set, index); err != nil {
+			index.lock.Unlock()
+			return nil, nil, false, iodine.New(err, nil)
+		}
+		// names are only changed while holding the bucket lock for writing
+		setObjectNames = append(setObjectNames, index.list(prefix, marker))
+		index.lock.Unlock()
+	}
+	results, commonPrefixes, isTruncated := drivers.ListObjectsPage(mergeObjectNames(setObjectNames), prefix, marker, delimiter, maxkeys)
+	return results, commonPrefixes, isTruncated, nil
+}
+
+// mergeObjectNames - merge lists of names in lexical order into a single one, names found in more
+// than one list are kept once
+func mergeObjectNames(lists [][]string) []string {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	var names []string
+	for {
+		next := -1
+		for i, list := range lists {
+			if len(list) > 0 && (next < 0 || list[0] < lists[next][0]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return names
+		}
+		name := lists[next][0]
+		for i, list := range lists {
+			if len(list) > 0 && list[0] == name {
+				lists[i] = list[1:]
+			}
+		}
+		names = append(names, name)
+	}
+}
+
+// indexObject - add or replace an object in the index of an erasure set, appended to the log on
+// every disk of the set. Caller is expected to hold the bucket lock
+func (b bucket) indexObject(set erasureSet, objectName string, metadata map[string]string) error {
+	index := b.indexes.get(set)
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if err := b.loadObjectIndex(set, index); err != nil {
+		return iodine.New(err, nil)
+	}
+	index.set(objectName, metadata)
+	record := objectIndexRecord{Object: objectName, Metadata: metadata}
+	if err := b.appendObjectIndex(set, index, record, b.getIndexWriteQuorum(set, metadata["storageClass"])); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// unindexObject - remove an object moved to another erasure set from the index of a set.
+// Caller is expected to hold the bucket lock
+func (b bucket) unindexObject(set erasureSet, objectName string) error {
+	index := b.indexes.get(set)
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if err := b.loadObjectIndex(set, index); err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, ok := index.objects[objectName]; !ok {
+		return nil
+	}
+	index.remove(objectName)
+	record := objectIndexRecord{Object: objectName, Removed: true}
+	if err := b.appendObjectIndex(set, index, record, b.getIndexWriteQuorum(set, StorageClassStandard)); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// getIndexWriteQuorum - disks of an erasure set an update of its index must reach, as many as
+// the slices of an object of the given storage class need to
+func (b bucket) getIndexWriteQuorum(set erasureSet, class string) int {
+	if len(set.disks) == 1 {
+		return 1
+	}
+	sc, err := getStorageClass(class)
+	if err != nil {
+		sc = storageClasses[StorageClassStandard]
+	}
+	k, _, err := sc.getDataAndParity(len(set.disks), set.getNodeWidth())
+	if err != nil {
+		return len(set.disks)/2 + 1
+	}
+	return b.getWriteQuorum(len(set.disks), k)
+}
+
+// appendObjectIndex - append a record to the log on every disk of an erasure set, the log is
+// compacted instead once it grew too long. Fails unless writeQuorum disks were updated, the
+// index is then reloaded from the disks on next use. Caller is expected to hold the index lock
+func (b bucket) appendObjectIndex(set erasureSet, index *objectIndex, record objectIndexRecord, writeQuorum int) error {
+	index.sequence = index.sequence + 1
+	if index.records >= len(index.names)+objectIndexCompactThreshold {
+		if err := b.compactObjectIndex(set, index, writeQuorum); err != nil {
+			return iodine.New(err, nil)
+		}
+		return nil
+	}
+	record.Sequence = index.sequence
+	appended := 0
+	for _, file := range b.getObjectSlices(set, objectIndexConfig) {
+		writer, err := file.disk.AppendFile(file.objectPath)
+		if err != nil {
+			continue
+		}
+		jenc := json.NewEncoder(writer)
+		if err := jenc.Encode(record); err != nil {
+			writer.Close()
+			continue
+		}
+		if err := syncAndClose(writer); err != nil {
+			continue
+		}
+		appended = appended + 1
+	}
+	index.records = index.records + 1
+	if appended < writeQuorum {
+		index.loaded = false
+		return iodine.New(errors.New("object index write quorum not reached"), map[string]string{
+			"bucket":      b.name,
+			"appended":    strconv.Itoa(appended),
+			"writeQuorum": strconv.Itoa(writeQuorum),
+		})
+	}
+	return nil
+}
+
+// indexCommittedObject - add or replace an object in the index of an erasure set, with the
+// metadata of its most recently written slice. Caller is expected to hold the bucket lock
+func (b bucket) indexCommittedObject(set erasureSet, objectName string) error {
+	var objectMetadata map[string]string
+	var created time.Time
+	for _, slice := range b.getObjectSlices(set, objectName) {
+		sliceObjectMetadata, err := readSliceMetadata(slice, objectMetadataConfig)
+		if err != nil {
+			continue
+		}
+		sliceCreated, _ := time.Parse(time.RFC3339Nano, sliceObjectMetadata["created"])
+		if objectMetadata == nil || sliceCreated.After(created) {
+			objectMetadata = sliceObjectMetadata
+			created = sliceCreated
+		}
+	}
+	if objectMetadata == nil {
+		return iodine.New(errObjectUnrecoverable, map[string]string{"bucket": b.name, "object": objectName})
+	}
+	if err := b.indexObject(set, objectMetadata["object"], objectMetadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// loadObjectIndex - load the index of an erasure set from the most recent log found on any of its
+// disks, only once. Logs missing or behind on some disks are replaced, a lost index is rebuilt
+// from object slices. Caller is expected to hold the bucket lock and the index lock
+func (b bucket) loadObjectIndex(set erasureSet, index *objectIndex) error {
+	if index.loaded {
+		return nil
+	}
+	files := b.getObjectSlices(set, objectIndexConfig)
+	var latest []objectIndexRecord
+	upToDate := 0
+	for _, file := range files {
+		records, err := readObjectIndexLog(file)
+		if err != nil {
+			continue
+		}
+		switch {
+		case latest == nil || records[len(records)-1].Sequence > latest[len(latest)-1].Sequence:
+			latest = records
+			upToDate = 1
+		case records[len(records)-1].Sequence == latest[len(latest)-1].Sequence:
+			upToDate = upToDate + 1
+		}
+	}
+	if latest == nil {
+		if err := b.rebuildObjectIndex(set, index); err != nil {
+			return iodine.New(err, nil)
+		}
+		index.loaded = true
+		return nil
+	}
+	objects := make(map[string]map[string]string)
+	index.records = 0
+	for _, record := range latest {
+		if !record.Snapshot {
+			index.records = index.records + 1
+		}
+		switch {
+		case record.Object == "":
+		case record.Removed:
+			delete(objects, record.Object)
+		default:
+			objects[record.Object] = record.Metadata
+		}
+	}
+	index.replace(objects)
+	index.sequence = latest[len(latest)-1].Sequence
+	if upToDate < len(files) {
+		// best effort, the index was read from the disks up to date
+		if err := b.compactObjectIndex(set, index, 1); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	index.loaded = true
+	return nil
+}
+
+// rebuildObjectIndex - rebuild the index of an erasure set from the metadata of its object slices.
+// Caller is expected to hold the bucket lock and the index lock
+func (b bucket) rebuildObjectIndex(set erasureSet, index *objectIndex) error {
+	objectList, err := b.listObjects(set)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	objects := make(map[string]map[string]string)
+	for objectName, object := range objectList {
+		objectMetadata, err := object.GetObjectMetadata()
+		if err != nil {
+			continue
+		}
+		objects[objectName] = objectMetadata
+	}
+	index.replace(objects)
+	// rebuilt again from the slices if lost once more
+	if err := b.compactObjectIndex(set, index, 1); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// compactObjectIndex - replace the log on every disk of an erasure set by a snapshot of all its
+// objects, fails unless saved on writeQuorum disks. Caller is expected to hold the index lock
+func (b bucket) compactObjectIndex(set erasureSet, index *objectIndex, writeQuorum int) error {
+	stagingName, err := newStagingName()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer b.removeStagedObject(stagingName)
+	stagingPath := path.Join(stagingDir, stagingName, objectIndexConfig)
+	saved := 0
+	for _, file := range b.getObjectSlices(set, objectIndexConfig) {
+		writer, err := file.disk.MakeFile(stagingPath)
+		if err != nil {
+			continue
+		}
+		if err := index.writeSnapshot(writer); err != nil {
+			writer.Close()
+			continue
+		}
+		if err := syncAndClose(writer); err != nil {
+			continue
+		}
+		if err := file.disk.Rename(stagingPath, file.objectPath); err != nil {
+			continue
+		}
+		saved = saved + 1
+	}
+	index.records = 0
+	if saved < writeQuorum {
+		index.loaded = false
+		return iodine.New(errors.New("object index write quorum not reached"), map[string]string{
+			"bucket":      b.name,
+			"saved":       strconv.Itoa(saved),
+			"writeQuorum": strconv.Itoa(writeQuorum),
+		})
+	}
+	return nil
+}
+
+// writeSnapshot - encode all objects as a snapshot at the current sequence
+func (i *objectIndex) writeSnapshot(writer io.Writer) error {
+	jenc := json.NewEncoder(writer)
+	if err := jenc.Encode(objectIndexRecord{Sequence: i.sequence, Snapshot: true}); err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, objectName := range i.names {
+		record := objectIndexRecord{
+			Sequence: i.sequence,
+			Snapshot: true,
+			Object:   objectName,
+			Metadata: i.objects[objectName],
+		}
+		if err := jenc.Encode(record); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// readObjectIndexLog - read the records of an object index log from a single disk, up to the
+// first record torn by a crash or out of sequence
+func readObjectIndexLog(file objectSlice) ([]objectIndexRecord, error) {
+	reader, err := file.disk.OpenFile(file.objectPath)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	defer reader.Close()
+	var records []objectIndexRecord
+	jdec := json.NewDecoder(reader)
+	for {
+		var record objectIndexRecord
+		if err := jdec.Decode(&record); err != nil {
+			break
+		}
+		if len(records) == 0 {
+			// every log starts with a snapshot
+			if !record.Snapshot || record.Object != "" {
+				break
+			}
+		} else {
+			last := records[len(records)-1]
+			inSnapshot := record.Snapshot && last.Snapshot && record.Sequence == last.Sequence
+			appended := !record.Snapshot && record.Sequence == last.Sequence+1
+			if !inSnapshot && !appended {
+				break
+			}
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, iodine.New(errors.New("object index log unreadable"), map[string]string{"path": file.objectPath})
+	}
+	return records, nil
+}
@@ -30,10 +30,20 @@ type Encoder interface {
 
 // Bucket interface
 type Bucket interface {
-	ListObjects() (map[string]Object, error)
+	ListObjects(prefix, marker, delimiter string, maxkeys int) (objects []string, prefixes []string, isTruncated bool, err error)
 
 	GetObject(object string) (io.ReadCloser, int64, error)
+	GetPartialObject(object string, start, length int64) (io.ReadCloser, error)
+	GetObjectMetadata(object string) (map[string]string, error)
 	PutObject(object string, contents io.Reader, expectedMD5Sum string, metadata map[string]string) error
+	SetObjectMetadata(object string, metadata map[string]string) error
+
+	Heal(limiter *rateLimiter) (HealReport, error)
+	Scrub(marker string, limiter *rateLimiter, scrubbed func(object string, stats ScrubStats) bool) error
+	MigrateObjectNames() error
+	RecoverStagedObjects() error
+	ListTooWideObjects() ([]string, error)
+	Rebalance(marker string, limiter *rateLimiter, rebalanced func(object string, stats RebalanceStats) bool) error
 }
 
 // Object interface
@@ -53,17 +63,23 @@ type Node interface {
 	LoadConfig() error
 }
 
-// Disk interface
+// Disk interface, local or remote. Files are streamed, writers may only report errors once
+// closed
 type Disk interface {
 	MakeDir(dirname string) error
 
 	ListDir(dirname string) ([]os.FileInfo, error)
 	ListFiles(dirname string) ([]os.FileInfo, error)
 
-	MakeFile(path string) (*os.File, error)
-	OpenFile(path string) (*os.File, error)
+	MakeFile(path string) (io.WriteCloser, error)
+	OpenFile(path string) (io.ReadCloser, error)
+	AppendFile(path string) (io.WriteCloser, error)
+
+	Rename(oldpath, newpath string) error
+	RemoveAll(path string) error
 
 	GetPath() string
+	GetID() string
 	GetOrder() int
 	GetFSInfo() map[string]string
 }
@@ -0,0 +1,177 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+/// This file contains the exclusive locks a process modifying a donut holds on all of its disks
+
+const (
+	// lock file in the root of every disk
+	diskLockConfig = ".lock"
+	// locks of remote disks are leased, released by the disk server unless refreshed in time
+	diskLockLease = 30 * time.Second
+)
+
+// ErrDiskLocked - disk is locked by another process modifying the donut
+var ErrDiskLocked = errors.New("disk locked by another minio process")
+
+// DiskLock - exclusive lock on disks, held until unlocked or the process exits
+type DiskLock interface {
+	Unlock() error
+}
+
+// donutLock - locks of all disks of a donut
+type donutLock struct {
+	locks []DiskLock
+}
+
+// Unlock - release the lock of every disk
+func (l donutLock) Unlock() error {
+	var err error
+	for _, lock := range l.locks {
+		if unlockErr := lock.Unlock(); unlockErr != nil && err == nil {
+			err = iodine.New(unlockErr, nil)
+		}
+	}
+	return err
+}
+
+// LockDisks - lock every disk of nodeDiskMap exclusively, fails with ErrDiskLocked if any of them
+// is locked by another process. Remote disks are locked by the disk server exporting them
+func LockDisks(nodeDiskMap map[string][]string) (DiskLock, error) {
+	owner, err := newUUID()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var hostnames []string
+	for hostname := range nodeDiskMap {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	lock := donutLock{}
+	for _, hostname := range hostnames {
+		for _, diskPath := range nodeDiskMap[hostname] {
+			var diskLock DiskLock
+			if IsRemoteDisk(diskPath) {
+				slash := strings.Index(diskPath, "/")
+				diskLock, err = lockRemoteDisk(remoteDisk{address: diskPath[:slash], root: diskPath[slash:]}, owner)
+			} else {
+				diskLock, err = lockLocalDisk(diskPath)
+			}
+			if err != nil {
+				lock.Unlock()
+				return nil, iodine.New(err, map[string]string{"disk": diskPath})
+			}
+			lock.locks = append(lock.locks, diskLock)
+		}
+	}
+	return lock, nil
+}
+
+// localDiskLock - lock file of a local disk, locked as long as it is open
+type localDiskLock struct {
+	file *os.File
+}
+
+// lockLocalDisk - lock the lock file of a local disk without waiting
+func lockLocalDisk(diskPath string) (DiskLock, error) {
+	if err := os.MkdirAll(diskPath, 0700); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	file, err := os.OpenFile(path.Join(diskPath, diskLockConfig), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, iodine.New(ErrDiskLocked, nil)
+		}
+		return nil, iodine.New(err, nil)
+	}
+	return localDiskLock{file: file}, nil
+}
+
+// Unlock - closing the lock file releases the lock
+func (l localDiskLock) Unlock() error {
+	if err := l.file.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// remoteDiskLock - lease on the lock of a remote disk, refreshed until unlocked
+type remoteDiskLock struct {
+	disk  remoteDisk
+	owner string
+	done  chan struct{}
+	once  *sync.Once
+}
+
+// lockRemoteDisk - lease the lock of a remote disk for owner
+func lockRemoteDisk(disk remoteDisk, owner string) (DiskLock, error) {
+	if err := disk.call("POST", "lock", url.Values{"owner": {owner}}, nil); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	l := remoteDiskLock{
+		disk:  disk,
+		owner: owner,
+		done:  make(chan struct{}),
+		once:  new(sync.Once),
+	}
+	go l.refresh()
+	return l, nil
+}
+
+// refresh - renew the lease well before it expires, until unlocked
+func (l remoteDiskLock) refresh() {
+	ticker := time.NewTicker(diskLockLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			if err := l.disk.call("POST", "lock", url.Values{"owner": {l.owner}}, nil); err != nil {
+				log.Error.Println(iodine.New(err, map[string]string{"disk": l.disk.GetPath()}))
+			}
+		}
+	}
+}
+
+// Unlock - stop refreshing the lease and release it
+func (l remoteDiskLock) Unlock() error {
+	l.once.Do(func() { close(l.done) })
+	if err := l.disk.call("DELETE", "lock", url.Values{"owner": {l.owner}}, nil); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
@@ -17,9 +17,13 @@
 package donut
 
 import (
+	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
 
 	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
 // node struct internal
@@ -28,6 +32,15 @@ type node struct {
 	disks    map[string]Disk
 }
 
+// nodeConfiguration - disks of a node in order, persisted on every disk of the node
+type nodeConfiguration struct {
+	Version  string
+	Hostname string
+	Disks    []string
+	DiskIDs  []string
+	Updated  time.Time
+}
+
 // NewNode - instantiates a new node
 func NewNode(hostname string) (Node, error) {
 	if hostname == "" {
@@ -66,12 +79,112 @@ func (n node) DetachDisk(disk Disk) error {
 	return nil
 }
 
-// SaveConfig - save node configuration
+// getDiskPaths - paths and ids of all disks in order
+func (n node) getDiskPaths() ([]string, []string) {
+	diskPaths := make([]string, len(n.disks))
+	diskIDs := make([]string, len(n.disks))
+	for diskPath, disk := range n.disks {
+		if disk.GetOrder() < len(diskPaths) {
+			diskPaths[disk.GetOrder()] = diskPath
+			diskIDs[disk.GetOrder()] = disk.GetID()
+		}
+	}
+	return diskPaths, diskIDs
+}
+
+// SaveConfig - save node configuration on every disk of the node
 func (n node) SaveConfig() error {
-	return errors.New("Not Implemented")
+	config := nodeConfiguration{
+		Version:  "1.0",
+		Hostname: n.hostname,
+		Updated:  time.Now().UTC(),
+	}
+	config.Disks, config.DiskIDs = n.getDiskPaths()
+	saved := 0
+	for _, disk := range n.disks {
+		if err := writeConfigFile(disk, nodeConfig, config); err != nil {
+			log.Error.Println(iodine.New(err, map[string]string{"disk": disk.GetPath(), "config": nodeConfig}))
+			continue
+		}
+		saved = saved + 1
+	}
+	if saved == 0 {
+		return iodine.New(errors.New("unable to save node config on any disk"), map[string]string{"hostname": n.hostname})
+	}
+	return nil
 }
 
-// LoadConfig - load node configuration from saved configs
+// LoadConfig - load node configuration from saved configs, verifying every saved disk is
+// attached in the same position. A node without saved configuration is left as attached
 func (n node) LoadConfig() error {
-	return errors.New("Not Implemented")
+	var config nodeConfiguration
+	for _, disk := range n.disks {
+		reader, err := disk.OpenFile(nodeConfig)
+		if err != nil {
+			continue
+		}
+		var diskConfig nodeConfiguration
+		jdec := json.NewDecoder(reader)
+		err = jdec.Decode(&diskConfig)
+		reader.Close()
+		if err != nil {
+			log.Error.Println(iodine.New(err, map[string]string{"disk": disk.GetPath(), "config": nodeConfig}))
+			continue
+		}
+		if diskConfig.Updated.After(config.Updated) {
+			config = diskConfig
+		}
+	}
+	if config.Updated.IsZero() {
+		return nil
+	}
+	errParams := map[string]string{"hostname": n.hostname}
+	if config.Hostname != n.hostname {
+		errParams["savedHostname"] = config.Hostname
+		return iodine.New(errors.New("node config belongs to another node"), errParams)
+	}
+	if _, err := verifyDiskOrder(n.disks, config.DiskIDs, config.Updated); err != nil {
+		return iodine.New(err, errParams)
+	}
+	return nil
+}
+
+// verifyDiskOrder - verify disks saved with diskIDs at time saved are attached in the same order,
+// disks attached since are ordered after them. A disk formatted after saved takes the place of
+// a missing disk, returns how many were replaced
+func verifyDiskOrder(disks map[string]Disk, diskIDs []string, saved time.Time) (int, error) {
+	attached := make(map[string]Disk)
+	ordered := make(map[int]Disk)
+	for _, disk := range disks {
+		attached[disk.GetID()] = disk
+		ordered[disk.GetOrder()] = disk
+	}
+	replaced := 0
+	for order, diskID := range diskIDs {
+		disk, ok := attached[diskID]
+		if !ok {
+			replacement, ok := ordered[order]
+			if !ok || !isReplacementDisk(replacement, saved) {
+				return 0, iodine.New(errors.New("disk missing from node"), map[string]string{"disk": diskID})
+			}
+			replaced = replaced + 1
+			continue
+		}
+		if disk.GetOrder() != order {
+			return 0, iodine.New(errors.New("disk order changed"), map[string]string{
+				"disk":  disk.GetPath(),
+				"order": strconv.Itoa(disk.GetOrder()),
+			})
+		}
+	}
+	return replaced, nil
+}
+
+// isReplacementDisk - true if a disk was formatted blank after saved, in place of a lost disk
+func isReplacementDisk(disk Disk, saved time.Time) bool {
+	format, err := readDiskFormat(disk)
+	if err != nil || format == nil {
+		return false
+	}
+	return format.Formatted.After(saved)
 }
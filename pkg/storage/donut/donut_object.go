@@ -17,54 +17,61 @@
 package donut
 
 import (
+	"encoding/json"
 	"errors"
 	"path"
-
-	"encoding/json"
-	"io/ioutil"
 )
 
 // object internal struct
 type object struct {
 	name                string
+	disk                Disk
 	objectPath          string
 	objectMetadata      map[string]string
 	donutObjectMetadata map[string]string
 }
 
-// NewObject - instantiate a new object
-func NewObject(objectName, p string) (Object, error) {
-	if objectName == "" {
+// NewObject - instantiate a new object, stored under p on disk
+func NewObject(objectName string, disk Disk, p string) (Object, error) {
+	if objectName == "" || disk == nil {
 		return nil, errors.New("invalid argument")
 	}
 	o := object{}
 	o.name = objectName
+	o.disk = disk
 	o.objectPath = path.Join(p, objectName)
 	return o, nil
 }
 
 func (o object) GetObjectMetadata() (map[string]string, error) {
-	objectMetadata := make(map[string]string)
-	objectMetadataBytes, err := ioutil.ReadFile(path.Join(o.objectPath, objectMetadataConfig))
+	objectMetadata, err := o.readMetadata(objectMetadataConfig)
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(objectMetadataBytes, &objectMetadata); err != nil {
-		return nil, err
-	}
 	o.objectMetadata = objectMetadata
 	return o.objectMetadata, nil
 }
 
 func (o object) GetDonutObjectMetadata() (map[string]string, error) {
-	donutObjectMetadata := make(map[string]string)
-	donutObjectMetadataBytes, err := ioutil.ReadFile(path.Join(o.objectPath, donutObjectMetadataConfig))
+	donutObjectMetadata, err := o.readMetadata(donutObjectMetadataConfig)
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(donutObjectMetadataBytes, &donutObjectMetadata); err != nil {
-		return nil, err
-	}
 	o.donutObjectMetadata = donutObjectMetadata
 	return o.donutObjectMetadata, nil
 }
+
+// readMetadata - read a metadata file of the object
+func (o object) readMetadata(config string) (map[string]string, error) {
+	reader, err := o.disk.OpenFile(path.Join(o.objectPath, config))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	metadata := make(map[string]string)
+	jdec := json.NewDecoder(reader)
+	if err := jdec.Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+/// This file contains the per disk pipelines, every slice is written and read in its own goroutine
+
+// sliceQueueDepth - encoded blocks buffered per slice, between encoding and disk I/O
+const sliceQueueDepth = 2
+
+// chunkBufferPool - buffers chunks are encoded in, reused once every slice wrote its block
+var chunkBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// getChunkBuffer - buffer of at least size bytes capacity, empty
+func getChunkBuffer(size int) *[]byte {
+	buffer := chunkBufferPool.Get().(*[]byte)
+	if cap(*buffer) < size {
+		*buffer = make([]byte, 0, size)
+	}
+	*buffer = (*buffer)[:0]
+	return buffer
+}
+
+// encodedChunk - encoded blocks of a chunk, one for every slice
+type encodedChunk struct {
+	blocks [][]byte
+	// data blocks point into buffer, returned to the pool once written by all slices
+	buffer  *[]byte
+	pending int32
+}
+
+// release - a slice is done with the chunk
+func (c *encodedChunk) release() {
+	if atomic.AddInt32(&c.pending, -1) == 0 && c.buffer != nil {
+		chunkBufferPool.Put(c.buffer)
+	}
+}
+
+// sliceWriters - write encoded chunks to every slice, each slice in its own goroutine. A slice
+// failing to write is dropped, blocks queued for it are discarded
+type sliceWriters struct {
+	bucket  string
+	writers []io.WriteCloser
+	queues  []chan *encodedChunk
+	failed  []int32
+	wg      *sync.WaitGroup
+}
+
+// newSliceWriters - start a writer goroutine for every slice writer, nil writers are skipped
+func newSliceWriters(bucketName string, writers []io.WriteCloser) *sliceWriters {
+	s := &sliceWriters{
+		bucket:  bucketName,
+		writers: writers,
+		queues:  make([]chan *encodedChunk, len(writers)),
+		failed:  make([]int32, len(writers)),
+		wg:      new(sync.WaitGroup),
+	}
+	for i, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		s.queues[i] = make(chan *encodedChunk, sliceQueueDepth)
+		s.wg.Add(1)
+		go s.writeSlice(i)
+	}
+	return s
+}
+
+// writeSlice - write every queued block of a single slice
+func (s *sliceWriters) writeSlice(i int) {
+	defer s.wg.Done()
+	for chunk := range s.queues[i] {
+		if atomic.LoadInt32(&s.failed[i]) == 0 {
+			if err := writeEncodedBlock(s.writers[i], chunk.blocks[i]); err != nil {
+				// dropped, reconstructed by heal later
+				log.Error.Println(iodine.New(err, map[string]string{"bucket": s.bucket, "slice": strconv.Itoa(i)}))
+				atomic.StoreInt32(&s.failed[i], 1)
+			}
+		}
+		chunk.release()
+	}
+}
+
+// write - queue a chunk on every slice, blocks while the queue of any slice is full
+func (s *sliceWriters) write(chunk *encodedChunk) {
+	chunk.pending = 1
+	for _, queue := range s.queues {
+		if queue != nil {
+			atomic.AddInt32(&chunk.pending, 1)
+			queue <- chunk
+		}
+	}
+	chunk.release()
+}
+
+// healthy - number of slices not failed so far
+func (s *sliceWriters) healthy() int {
+	healthy := 0
+	for i, queue := range s.queues {
+		if queue != nil && atomic.LoadInt32(&s.failed[i]) == 0 {
+			healthy = healthy + 1
+		}
+	}
+	return healthy
+}
+
+// close - wait for all queued chunks to be written, writers of failed slices are closed and
+// set to nil
+func (s *sliceWriters) close() {
+	for _, queue := range s.queues {
+		if queue != nil {
+			close(queue)
+		}
+	}
+	s.wg.Wait()
+	for i := range s.writers {
+		if s.writers[i] != nil && s.failed[i] == 1 {
+			s.writers[i].Close()
+			s.writers[i] = nil
+		}
+	}
+}
+
+// syncSlices - sync and close every slice writer in its own goroutine, writers are set to nil.
+// Returns the slices which are durable on disk
+func syncSlices(bucketName string, writers []io.WriteCloser) []bool {
+	healthySlices := make([]bool, len(writers))
+	var wg sync.WaitGroup
+	for i, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		writers[i] = nil
+		wg.Add(1)
+		go func(i int, writer io.WriteCloser) {
+			defer wg.Done()
+			if err := syncAndClose(writer); err != nil {
+				log.Error.Println(iodine.New(err, map[string]string{"bucket": bucketName, "slice": strconv.Itoa(i)}))
+				return
+			}
+			healthySlices[i] = true
+		}(i, writer)
+	}
+	wg.Wait()
+	return healthySlices
+}
+
+// sliceBlock - an encoded block read from a slice
+type sliceBlock struct {
+	block []byte
+	err   error
+}
+
+// sliceReaders - read encoded blocks of every slice ahead of decoding, each slice in its own
+// goroutine
+type sliceReaders struct {
+	queues []chan sliceBlock
+	done   chan struct{}
+}
+
+// newSliceReaders - start a reader goroutine for every slice reader, reading blocks of the given
+// lengths in order. nil readers are skipped
+func newSliceReaders(readers []io.ReadCloser, blockLens []int, checksummed bool) *sliceReaders {
+	s := &sliceReaders{
+		queues: make([]chan sliceBlock, len(readers)),
+		done:   make(chan struct{}),
+	}
+	for i, reader := range readers {
+		if reader == nil {
+			continue
+		}
+		s.queues[i] = make(chan sliceBlock, sliceQueueDepth)
+		go s.readSlice(reader, s.queues[i], blockLens, checksummed)
+	}
+	return s
+}
+
+// readSlice - read all blocks of a single slice, stops at the first error other than bitrot
+func (s *sliceReaders) readSlice(reader io.Reader, queue chan<- sliceBlock, blockLens []int, checksummed bool) {
+	defer close(queue)
+	for _, blockLen := range blockLens {
+		block, err := readEncodedBlock(reader, blockLen, checksummed)
+		select {
+		case queue <- sliceBlock{block: block, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil && iodine.ToError(err) != errBitrot {
+			return
+		}
+	}
+}
+
+// next - next block of a slice, ok is false if the slice has no more blocks to offer
+func (s *sliceReaders) next(i int) (sliceBlock, bool) {
+	if s.queues[i] == nil {
+		return sliceBlock{}, false
+	}
+	block, ok := <-s.queues[i]
+	if !ok {
+		s.queues[i] = nil
+	}
+	return block, ok
+}
+
+// drop - stop offering blocks of a slice
+func (s *sliceReaders) drop(i int) {
+	s.queues[i] = nil
+}
+
+// close - stop reading ahead, slice readers are expected to be closed by the caller
+func (s *sliceReaders) close() {
+	close(s.done)
+}
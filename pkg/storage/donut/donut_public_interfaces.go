@@ -16,7 +16,10 @@
 
 package donut
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Collection of Donut specification interfaces
 
@@ -32,25 +35,71 @@ type ObjectStorage interface {
 	GetBucketMetadata(bucket string) (map[string]string, error)
 	SetBucketMetadata(bucket string, metadata map[string]string) error
 	ListBuckets() ([]string, error)
-	MakeBucket(bucket, acl string) error
+	MakeBucket(bucket, acl, storageClass string) error
 
 	// Bucket Operations
 	ListObjects(bucket, prefix, marker, delim string, maxKeys int) (result []string, prefixes []string, isTruncated bool, err error)
 
 	// Object Operations
 	GetObject(bucket, object string) (io.ReadCloser, int64, error)
+	GetPartialObject(bucket, object string, start, length int64) (io.ReadCloser, error)
 	GetObjectMetadata(bucket, object string) (map[string]string, error)
+	SetObjectMetadata(bucket, object string, metadata map[string]string) error
 	PutObject(bucket, object, expectedMD5Sum string, reader io.ReadCloser, metadata map[string]string) error
 }
 
+// HealReport - outcome of healing all objects in a donut
+type HealReport struct {
+	Healed        int // objects with missing or unreadable slices reconstructed
+	Failed        int // objects which could not be healed due to an error
+	Unrecoverable int // objects with too many slices lost to be reconstructed
+}
+
+// ScrubStats - outcome of a scrub pass verifying every block of all objects in a donut
+type ScrubStats struct {
+	Started       time.Time
+	Completed     time.Time
+	Objects       int // objects verified
+	BitrotSlices  int // slices found with blocks failing their checksum
+	Healed        int // objects with corrupted, missing or unreadable slices reconstructed
+	Failed        int // objects which could not be healed due to an error
+	Unrecoverable int // objects with too many slices lost to be reconstructed
+}
+
+// RebalanceStats - outcome of re-encoding objects onto all disks after more disks were attached
+type RebalanceStats struct {
+	Started   time.Time
+	Completed time.Time
+	Objects   int // objects inspected
+	Migrated  int // objects re-encoded onto all disks
+	Failed    int // objects which could not be migrated due to an error
+}
+
+// DonutInfo - donut configuration and status
+type DonutInfo struct {
+	Disks            map[string][]string            // disks of every node, in order
+	FSInfo           map[string][]map[string]string // filesystem and usage of every disk, in order
+	DiskReserve      int                            // percentage of every disk kept free
+	LastScrub        ScrubStats                     // last completed scrub pass
+	CurrentScrub     ScrubStats                     // scrub pass in progress, zero if none
+	LastRebalance    RebalanceStats                 // last completed rebalance
+	CurrentRebalance RebalanceStats                 // rebalance in progress, zero if none
+}
+
 // Management is a donut management system interface
 type Management interface {
-	Heal() error
+	Heal() (HealReport, error)
 	Rebalance() error
-	Info() (map[string][]string, error)
+	Info() (DonutInfo, error)
+
+	Scrub() (ScrubStats, error)
+	StartScrubber() error
+	StopScrubber()
 
 	AttachNode(node Node) error
 	DetachNode(node Node) error
+	ListTooWideObjects() ([]string, error)
+	SetDiskReserve(percent int) error
 
 	SaveConfig() error
 	LoadConfig() error
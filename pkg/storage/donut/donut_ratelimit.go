@@ -0,0 +1,86 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter - throttles background I/O to a fixed number of bytes per second
+type rateLimiter struct {
+	bytesPerSecond int64
+	lock           *sync.Mutex
+	start          time.Time
+	bytes          int64
+}
+
+// newRateLimiter - instantiate a new rate limiter, bytesPerSecond <= 0 disables throttling
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		bytesPerSecond: bytesPerSecond,
+		lock:           new(sync.Mutex),
+	}
+}
+
+// wait - account for n bytes of I/O, blocks until they fit within the configured rate
+func (r *rateLimiter) wait(n int) {
+	if r == nil || r.bytesPerSecond <= 0 || n <= 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	// start a new window after being idle, sleeping time is never carried over
+	if r.bytes == 0 || now.Sub(r.start) > r.allowance()+time.Second {
+		r.start = now
+		r.bytes = 0
+	}
+	r.bytes = r.bytes + int64(n)
+	if sleep := r.allowance() - now.Sub(r.start); sleep > 0 {
+		time.Sleep(sleep)
+	}
+}
+
+// allowance - time the bytes accounted so far are allowed to take
+func (r *rateLimiter) allowance() time.Duration {
+	return time.Duration(float64(r.bytes) / float64(r.bytesPerSecond) * float64(time.Second))
+}
+
+// rateLimitedReader - reader throttled by a rate limiter
+type rateLimitedReader struct {
+	reader  io.Reader
+	limiter *rateLimiter
+}
+
+func (r rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.limiter.wait(n)
+	return n, err
+}
+
+// rateLimitedWriter - writer throttled by a rate limiter
+type rateLimitedWriter struct {
+	writer  io.Writer
+	limiter *rateLimiter
+}
+
+func (w rateLimitedWriter) Write(p []byte) (int, error) {
+	w.limiter.wait(len(p))
+	return w.writer.Write(p)
+}
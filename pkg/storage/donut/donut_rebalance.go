@@ -17,40 +17,380 @@
 package donut
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
-// Rebalance -
+/// This file contains rebalancing, re-encoding objects onto disks attached after they were written
+/// and moving objects onto the erasure sets they are placed on
+
+// rebalancing is throttled, objects keep being served while migrated
+const (
+	rebalanceBandwidth    = 32 * 1024 * 1024
+	rebalanceSaveInterval = 30 * time.Second
+)
+
+// rebalanceProgress - position and statistics of rebalancing, persisted on every disk
+type rebalanceProgress struct {
+	// number of disks of all erasure sets objects were spread over by the last completed rebalance
+	Width int
+	// last object handled by the rebalance in progress
+	Bucket  string
+	Object  string
+	Current RebalanceStats
+	Last    RebalanceStats
+	Updated time.Time
+}
+
+// rebalancer internal struct
+type rebalancer struct {
+	// protects progress and loaded
+	lock     *sync.Mutex
+	progress rebalanceProgress
+	loaded   bool
+	// only a single rebalance runs at any time
+	passLock *sync.Mutex
+	limiter  *rateLimiter
+}
+
+// newRebalancer - instantiate a new rebalancer
+func newRebalancer() *rebalancer {
+	return &rebalancer{
+		lock:     new(sync.Mutex),
+		passLock: new(sync.Mutex),
+		limiter:  newRateLimiter(rebalanceBandwidth),
+	}
+}
+
+// getStats - statistics of the last completed rebalance and the rebalance in progress
+func (r *rebalancer) getStats() (last RebalanceStats, current RebalanceStats) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.progress.Last, r.progress.Current
+}
+
+// add - accumulate statistics of another rebalance
+func (s *RebalanceStats) add(stats RebalanceStats) {
+	s.Objects = s.Objects + stats.Objects
+	s.Migrated = s.Migrated + stats.Migrated
+	s.Failed = s.Failed + stats.Failed
+}
+
+// getDonutWidth - number of disks of all erasure sets
+func (d donut) getDonutWidth() (int, error) {
+	donutDisks, err := getDonutDisks(d.getNodes())
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	return len(donutDisks), nil
+}
+
+// Rebalance - re-encode objects written before more disks were attached onto all disks of their
+// erasure set, moving objects placed on another set since, in lexical order of buckets and
+// objects. Objects keep being served from the disks they were written to until migrated, an
+// interrupted rebalance is resumed where it stopped
 func (d donut) Rebalance() error {
-	var totalOffSetLength int
-	var newDisks []Disk
-	var existingDirs []os.FileInfo
-	for _, node := range d.nodes {
-		disks, err := node.ListDisks()
+	r := d.rebalancer
+	r.passLock.Lock()
+	defer r.passLock.Unlock()
+	if err := d.loadRebalanceProgress(); err != nil {
+		return iodine.New(err, nil)
+	}
+	width, err := d.getDonutWidth()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	r.lock.Lock()
+	if r.progress.Current.Started.IsZero() {
+		// no disks were attached since the last rebalance completed
+		if r.progress.Width == width {
+			r.lock.Unlock()
+			return nil
+		}
+		r.progress.Current = RebalanceStats{Started: time.Now().UTC()}
+		r.progress.Bucket = ""
+		r.progress.Object = ""
+	}
+	resumeBucket, resumeObject := r.progress.Bucket, r.progress.Object
+	r.lock.Unlock()
+	// record the rebalance as started, so that a crash resumes it
+	if err := d.saveRebalanceProgress(); err != nil {
+		return iodine.New(err, nil)
+	}
+
+	if err := d.getDonutBuckets(); err != nil {
+		return iodine.New(err, nil)
+	}
+	// buckets made meanwhile are picked up by the next pass
+	bucketNames := d.getBucketNames()
+
+	lastSaved := time.Now()
+	for _, bucketName := range bucketNames {
+		if bucketName < resumeBucket {
+			continue
+		}
+		marker := ""
+		if bucketName == resumeBucket {
+			marker = resumeObject
+		}
+		bucket, _ := d.getBucket(bucketName)
+		err := bucket.Rebalance(marker, r.limiter, func(objectName string, stats RebalanceStats) bool {
+			r.lock.Lock()
+			r.progress.Bucket = bucketName
+			r.progress.Object = objectName
+			r.progress.Current.add(stats)
+			r.lock.Unlock()
+			if time.Since(lastSaved) > rebalanceSaveInterval {
+				if err := d.saveRebalanceProgress(); err != nil {
+					log.Error.Println(iodine.New(err, nil))
+				}
+				lastSaved = time.Now()
+			}
+			return true
+		})
 		if err != nil {
 			return iodine.New(err, nil)
 		}
-		totalOffSetLength = len(disks)
-		fmt.Println(totalOffSetLength)
-		for _, disk := range disks {
-			dirs, err := disk.ListDir(d.name)
-			if err != nil {
-				return iodine.New(err, nil)
-			}
-			if len(dirs) == 0 {
-				newDisks = append(newDisks, disk)
+	}
+	r.lock.Lock()
+	r.progress.Current.Completed = time.Now().UTC()
+	r.progress.Last = r.progress.Current
+	r.progress.Current = RebalanceStats{}
+	r.progress.Bucket = ""
+	r.progress.Object = ""
+	// objects which failed to migrate are retried once more disks are attached, or by
+	// running rebalance again after they were healed
+	if r.progress.Last.Failed == 0 {
+		r.progress.Width = width
+	}
+	r.lock.Unlock()
+	if err := d.saveRebalanceProgress(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// saveRebalanceProgress - persist rebalance progress on every disk
+func (d donut) saveRebalanceProgress() error {
+	d.rebalancer.lock.Lock()
+	d.rebalancer.progress.Updated = time.Now().UTC()
+	progress := d.rebalancer.progress
+	d.rebalancer.lock.Unlock()
+	if err := d.saveDonutConfig(rebalanceProgressConfig, progress); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// loadRebalanceProgress - load most recently saved rebalance progress, only once
+func (d donut) loadRebalanceProgress() error {
+	d.rebalancer.lock.Lock()
+	defer d.rebalancer.lock.Unlock()
+	if d.rebalancer.loaded {
+		return nil
+	}
+	err := d.loadDonutConfig(rebalanceProgressConfig, func(jdec *json.Decoder) error {
+		var progress rebalanceProgress
+		if err := jdec.Decode(&progress); err != nil {
+			return iodine.New(err, nil)
+		}
+		if progress.Updated.After(d.rebalancer.progress.Updated) {
+			d.rebalancer.progress = progress
+		}
+		return nil
+	})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	d.rebalancer.loaded = true
+	return nil
+}
+
+// Rebalance - migrate every object after marker in lexical order spanning fewer disks than its
+// erasure set has, or held by another set than the one it is placed on. rebalanced is called after
+// every object with its outcome, returning false stops the rebalance
+func (b bucket) Rebalance(marker string, limiter *rateLimiter, rebalanced func(objectName string, stats RebalanceStats) bool) error {
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	ring := newPlacementRing(sets)
+	b.lock.RLock()
+	objectNames, objectSets := b.listObjectSlices(sets)
+	b.lock.RUnlock()
+	for _, objectName := range objectNames {
+		if objectName <= marker {
+			continue
+		}
+		target := sets[ring.getErasureSet(b.name, objectName)]
+		if !rebalanced(objectName, b.rebalanceObject(target, objectSets[objectName], objectName, limiter)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// rebalanceObject - migrate an object onto all disks of the erasure set it is placed on if needed
+func (b bucket) rebalanceObject(target erasureSet, holding []erasureSet, objectName string, limiter *rateLimiter) RebalanceStats {
+	stats := RebalanceStats{Objects: 1}
+	migrated, err := b.migrateObject(target, holding, objectName, limiter)
+	switch {
+	case err != nil:
+		log.Error.Println(iodine.New(err, map[string]string{"bucket": b.name, "object": objectName}))
+		stats.Failed = 1
+	case migrated:
+		stats.Migrated = 1
+	}
+	return stats
+}
+
+// migrateObject - decode the most recent copy of an object held by the given erasure sets and
+// encode it again onto all disks of the target set, if it spans fewer disks or is held by another
+// set. The object is read and staged without blocking writers, it is only swapped in if it was
+// not overwritten meanwhile. Copies left on other sets are removed once the target set holds the
+// most recent one. Returns true if the object was migrated
+func (b bucket) migrateObject(target erasureSet, holding []erasureSet, objectName string, limiter *rateLimiter) (bool, error) {
+	b.lock.RLock()
+	source, inspection, targetMetadata, err := b.inspectObjectCopies(target, holding, objectName)
+	if err != nil {
+		b.lock.RUnlock()
+		return false, iodine.New(err, nil)
+	}
+	if source.id == target.id && len(inspection.slices) == len(target.disks) {
+		b.lock.RUnlock()
+		if len(holding) == 1 {
+			return false, nil
+		}
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if err := b.removeObjectCopies(target, holding, objectName, inspection.objectMetadata["object"]); err != nil {
+			return false, iodine.New(err, nil)
+		}
+		return false, nil
+	}
+	// opened slices stay readable even if the object is replaced while being read
+	readers, err := b.getDiskReaders(source, objectName, "data")
+	if err != nil {
+		b.lock.RUnlock()
+		return false, iodine.New(err, nil)
+	}
+	readers, err = trimObjectReaders(readers, inspection.donutObjectMetadata)
+	if err != nil {
+		b.lock.RUnlock()
+		return false, iodine.New(err, nil)
+	}
+	b.lock.RUnlock()
+	if err := b.verifyReadQuorum(objectName, readers, inspection.donutObjectMetadata); err != nil {
+		for _, reader := range readers {
+			if reader != nil {
+				reader.Close()
 			}
-			existingDirs = append(existingDirs, dirs...)
 		}
+		return false, iodine.New(err, nil)
 	}
-	for _, dir := range existingDirs {
-		splits := strings.Split(dir.Name(), "$")
-		bucketName, segment, offset := splits[0], splits[1], splits[2]
-		fmt.Println(bucketName, segment, offset)
+	reader, writer := io.Pipe()
+	go b.readEncodedData(readers, writer, inspection.donutObjectMetadata)
+	defer reader.Close()
+
+	stagingName, err := newStagingName()
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	defer b.removeStagedObject(stagingName)
+	objectData := rateLimitedReader{reader: reader, limiter: limiter}
+	// re-encoded with the storage class it was written with
+	storageClass := map[string]string{"storageClass": inspection.donutObjectMetadata["sys.storageClass"]}
+	healthySlices, writeQuorum, err := b.writeStagedObject(target, stagingName, inspection.objectMetadata["object"], objectData, inspection.objectMetadata["md5"], storageClass)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	// only the layout of the object changes, its metadata is kept as it was
+	objectMetadataWriters, err := b.getStagingWriters(target, stagingName, objectMetadataConfig, healthySlices)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	for i, objectMetadataWriter := range objectMetadataWriters {
+		if healthySlices[i] && objectMetadataWriter == nil {
+			return false, iodine.New(errors.New("unable to stage object metadata"), nil)
+		}
+	}
+	if err := b.writeObjectMetadata(objectMetadataWriters, inspection.objectMetadata); err != nil {
+		return false, iodine.New(err, nil)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	// an object overwritten while being migrated was written onto the target set
+	var currentMetadata map[string]string
+	if current, err := b.inspectObject(target, objectName, nil, false); err == nil {
+		currentMetadata = current.objectMetadata
+	}
+	if currentMetadata["created"] != targetMetadata["created"] || currentMetadata["md5"] != targetMetadata["md5"] {
+		return false, nil
+	}
+	if err := b.commitStagedObject(target, stagingName, objectName, healthySlices, writeQuorum); err != nil {
+		return false, iodine.New(err, nil)
+	}
+	if err := b.removeObjectCopies(target, holding, objectName, inspection.objectMetadata["object"]); err != nil {
+		return false, iodine.New(err, nil)
+	}
+	return true, nil
+}
+
+// inspectObjectCopies - find the most recently written copy of an object among the erasure sets
+// holding it, a copy on the target set is preferred over an equally recent one. Returns the set
+// holding it along with its inspection, and the object metadata of the copy on the target set if
+// any. Caller is expected to hold the bucket lock
+func (b bucket) inspectObjectCopies(target erasureSet, holding []erasureSet, objectName string) (erasureSet, objectInspection, map[string]string, error) {
+	var source erasureSet
+	var inspection objectInspection
+	var targetMetadata map[string]string
+	var created time.Time
+	var lastErr error
+	for _, set := range holding {
+		setInspection, err := b.inspectObject(set, objectName, nil, false)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if set.id == target.id {
+			targetMetadata = setInspection.objectMetadata
+		}
+		setCreated, _ := time.Parse(time.RFC3339Nano, setInspection.objectMetadata["created"])
+		if inspection.objectMetadata == nil || setCreated.After(created) || (setCreated.Equal(created) && set.id == target.id) {
+			source = set
+			inspection = setInspection
+			created = setCreated
+		}
+	}
+	if inspection.objectMetadata == nil {
+		return source, inspection, nil, iodine.New(lastErr, nil)
+	}
+	return source, inspection, targetMetadata, nil
+}
+
+// removeObjectCopies - remove copies of an object from all erasure sets holding it other than the
+// target set, unindexed before their slices are removed. Caller is expected to hold the bucket lock
+func (b bucket) removeObjectCopies(target erasureSet, holding []erasureSet, objectName, indexedName string) error {
+	for _, set := range holding {
+		if set.id == target.id {
+			continue
+		}
+		if err := b.unindexObject(set, indexedName); err != nil {
+			return iodine.New(err, nil)
+		}
+		for _, slice := range b.getObjectSlices(set, objectName) {
+			// best effort, leftovers are found and removed by the next rebalance
+			if err := slice.disk.RemoveAll(slice.objectPath); err != nil {
+				log.Error.Println(iodine.New(err, map[string]string{"bucket": b.name, "object": objectName}))
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,313 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
+)
+
+/// This file contains the background scrubber, verifying every block of all objects
+
+// scrubber runs at low priority, throttled and only every so often
+const (
+	scrubBandwidth     = 8 * 1024 * 1024
+	scrubInterval      = 7 * 24 * time.Hour
+	scrubRetryInterval = time.Hour
+	scrubStartDelay    = time.Minute
+	scrubSaveInterval  = 30 * time.Second
+)
+
+// errScrubStopped - scrub pass was interrupted, it is resumed by the next pass
+var errScrubStopped = errors.New("scrub stopped")
+
+// scrubProgress - position and statistics of scrubbing, persisted on every disk
+type scrubProgress struct {
+	// last object verified by the pass in progress
+	Bucket  string
+	Object  string
+	Current ScrubStats
+	Last    ScrubStats
+	Updated time.Time
+}
+
+// scrubber internal struct
+type scrubber struct {
+	// protects progress, loaded and stop
+	lock     *sync.Mutex
+	progress scrubProgress
+	loaded   bool
+	stop     chan struct{}
+	// only a single pass runs at any time
+	passLock *sync.Mutex
+	limiter  *rateLimiter
+}
+
+// newScrubber - instantiate a new scrubber
+func newScrubber() *scrubber {
+	return &scrubber{
+		lock:     new(sync.Mutex),
+		passLock: new(sync.Mutex),
+		limiter:  newRateLimiter(scrubBandwidth),
+	}
+}
+
+// getStats - statistics of the last completed pass and the pass in progress
+func (s *scrubber) getStats() (last ScrubStats, current ScrubStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.progress.Last, s.progress.Current
+}
+
+// add - accumulate statistics of another scrub
+func (s *ScrubStats) add(stats ScrubStats) {
+	s.Objects = s.Objects + stats.Objects
+	s.BitrotSlices = s.BitrotSlices + stats.BitrotSlices
+	s.Healed = s.Healed + stats.Healed
+	s.Failed = s.Failed + stats.Failed
+	s.Unrecoverable = s.Unrecoverable + stats.Unrecoverable
+}
+
+// Scrub - run a scrub pass over all objects now, an interrupted pass is resumed
+func (d donut) Scrub() (ScrubStats, error) {
+	stats, err := d.scrubPass(nil)
+	if err != nil {
+		return ScrubStats{}, iodine.New(err, nil)
+	}
+	return stats, nil
+}
+
+// StartScrubber - scrub all objects in the background, a pass is run every scrubInterval
+func (d donut) StartScrubber() error {
+	d.scrubber.lock.Lock()
+	defer d.scrubber.lock.Unlock()
+	if d.scrubber.stop != nil {
+		return iodine.New(errors.New("scrubber already running"), nil)
+	}
+	d.scrubber.stop = make(chan struct{})
+	go d.runScrubber(d.scrubber.stop)
+	return nil
+}
+
+// StopScrubber - stop background scrubbing, progress of an interrupted pass is saved
+func (d donut) StopScrubber() {
+	d.scrubber.lock.Lock()
+	defer d.scrubber.lock.Unlock()
+	if d.scrubber.stop != nil {
+		close(d.scrubber.stop)
+		d.scrubber.stop = nil
+	}
+}
+
+// runScrubber - run scrub passes until stopped
+func (d donut) runScrubber(stop <-chan struct{}) {
+	delay := scrubStartDelay
+	if err := d.loadScrubProgress(); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+	}
+	last, current := d.scrubber.getStats()
+	// resume an interrupted pass right away, otherwise wait for the next one to be due
+	if current.Started.IsZero() && !last.Completed.IsZero() {
+		if due := last.Completed.Add(scrubInterval).Sub(time.Now()); due > delay {
+			delay = due
+		}
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		_, err := d.scrubPass(stop)
+		switch {
+		case err == nil:
+			delay = scrubInterval
+		case iodine.ToError(err) == errScrubStopped:
+			return
+		default:
+			log.Error.Println(iodine.New(err, nil))
+			delay = scrubRetryInterval
+		}
+	}
+}
+
+// scrubPass - verify every object in lexical order of buckets and objects, starting after
+// the last object verified by an interrupted pass. Progress is saved periodically, a pass
+// stopped through stop returns errScrubStopped
+func (d donut) scrubPass(stop <-chan struct{}) (ScrubStats, error) {
+	s := d.scrubber
+	s.passLock.Lock()
+	defer s.passLock.Unlock()
+	if err := d.loadScrubProgress(); err != nil {
+		return ScrubStats{}, iodine.New(err, nil)
+	}
+	s.lock.Lock()
+	if s.progress.Current.Started.IsZero() {
+		s.progress.Current = ScrubStats{Started: time.Now().UTC()}
+		s.progress.Bucket = ""
+		s.progress.Object = ""
+	}
+	resumeBucket, resumeObject := s.progress.Bucket, s.progress.Object
+	s.lock.Unlock()
+
+	if err := d.getDonutBuckets(); err != nil {
+		return ScrubStats{}, iodine.New(err, nil)
+	}
+	// buckets made meanwhile are picked up by the next pass
+	bucketNames := d.getBucketNames()
+
+	lastSaved := time.Now()
+	stopped := false
+	for _, bucketName := range bucketNames {
+		if bucketName < resumeBucket {
+			continue
+		}
+		marker := ""
+		if bucketName == resumeBucket {
+			marker = resumeObject
+		}
+		bucket, _ := d.getBucket(bucketName)
+		err := bucket.Scrub(marker, s.limiter, func(objectName string, stats ScrubStats) bool {
+			s.lock.Lock()
+			s.progress.Bucket = bucketName
+			s.progress.Object = objectName
+			s.progress.Current.add(stats)
+			s.lock.Unlock()
+			if time.Since(lastSaved) > scrubSaveInterval {
+				if err := d.saveScrubProgress(); err != nil {
+					log.Error.Println(iodine.New(err, nil))
+				}
+				lastSaved = time.Now()
+			}
+			select {
+			case <-stop:
+				stopped = true
+				return false
+			default:
+				return true
+			}
+		})
+		if err != nil {
+			return ScrubStats{}, iodine.New(err, nil)
+		}
+		if stopped {
+			if err := d.saveScrubProgress(); err != nil {
+				return ScrubStats{}, iodine.New(err, nil)
+			}
+			return ScrubStats{}, iodine.New(errScrubStopped, nil)
+		}
+	}
+	s.lock.Lock()
+	s.progress.Current.Completed = time.Now().UTC()
+	s.progress.Last = s.progress.Current
+	s.progress.Current = ScrubStats{}
+	s.progress.Bucket = ""
+	s.progress.Object = ""
+	stats := s.progress.Last
+	s.lock.Unlock()
+	if err := d.saveScrubProgress(); err != nil {
+		return ScrubStats{}, iodine.New(err, nil)
+	}
+	return stats, nil
+}
+
+// saveScrubProgress - persist scrub progress on every disk
+func (d donut) saveScrubProgress() error {
+	d.scrubber.lock.Lock()
+	d.scrubber.progress.Updated = time.Now().UTC()
+	progress := d.scrubber.progress
+	d.scrubber.lock.Unlock()
+	if err := d.saveDonutConfig(scrubProgressConfig, progress); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// loadScrubProgress - load most recently saved scrub progress, only once
+func (d donut) loadScrubProgress() error {
+	d.scrubber.lock.Lock()
+	defer d.scrubber.lock.Unlock()
+	if d.scrubber.loaded {
+		return nil
+	}
+	err := d.loadDonutConfig(scrubProgressConfig, func(jdec *json.Decoder) error {
+		var progress scrubProgress
+		if err := jdec.Decode(&progress); err != nil {
+			return iodine.New(err, nil)
+		}
+		if progress.Updated.After(d.scrubber.progress.Updated) {
+			d.scrubber.progress = progress
+		}
+		return nil
+	})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	d.scrubber.loaded = true
+	return nil
+}
+
+// Scrub - verify every block of every object after marker in lexical order, healing objects
+// found with corrupted, missing or unreadable slices. scrubbed is called after every object
+// with its outcome, returning false stops the scrub
+func (b bucket) Scrub(marker string, limiter *rateLimiter, scrubbed func(objectName string, stats ScrubStats) bool) error {
+	sets, err := getErasureSets(b.nodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	b.lock.RLock()
+	objectNames, objectSets := b.listObjectSlices(sets)
+	b.lock.RUnlock()
+	for _, objectName := range objectNames {
+		if objectName <= marker {
+			continue
+		}
+		stats := ScrubStats{Objects: 1}
+		// an object left behind on another erasure set is verified there as well, until
+		// rebalance moves it
+		for _, set := range objectSets[objectName] {
+			stats.add(b.scrubObject(set, objectName, limiter))
+		}
+		if !scrubbed(objectName, stats) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// scrubObject - verify every block of an object on an erasure set, healing it if needed
+func (b bucket) scrubObject(set erasureSet, objectName string, limiter *rateLimiter) ScrubStats {
+	stats := ScrubStats{}
+	// verifying is slow, the bucket lock is only held to read metadata and swap healed slices in
+	healed, bitrotSlices, err := b.healObject(set, objectName, limiter, true)
+	stats.BitrotSlices = bitrotSlices
+	switch {
+	case err == nil && healed:
+		stats.Healed = 1
+	case err == nil:
+	case iodine.ToError(err) == errObjectUnrecoverable:
+		stats.Unrecoverable = 1
+	default:
+		stats.Failed = 1
+	}
+	return stats
+}
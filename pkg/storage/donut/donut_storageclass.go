@@ -0,0 +1,92 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"errors"
+
+	"github.com/minio-io/minio/pkg/iodine"
+)
+
+// storage classes an object can be written with, chosen per object or per bucket
+const (
+	StorageClassStandard          = "STANDARD"
+	StorageClassReducedRedundancy = "REDUCED_REDUNDANCY"
+)
+
+// sliceBlockSize - bytes every data slice holds of an encoded block, blocks grow with the number
+// of data slices so every disk reads and writes as much per block whatever the width of its set
+const sliceBlockSize = 1024 * 1024
+
+// storageClass - erasure coding of objects written with a storage class
+type storageClass struct {
+	// one parity slice for every disksPerParity disks of an erasure set, at least one
+	disksPerParity int
+	technique      string
+}
+
+var storageClasses = map[string]storageClass{
+	// survives losing half of the disks
+	StorageClassStandard: {
+		disksPerParity: 2,
+		technique:      "Cauchy",
+	},
+	// survives losing a quarter of the disks, for data which can be reproduced
+	StorageClassReducedRedundancy: {
+		disksPerParity: 4,
+		technique:      "Vandermonde",
+	},
+}
+
+// getStorageClass - storage class by name, unknown classes are invalid
+func getStorageClass(class string) (storageClass, error) {
+	sc, ok := storageClasses[class]
+	if !ok {
+		return storageClass{}, iodine.New(errors.New("invalid storage class"), map[string]string{"storageClass": class})
+	}
+	return sc, nil
+}
+
+// getDataAndParity - calculate k, m (data and parity) values of a storage class from number of disks.
+// Parity covers nodeWidth disks, the most disks on a single node, as long as it does not exceed data
+func (sc storageClass) getDataAndParity(totalWriters, nodeWidth int) (k uint8, m uint8, err error) {
+	if totalWriters <= 1 {
+		return 0, 0, iodine.New(errors.New("invalid argument"), nil)
+	}
+	parity := totalWriters / sc.disksPerParity
+	if parity < nodeWidth {
+		parity = nodeWidth
+	}
+	if parity > totalWriters/2 {
+		parity = totalWriters / 2
+	}
+	if parity < 1 {
+		parity = 1
+	}
+	// parity cannot be bigger than (255 / 2) = 127
+	if parity > 127 || totalWriters-parity > 255 {
+		return 0, 0, iodine.New(errors.New("parity over flow"), nil)
+	}
+	k = uint8(totalWriters - parity)
+	m = uint8(parity)
+	return k, m, nil
+}
+
+// getBlockSize - bytes of object data encoded at once into k data slices
+func getBlockSize(k uint8) int {
+	return int(k) * sliceBlockSize
+}
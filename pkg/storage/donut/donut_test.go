@@ -20,15 +20,24 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/minio-io/check"
+	encoding "github.com/minio-io/minio/pkg/erasure"
+	"github.com/minio-io/minio/pkg/iodine"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -75,10 +84,10 @@ func (s *MySuite) TestBucketWithoutNameFails(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 	// fail to create new bucket without a name
-	err = donut.MakeBucket("", "private")
+	err = donut.MakeBucket("", "private", "")
 	c.Assert(err, Not(IsNil))
 
-	err = donut.MakeBucket(" ", "private")
+	err = donut.MakeBucket(" ", "private", "")
 	c.Assert(err, Not(IsNil))
 }
 
@@ -90,7 +99,7 @@ func (s *MySuite) TestEmptyBucket(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 
-	c.Assert(donut.MakeBucket("foo", "private"), IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", ""), IsNil)
 	// check if bucket is empty
 	objects, _, istruncated, err := donut.ListObjects("foo", "", "", "", 1)
 	c.Assert(err, IsNil)
@@ -106,7 +115,7 @@ func (s *MySuite) TestMakeBucketAndList(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 	// create bucket
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	// check bucket exists
@@ -122,13 +131,31 @@ func (s *MySuite) TestMakeBucketWithSameNameFails(c *C) {
 	defer os.RemoveAll(root)
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, Not(IsNil))
 }
 
+// test a bucket failing to be made is not kept
+func (s *MySuite) TestMakeBucketFailureNotKept(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	nodeDiskMap := createTestNodeDiskMap(root)
+	d, err := NewDonut("test", nodeDiskMap)
+	c.Assert(err, IsNil)
+	// bucket metadata is staged first, a file in place of staging fails every write
+	for _, diskPath := range nodeDiskMap["localhost"] {
+		c.Assert(os.RemoveAll(path.Join(diskPath, stagingDir)), IsNil)
+		c.Assert(ioutil.WriteFile(path.Join(diskPath, stagingDir), nil, 0600), IsNil)
+	}
+	c.Assert(d.MakeBucket("foo", "private", ""), Not(IsNil))
+	_, ok := d.(donut).buckets["foo"]
+	c.Assert(ok, Equals, false)
+}
+
 // test make multiple buckets
 func (s *MySuite) TestCreateMultipleBucketsAndList(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
@@ -137,17 +164,17 @@ func (s *MySuite) TestCreateMultipleBucketsAndList(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 	// add a second bucket
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("bar", "private")
+	err = donut.MakeBucket("bar", "private", "")
 	c.Assert(err, IsNil)
 
 	buckets, err := donut.ListBuckets()
 	c.Assert(err, IsNil)
 	c.Assert(buckets, DeepEquals, []string{"bar", "foo"})
 
-	err = donut.MakeBucket("foobar", "private")
+	err = donut.MakeBucket("foobar", "private", "")
 	c.Assert(err, IsNil)
 
 	buckets, err = donut.ListBuckets()
@@ -185,7 +212,7 @@ func (s *MySuite) TestNewObjectMetadata(c *C) {
 	expectedMd5Sum := hex.EncodeToString(hasher.Sum(nil))
 	reader := ioutil.NopCloser(bytes.NewReader([]byte(data)))
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	err = donut.PutObject("foo", "obj", expectedMd5Sum, reader, metadata)
@@ -222,7 +249,7 @@ func (s *MySuite) TestNewObjectCanBeWritten(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", "")
 	c.Assert(err, IsNil)
 
 	metadata := make(map[string]string)
@@ -255,6 +282,59 @@ func (s *MySuite) TestNewObjectCanBeWritten(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// test overwrite, readers opened before the overwrite keep reading the old object
+func (s *MySuite) TestObjectOverwrite(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	metadata := make(map[string]string)
+	metadata["contentType"] = "application/octet-stream"
+
+	hasher := md5.New()
+	hasher.Write([]byte("one"))
+	err = donut.PutObject("foo", "obj", hex.EncodeToString(hasher.Sum(nil)), ioutil.NopCloser(bytes.NewReader([]byte("one"))), metadata)
+	c.Assert(err, IsNil)
+
+	oldReader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len("one")))
+
+	hasher = md5.New()
+	hasher.Write([]byte("three"))
+	err = donut.PutObject("foo", "obj", hex.EncodeToString(hasher.Sum(nil)), ioutil.NopCloser(bytes.NewReader([]byte("three"))), metadata)
+	c.Assert(err, IsNil)
+
+	var oldData bytes.Buffer
+	_, err = io.Copy(&oldData, oldReader)
+	c.Assert(err, IsNil)
+	c.Assert(oldData.String(), Equals, "one")
+
+	reader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len("three")))
+	var newData bytes.Buffer
+	_, err = io.Copy(&newData, reader)
+	c.Assert(err, IsNil)
+	c.Assert(newData.String(), Equals, "three")
+
+	// failed overwrite leaves the object intact
+	err = donut.PutObject("foo", "obj", hex.EncodeToString(hasher.Sum(nil)), ioutil.NopCloser(bytes.NewReader([]byte("four"))), metadata)
+	c.Assert(err, Not(IsNil))
+	actualMetadata, err := donut.GetObjectMetadata("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(actualMetadata["size"], Equals, strconv.Itoa(len("three")))
+
+	objects, _, _, err := donut.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj"})
+}
+
 // test list objects
 func (s *MySuite) TestMultipleNewObjects(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
@@ -263,7 +343,7 @@ func (s *MySuite) TestMultipleNewObjects(c *C) {
 	donut, err := NewDonut("test", createTestNodeDiskMap(root))
 	c.Assert(err, IsNil)
 
-	c.Assert(donut.MakeBucket("foo", "private"), IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", ""), IsNil)
 
 	one := ioutil.NopCloser(bytes.NewReader([]byte("one")))
 	err = donut.PutObject("foo", "obj1", "", one, nil)
@@ -293,12 +373,20 @@ func (s *MySuite) TestMultipleNewObjects(c *C) {
 
 	/// test list of objects
 
-	// test list objects with prefix and delimiter
+	// test list objects with prefix and delimiter, common prefixes count towards maxkeys
 	listObjects, prefixes, isTruncated, err := donut.ListObjects("foo", "o", "", "1", 1)
 	c.Assert(err, IsNil)
-	c.Assert(isTruncated, Equals, false)
+	c.Assert(isTruncated, Equals, true)
+	c.Assert(listObjects, IsNil)
 	c.Assert(prefixes[0], Equals, "obj1")
 
+	// test list objects resumes after a common prefix marker
+	listObjects, prefixes, isTruncated, err = donut.ListObjects("foo", "o", "obj1", "1", 1)
+	c.Assert(err, IsNil)
+	c.Assert(isTruncated, Equals, false)
+	c.Assert(listObjects, DeepEquals, []string{"obj2"})
+	c.Assert(prefixes, IsNil)
+
 	// test list objects with only delimiter
 	listObjects, prefixes, isTruncated, err = donut.ListObjects("foo", "", "", "1", 10)
 	c.Assert(err, IsNil)
@@ -331,3 +419,1688 @@ func (s *MySuite) TestMultipleNewObjects(c *C) {
 	c.Assert(isTruncated, Equals, true)
 	c.Assert(len(listObjects), Equals, 2)
 }
+
+// test heal of missing and corrupted slices
+func (s *MySuite) TestHealMissingSlices(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+
+	slicePath := func(disk int, file string) string {
+		return path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj", file)
+	}
+	expectedData3, err := ioutil.ReadFile(slicePath(3, "data"))
+	c.Assert(err, IsNil)
+	expectedData7, err := ioutil.ReadFile(slicePath(7, "data"))
+	c.Assert(err, IsNil)
+	expectedMetadata5, err := ioutil.ReadFile(slicePath(5, objectMetadataConfig))
+	c.Assert(err, IsNil)
+
+	// lose a slice, truncate another and lose metadata on a third disk
+	c.Assert(os.Remove(slicePath(3, "data")), IsNil)
+	c.Assert(os.Truncate(slicePath(7, "data"), 10), IsNil)
+	c.Assert(os.Remove(slicePath(5, objectMetadataConfig)), IsNil)
+
+	report, err := donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{Healed: 1})
+
+	actualData3, err := ioutil.ReadFile(slicePath(3, "data"))
+	c.Assert(err, IsNil)
+	c.Assert(actualData3, DeepEquals, expectedData3)
+	actualData7, err := ioutil.ReadFile(slicePath(7, "data"))
+	c.Assert(err, IsNil)
+	c.Assert(actualData7, DeepEquals, expectedData7)
+	actualMetadata5, err := ioutil.ReadFile(slicePath(5, objectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(actualMetadata5, DeepEquals, expectedMetadata5)
+
+	reader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(data)))
+	var actualData bytes.Buffer
+	_, err = io.Copy(&actualData, reader)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+
+	// nothing left to heal
+	report, err = donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{})
+}
+
+// test heal of a replaced disk
+func (s *MySuite) TestHealReplacedDisk(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = donut.PutObject("foo", "obj1", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	err = donut.PutObject("foo", "obj2", "", ioutil.NopCloser(bytes.NewReader([]byte("two"))), nil)
+	c.Assert(err, IsNil)
+
+	// replace disk with an empty one
+	c.Assert(os.RemoveAll(path.Join(root, "2")), IsNil)
+	c.Assert(os.MkdirAll(path.Join(root, "2"), 0700), IsNil)
+
+	report, err := donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{Healed: 2})
+
+	_, err = donut.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	for object, expectedData := range map[string]string{"obj1": "one", "obj2": "two"} {
+		reader, _, err := donut.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var actualData bytes.Buffer
+		_, err = io.Copy(&actualData, reader)
+		c.Assert(err, IsNil)
+		c.Assert(actualData.String(), Equals, expectedData)
+	}
+}
+
+// test a donut reopened with a replaced disk loads its config and heals
+func (s *MySuite) TestHealReplacedDiskReopen(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(d.MakeBucket("foo", "private", ""), IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	c.Assert(d.SaveConfig(), IsNil)
+
+	// replace disk with an empty one
+	c.Assert(os.RemoveAll(path.Join(root, "2")), IsNil)
+	c.Assert(os.MkdirAll(path.Join(root, "2"), 0700), IsNil)
+
+	reopened, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(reopened.LoadConfig(), IsNil)
+	report, err := reopened.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{Healed: 1})
+	reader, size, err := reopened.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "one")
+
+	// the replacement is recorded in the saved config
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(restarted.LoadConfig(), IsNil)
+
+	// without a majority of the saved disks left nothing can be healed from
+	for disk := 0; disk < 9; disk++ {
+		c.Assert(os.RemoveAll(path.Join(root, strconv.Itoa(disk))), IsNil)
+		c.Assert(os.MkdirAll(path.Join(root, strconv.Itoa(disk)), 0700), IsNil)
+	}
+	replaced, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(replaced.LoadConfig(), Not(IsNil))
+}
+
+// test heal of an object with more slices lost than parity
+func (s *MySuite) TestHealUnrecoverable(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+
+	// 16 disks carry 8 data and 8 parity slices
+	for disk := 0; disk < 9; disk++ {
+		err := os.Remove(path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj", "data"))
+		c.Assert(err, IsNil)
+	}
+	report, err := donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{Unrecoverable: 1})
+}
+
+// test reads with failed disks, up to M object slices may be lost
+func (s *MySuite) TestDegradedRead(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+
+	removeSlice := func(disk int) {
+		err := os.RemoveAll(path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj"))
+		c.Assert(err, IsNil)
+	}
+	// 16 disks carry 8 data and 8 parity slices, lose both data and parity slices
+	for _, disk := range []int{0, 3, 5, 7, 9, 11, 13, 15} {
+		removeSlice(disk)
+	}
+	reader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(data)))
+	var actualData bytes.Buffer
+	_, err = io.Copy(&actualData, reader)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+
+	objects, _, _, err := donut.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj"})
+
+	// one more slice lost, no longer recoverable
+	removeSlice(1)
+	_, _, err = donut.GetObject("foo", "obj")
+	c.Assert(err, Not(IsNil))
+}
+
+// test writes with failed disks, a write succeeds as long as K+1 slices are written
+func (s *MySuite) TestQuorumWrite(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	// a disk replaced by a regular file fails every write
+	failDisk := func(disk int) {
+		diskPath := path.Join(root, strconv.Itoa(disk))
+		c.Assert(os.RemoveAll(diskPath), IsNil)
+		c.Assert(ioutil.WriteFile(diskPath, nil, 0600), IsNil)
+	}
+	replaceDisk := func(disk int) {
+		diskPath := path.Join(root, strconv.Itoa(disk))
+		c.Assert(os.Remove(diskPath), IsNil)
+		c.Assert(os.MkdirAll(diskPath, 0700), IsNil)
+	}
+	for _, disk := range []int{1, 4, 9} {
+		failDisk(disk)
+	}
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+
+	donutObjectMetadata, err := ioutil.ReadFile(path.Join(root, "0", "test", "foo$0$0", "obj", donutObjectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(donutObjectMetadata), `"sys.slices":"0,2,3,5,6,7,8,10,11,12,13,14,15"`), Equals, true)
+
+	reader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(data)))
+	var actualData bytes.Buffer
+	_, err = io.Copy(&actualData, reader)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+
+	// heal finishes the write once disks are back
+	for _, disk := range []int{1, 4, 9} {
+		replaceDisk(disk)
+	}
+	report, err := donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{Healed: 1})
+	donutObjectMetadata, err = ioutil.ReadFile(path.Join(root, "4", "test", "foo$0$4", "obj", donutObjectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(donutObjectMetadata), `"sys.slices":"0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15"`), Equals, true)
+
+	// 16 disks carry 8 data and 8 parity slices, with 8 failed disks write quorum is lost
+	for _, disk := range []int{0, 2, 4, 6, 8, 10, 12, 14} {
+		failDisk(disk)
+	}
+	err = donut.PutObject("foo", "obj2", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, Not(IsNil))
+
+	// partial slices are rolled back
+	for _, disk := range []int{1, 3, 5, 7, 9, 11, 13, 15} {
+		_, err := os.Stat(path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj2"))
+		c.Assert(os.IsNotExist(err), Equals, true)
+		staged, err := ioutil.ReadDir(path.Join(root, strconv.Itoa(disk), stagingDir))
+		c.Assert(err, IsNil)
+		c.Assert(len(staged), Equals, 0)
+	}
+}
+
+// test reads with bitrot, corrupted blocks are reconstructed from parity
+func (s *MySuite) TestBitrotRead(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+
+	corruptSlice := func(disk int) {
+		slicePath := path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj", "data")
+		slice, err := ioutil.ReadFile(slicePath)
+		c.Assert(err, IsNil)
+		slice[100] = slice[100] ^ 0xff
+		c.Assert(ioutil.WriteFile(slicePath, slice, 0600), IsNil)
+	}
+	readObject := func() ([]byte, error) {
+		reader, _, err := donut.GetObject("foo", "obj")
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(reader)
+	}
+	for _, disk := range []int{2, 6, 10} {
+		corruptSlice(disk)
+	}
+	actualData, err := readObject()
+	c.Assert(err, IsNil)
+	c.Assert(actualData, DeepEquals, data)
+
+	// more corrupted blocks than parity
+	for _, disk := range []int{0, 1, 3, 4, 5, 7} {
+		corruptSlice(disk)
+	}
+	_, err = readObject()
+	c.Assert(err, Not(IsNil))
+}
+
+// test scrub, bitrot is found and healed and scrub progress is persisted
+func (s *MySuite) TestScrub(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = donut.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	for _, object := range []string{"obj1", "obj2", "obj3"} {
+		err = donut.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+		c.Assert(err, IsNil)
+	}
+
+	slicePath := path.Join(root, "3", "test", "foo$0$3", "obj2", "data")
+	expectedSlice, err := ioutil.ReadFile(slicePath)
+	c.Assert(err, IsNil)
+	corruptedSlice := append([]byte(nil), expectedSlice...)
+	corruptedSlice[100] = corruptedSlice[100] ^ 0xff
+	c.Assert(ioutil.WriteFile(slicePath, corruptedSlice, 0600), IsNil)
+
+	// heal does not read back every block, bitrot is left for the scrubber to find
+	report, err := donut.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{})
+
+	stats, err := donut.Scrub()
+	c.Assert(err, IsNil)
+	c.Assert(stats.Objects, Equals, 3)
+	c.Assert(stats.BitrotSlices, Equals, 1)
+	c.Assert(stats.Healed, Equals, 1)
+	c.Assert(stats.Completed.IsZero(), Equals, false)
+
+	actualSlice, err := ioutil.ReadFile(slicePath)
+	c.Assert(err, IsNil)
+	c.Assert(actualSlice, DeepEquals, expectedSlice)
+
+	info, err := donut.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.LastScrub, DeepEquals, stats)
+	c.Assert(info.CurrentScrub, Equals, ScrubStats{})
+
+	// last scrub statistics survive a restart
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	info, err = restarted.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.LastScrub.Objects, Equals, 3)
+	c.Assert(info.LastScrub.Healed, Equals, 1)
+}
+
+// test an object overwritten while it is verified is not healed with slices of the old one
+func (s *MySuite) TestScrubConcurrentWrite(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	b := d.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+
+	slicePath := path.Join(root, "3", "test", "foo$0$3", "obj", "data")
+	corruptedSlice, err := ioutil.ReadFile(slicePath)
+	c.Assert(err, IsNil)
+	corruptedSlice[100] = corruptedSlice[100] ^ 0xff
+	c.Assert(ioutil.WriteFile(slicePath, corruptedSlice, 0600), IsNil)
+
+	// the object is overwritten once the scrubber reads the slice of the last disk
+	overwritten := []byte("overwritten")
+	writing := erasureSet{id: sets[0].id}
+	for _, setDisk := range sets[0].disks {
+		writing.disks = append(writing.disks, setDisk)
+	}
+	last := len(writing.disks) - 1
+	writing.disks[last].disk = openHookDisk{Disk: writing.disks[last].disk, once: new(sync.Once), hook: func() {
+		err := d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(overwritten)), nil)
+		c.Assert(err, IsNil)
+	}}
+	stats := b.scrubObject(writing, "obj", nil)
+	c.Assert(stats, Equals, ScrubStats{})
+
+	reader, size, err := d.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, overwritten)
+	scrubStats, err := d.Scrub()
+	c.Assert(err, IsNil)
+	c.Assert(scrubStats.Healed, Equals, 0)
+	c.Assert(scrubStats.BitrotSlices, Equals, 0)
+}
+
+// test buckets made while scrubbing and rebalancing, run with -race
+func (s *MySuite) TestScrubConcurrentMakeBucket(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(d.MakeBucket("foo", "private", ""), IsNil)
+	c.Assert(d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), nil), IsNil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Check(d.MakeBucket("bar"+strconv.Itoa(i), "private", ""), IsNil)
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		_, err := d.Scrub()
+		c.Assert(err, IsNil)
+		c.Assert(d.Rebalance(), IsNil)
+	}
+	wg.Wait()
+	buckets, err := d.ListBuckets()
+	c.Assert(err, IsNil)
+	c.Assert(len(buckets), Equals, 9)
+}
+
+// openHookDisk - disk calling hook once when a data slice is first opened
+type openHookDisk struct {
+	Disk
+	once *sync.Once
+	hook func()
+}
+
+func (d openHookDisk) OpenFile(filename string) (io.ReadCloser, error) {
+	if path.Base(filename) == "data" {
+		d.once.Do(d.hook)
+	}
+	return d.Disk.OpenFile(filename)
+}
+
+// test scrub progress of an interrupted pass is resumed after a restart
+func (s *MySuite) TestScrubResume(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	for _, object := range []string{"obj1", "obj2", "obj3"} {
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), nil)
+		c.Assert(err, IsNil)
+	}
+
+	// a stopped pass verifies a single object before noticing
+	stop := make(chan struct{})
+	close(stop)
+	_, err = d.(donut).scrubPass(stop)
+	c.Assert(err, Not(IsNil))
+
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	info, err := restarted.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.CurrentScrub.Objects, Equals, 1)
+
+	stats, err := restarted.Scrub()
+	c.Assert(err, IsNil)
+	c.Assert(stats.Objects, Equals, 3)
+
+	// next pass starts over
+	stats, err = restarted.Scrub()
+	c.Assert(err, IsNil)
+	c.Assert(stats.Objects, Equals, 3)
+}
+
+func createTestNodeDiskMapWithDisks(p string, disks int) map[string][]string {
+	nodes := make(map[string][]string)
+	for i := 0; i < disks; i++ {
+		diskPath := path.Join(p, strconv.Itoa(i))
+		os.MkdirAll(diskPath, 0700)
+		nodes["localhost"] = append(nodes["localhost"], diskPath)
+	}
+	return nodes
+}
+
+func (s *MySuite) TestRebalance(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 8))
+	c.Assert(err, IsNil)
+
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	for _, object := range []string{"obj1", "obj2"} {
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), map[string]string{"contentType": "application/json"})
+		c.Assert(err, IsNil)
+	}
+	metadata, err := d.GetObjectMetadata("foo", "obj1")
+	c.Assert(err, IsNil)
+
+	// grow from 8 to 16 disks, old objects are still read from their original disks
+	grown, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	reader, size, err := grown.GetObject("foo", "obj1")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "obj1")
+	_, err = os.Stat(path.Join(root, "15", "test", "foo$0$15", "obj1", "data"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	err = grown.Rebalance()
+	c.Assert(err, IsNil)
+	info, err := grown.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.LastRebalance.Objects, Equals, 2)
+	c.Assert(info.LastRebalance.Migrated, Equals, 2)
+	c.Assert(info.CurrentRebalance.Started.IsZero(), Equals, true)
+
+	_, err = os.Stat(path.Join(root, "15", "test", "foo$0$15", "obj1", "data"))
+	c.Assert(err, IsNil)
+	reader, size, err = grown.GetObject("foo", "obj1")
+	c.Assert(err, IsNil)
+	actualData.Reset()
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "obj1")
+	// metadata is kept as it was
+	newMetadata, err := grown.GetObjectMetadata("foo", "obj1")
+	c.Assert(err, IsNil)
+	c.Assert(newMetadata, DeepEquals, metadata)
+
+	// nothing left to do until more disks are attached
+	err = grown.Rebalance()
+	c.Assert(err, IsNil)
+	newInfo, err := grown.Info()
+	c.Assert(err, IsNil)
+	c.Assert(newInfo.LastRebalance, DeepEquals, info.LastRebalance)
+}
+
+func (s *MySuite) TestRebalanceResume(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 8))
+	c.Assert(err, IsNil)
+
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	for _, object := range []string{"obj1", "obj2"} {
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), nil)
+		c.Assert(err, IsNil)
+	}
+
+	grown, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	_, err = grown.ListBuckets()
+	c.Assert(err, IsNil)
+	b := grown.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+
+	// crash while swapping in a migrated object, after its first slice
+	healthySlices, _, err := b.writeStagedObject(sets[0], "crashed", "obj1", bytes.NewReader([]byte("obj1")), "", nil)
+	c.Assert(err, IsNil)
+	err = b.writeCommitMarkers(sets[0], "crashed", "obj1", healthySlices, false)
+	c.Assert(err, IsNil)
+	disk, err := NewDisk(path.Join(root, "0"), 0)
+	c.Assert(err, IsNil)
+	err = commitStagedSlice(disk, path.Join(stagingDir, "crashed"), path.Join("test", "foo$0$0", "obj1"))
+	c.Assert(err, IsNil)
+
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = restarted.Rebalance()
+	c.Assert(err, IsNil)
+	info, err := restarted.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.LastRebalance.Objects, Equals, 2)
+	c.Assert(info.LastRebalance.Migrated, Equals, 1)
+	c.Assert(info.LastRebalance.Failed, Equals, 0)
+
+	_, err = os.Stat(path.Join(root, "15", stagingDir, "crashed"+commitMarkerSuffix))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	for _, object := range []string{"obj1", "obj2"} {
+		reader, size, err := restarted.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var actualData bytes.Buffer
+		_, err = io.CopyN(&actualData, reader, size)
+		c.Assert(err, IsNil)
+		c.Assert(actualData.String(), Equals, object)
+	}
+}
+
+func (s *MySuite) TestSaveLoadConfig(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.SaveConfig()
+	c.Assert(err, IsNil)
+	info, err := d.Info()
+	c.Assert(err, IsNil)
+
+	// configs are swapped in from staging, a copy left partial by a crash is skipped
+	for _, diskPath := range createTestNodeDiskMap(root)["localhost"] {
+		staged, err := ioutil.ReadDir(path.Join(diskPath, stagingDir))
+		c.Assert(err, IsNil)
+		c.Assert(len(staged), Equals, 0)
+	}
+	c.Assert(ioutil.WriteFile(path.Join(root, "0", "test", donutConfig), nil, 0600), IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(root, "1", nodeConfig), []byte("{"), 0600), IsNil)
+
+	// disks passed in a different order get their saved order back
+	nodeDiskMap := createTestNodeDiskMap(root)
+	disks := nodeDiskMap["localhost"]
+	for i, j := 0, len(disks)-1; i < j; i, j = i+1, j-1 {
+		disks[i], disks[j] = disks[j], disks[i]
+	}
+	restarted, err := NewDonut("test", nodeDiskMap)
+	c.Assert(err, IsNil)
+	err = restarted.LoadConfig()
+	c.Assert(err, IsNil)
+	restartedInfo, err := restarted.Info()
+	c.Assert(err, IsNil)
+	c.Assert(restartedInfo.Disks, DeepEquals, info.Disks)
+
+	// a saved disk which is not attached anymore is refused
+	missingDisk, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 15))
+	c.Assert(err, IsNil)
+	err = missingDisk.LoadConfig()
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestDiskFormat(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("data"))), nil)
+	c.Assert(err, IsNil)
+
+	// swapping disks between paths is detected through their format
+	c.Assert(os.Rename(path.Join(root, "0"), path.Join(root, "tmp")), IsNil)
+	c.Assert(os.Rename(path.Join(root, "1"), path.Join(root, "0")), IsNil)
+	c.Assert(os.Rename(path.Join(root, "tmp"), path.Join(root, "1")), IsNil)
+	swapped, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	info, err := swapped.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.Disks["localhost"][0], Equals, path.Join(root, "1"))
+	c.Assert(info.Disks["localhost"][1], Equals, path.Join(root, "0"))
+	reader, size, err := swapped.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "data")
+
+	// a disk of another donut is refused
+	foreignRoot, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(foreignRoot)
+	_, err = NewDonut("test", createTestNodeDiskMap(foreignRoot))
+	c.Assert(err, IsNil)
+	nodeDiskMap := createTestNodeDiskMap(root)
+	nodeDiskMap["localhost"][2] = path.Join(foreignRoot, "2")
+	_, err = NewDonut("test", nodeDiskMap)
+	c.Assert(err, Not(IsNil))
+
+	// so is a copy of another disk
+	format, err := ioutil.ReadFile(path.Join(root, "3", diskFormatConfig))
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(root, "4", diskFormatConfig), format, 0600), IsNil)
+	_, err = NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestObjectNamespace(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+
+	// names which used to collide, and a name longer than any filesystem allows
+	longName := strings.Repeat("long/", 100) + "obj"
+	objects := []string{"a/b", "a-b", "a/b/c", "..", longName}
+	for _, object := range objects {
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), nil)
+		c.Assert(err, IsNil)
+	}
+	for _, object := range objects {
+		reader, size, err := d.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var actualData bytes.Buffer
+		_, err = io.CopyN(&actualData, reader, size)
+		c.Assert(err, IsNil)
+		c.Assert(actualData.String(), Equals, object)
+	}
+	results, prefixes, _, err := d.ListObjects("foo", "a/", "", "/", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(results, DeepEquals, []string{"a/b"})
+	c.Assert(prefixes, DeepEquals, []string{"a/b/"})
+	results, _, _, err = d.ListObjects("foo", "long/long/", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(results, DeepEquals, []string{longName})
+}
+
+func (s *MySuite) TestObjectNameMigration(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "dir/obj", "", ioutil.NopCloser(bytes.NewReader([]byte("data"))), nil)
+	c.Assert(err, IsNil)
+
+	// objects used to be stored with every "/" replaced by "-"
+	for disk := 0; disk < 16; disk++ {
+		bucketPath := path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk))
+		err := os.Rename(path.Join(bucketPath, "dir%2Fobj"), path.Join(bucketPath, "dir-obj"))
+		c.Assert(err, IsNil)
+	}
+
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	reader, size, err := restarted.GetObject("foo", "dir/obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "data")
+	_, err = os.Stat(path.Join(root, "0", "test", "foo$0$0", "dir-obj"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MySuite) TestCrashRecovery(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	b := d.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+
+	// crash while swapping in an overwrite, after its first slice
+	healthySlices, _, err := b.writeStagedObject(sets[0], "crashed", "obj", bytes.NewReader([]byte("two")), "", nil)
+	c.Assert(err, IsNil)
+	err = b.writeCommitMarkers(sets[0], "crashed", "obj", healthySlices, false)
+	c.Assert(err, IsNil)
+	disk, err := NewDisk(path.Join(root, "0"), 0)
+	c.Assert(err, IsNil)
+	err = commitStagedSlice(disk, path.Join(stagingDir, "crashed"), path.Join("test", "foo$0$0", "obj"))
+	c.Assert(err, IsNil)
+	// crash while staging another write, never committed
+	_, _, err = b.writeStagedObject(sets[0], "orphaned", "obj", bytes.NewReader([]byte("three")), "", nil)
+	c.Assert(err, IsNil)
+
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	reader, size, err := restarted.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "two")
+	// the orphaned write may still be in progress by another process, swept once it is old
+	old := time.Now().Add(-2 * stagingGracePeriod)
+	for disk := 0; disk < 16; disk++ {
+		_, err = os.Stat(path.Join(root, strconv.Itoa(disk), stagingDir, "crashed"))
+		c.Assert(os.IsNotExist(err), Equals, true)
+		orphaned := path.Join(root, strconv.Itoa(disk), stagingDir, "orphaned")
+		files, err := ioutil.ReadDir(orphaned)
+		c.Assert(err, IsNil)
+		for _, file := range files {
+			c.Assert(os.Chtimes(path.Join(orphaned, file.Name()), old, old), IsNil)
+		}
+		c.Assert(os.Chtimes(orphaned, old, old), IsNil)
+	}
+	_, err = NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	for disk := 0; disk < 16; disk++ {
+		_, err = os.Stat(path.Join(root, strconv.Itoa(disk), stagingDir, "orphaned"))
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+// test heal interrupted by a crash before its slices were swapped in
+func (s *MySuite) TestHealCrashRecovery(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	b := d.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+
+	slicePath := func(disk int, file string) string {
+		return path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj", file)
+	}
+	expectedData5, err := ioutil.ReadFile(slicePath(5, "data"))
+	c.Assert(err, IsNil)
+	expectedMetadata5, err := ioutil.ReadFile(slicePath(5, objectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(os.Remove(slicePath(5, objectMetadataConfig)), IsNil)
+
+	// crash once the healed metadata is staged and marked committing
+	inspection, err := b.inspectObject(sets[0], "obj", nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(inspection.needsHeal(), Equals, true)
+	staged, err := b.stageHealedSlices(inspection, "crashed", nil)
+	c.Assert(err, IsNil)
+	err = b.writeCommitMarkers(sets[0], "crashed", "obj", staged, true)
+	c.Assert(err, IsNil)
+
+	// the healed slice is swapped in on startup keeping its data
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	actualData5, err := ioutil.ReadFile(slicePath(5, "data"))
+	c.Assert(err, IsNil)
+	c.Assert(actualData5, DeepEquals, expectedData5)
+	actualMetadata5, err := ioutil.ReadFile(slicePath(5, objectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(actualMetadata5, DeepEquals, expectedMetadata5)
+	reader, size, err := restarted.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+	report, err := restarted.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report, Equals, HealReport{})
+}
+
+// renameFailingDisk - disk failing every rename, all other operations succeed
+type renameFailingDisk struct {
+	Disk
+}
+
+func (d renameFailingDisk) Rename(oldName, newName string) error {
+	return errors.New("rename failed")
+}
+
+// appendFailingDisk - disk failing every append, all other operations succeed
+type appendFailingDisk struct {
+	Disk
+}
+
+func (d appendFailingDisk) AppendFile(name string) (io.WriteCloser, error) {
+	return nil, errors.New("append failed")
+}
+
+func (s *MySuite) TestObjectIndexWriteQuorum(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	b := d.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+	withFailingDisks := func(failing int) erasureSet {
+		set := erasureSet{id: sets[0].id}
+		for i, setDisk := range sets[0].disks {
+			if i < failing {
+				setDisk.disk = appendFailingDisk{Disk: setDisk.disk}
+			}
+			set.disks = append(set.disks, setDisk)
+		}
+		return set
+	}
+	metadata := map[string]string{"object": "obj", "created": time.Now().Format(time.RFC3339Nano)}
+
+	// an update reaching as many disks as an object write needs is durable
+	c.Assert(b.indexObject(withFailingDisks(1), "obj", metadata), IsNil)
+	// an update reaching fewer fails, the index is reloaded from the disks
+	err = b.indexObject(withFailingDisks(len(sets[0].disks)-2), "obj", metadata)
+	c.Assert(err, Not(IsNil))
+	c.Assert(b.indexes.get(sets[0]).loaded, Equals, false)
+	objectMetadata, err := b.GetObjectMetadata("obj")
+	c.Assert(err, IsNil)
+	c.Assert(objectMetadata["created"], Equals, metadata["created"])
+}
+
+func (s *MySuite) TestCommitRollForward(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	b := d.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+	failing := erasureSet{id: sets[0].id}
+	for _, setDisk := range sets[0].disks {
+		failing.disks = append(failing.disks, setDisk)
+	}
+	failing.disks[0].disk = renameFailingDisk{Disk: failing.disks[0].disk}
+
+	// a disk failing its rename loses its old slice, the commit reaches write quorum without it
+	healthySlices, writeQuorum, err := b.writeStagedObject(sets[0], "staged", "obj", bytes.NewReader([]byte("two")), "", nil)
+	c.Assert(err, IsNil)
+	err = b.commitStagedObject(failing, "staged", "obj", healthySlices, writeQuorum)
+	c.Assert(err, IsNil)
+	_, err = os.Stat(path.Join(root, "0", "test", "foo$0$0", "obj"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	reader, size, err := d.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "two")
+
+	// short of write quorum the commit stays marked, heal rolls it forward
+	healthySlices, _, err = b.writeStagedObject(sets[0], "marked", "obj", bytes.NewReader([]byte("three")), "", nil)
+	c.Assert(err, IsNil)
+	err = b.commitStagedObject(failing, "marked", "obj", healthySlices, len(healthySlices))
+	c.Assert(err, Not(IsNil))
+	c.Assert(b.isCommitMarked("marked"), Equals, true)
+	_, err = d.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(b.isCommitMarked("marked"), Equals, false)
+	reader, size, err = d.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	actualData.Reset()
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "three")
+}
+
+func (s *MySuite) TestObjectIndex(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	for _, object := range []string{"c", "b/2", "a", "b/1"} {
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), map[string]string{"key": object})
+		c.Assert(err, IsNil)
+	}
+	err = d.SetObjectMetadata("foo", "a", map[string]string{"key": "value"})
+	c.Assert(err, IsNil)
+
+	indexPath := func(disk int) string {
+		return path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), objectIndexConfig)
+	}
+	verifyIndex := func(d Donut) {
+		results, prefixes, isTruncated, err := d.ListObjects("foo", "", "", "/", 1000)
+		c.Assert(err, IsNil)
+		c.Assert(results, DeepEquals, []string{"a", "c"})
+		c.Assert(prefixes, DeepEquals, []string{"b/"})
+		c.Assert(isTruncated, Equals, false)
+		results, _, isTruncated, err = d.ListObjects("foo", "b/", "b/1", "", 1)
+		c.Assert(err, IsNil)
+		c.Assert(results, DeepEquals, []string{"b/2"})
+		c.Assert(isTruncated, Equals, false)
+		metadata, err := d.GetObjectMetadata("foo", "a")
+		c.Assert(err, IsNil)
+		c.Assert(metadata["key"], Equals, "value")
+		_, err = d.GetObjectMetadata("foo", "b")
+		c.Assert(err, Not(IsNil))
+	}
+	verifyIndex(d)
+
+	// a torn append is ignored, a log behind or missing on some disks is replaced
+	logFile, err := os.OpenFile(indexPath(0), os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = logFile.Write([]byte(`{"Sequence":`))
+	c.Assert(err, IsNil)
+	c.Assert(logFile.Close(), IsNil)
+	c.Assert(os.Remove(indexPath(1)), IsNil)
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	verifyIndex(restarted)
+	_, err = os.Stat(indexPath(1))
+	c.Assert(err, IsNil)
+
+	// a lost index is rebuilt from object slices
+	for disk := 0; disk < 16; disk++ {
+		c.Assert(os.Remove(indexPath(disk)), IsNil)
+	}
+	rebuilt, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	verifyIndex(rebuilt)
+	_, err = os.Stat(indexPath(15))
+	c.Assert(err, IsNil)
+}
+
+func (s *MySuite) TestPartialObject(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	// spans three chunks, the last one partially filled
+	data := make([]byte, 2*getBlockSize(8)+5*1024*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+
+	readRange := func(start, length int64) ([]byte, error) {
+		reader, err := d.GetPartialObject("foo", "obj", start, length)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	blockSize := int64(getBlockSize(8))
+	for _, r := range [][2]int64{{0, 100}, {blockSize - 10, 20}, {blockSize + 5, blockSize}, {int64(len(data)) - 7, 7}, {0, int64(len(data))}} {
+		actualData, err := readRange(r[0], r[1])
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(actualData, data[r[0]:r[0]+r[1]]), Equals, true)
+	}
+	_, err = readRange(int64(len(data))-7, 8)
+	c.Assert(err, Not(IsNil))
+
+	// the first chunk is lost on more slices than parity covers, later chunks are still readable
+	for disk := 0; disk < 9; disk++ {
+		slicePath := path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "obj", "data")
+		file, err := os.OpenFile(slicePath, os.O_WRONLY, 0600)
+		c.Assert(err, IsNil)
+		_, err = file.WriteAt([]byte("corrupted"), blockChecksumSize)
+		c.Assert(err, IsNil)
+		c.Assert(file.Close(), IsNil)
+	}
+	actualData, err := readRange(2*blockSize, 100)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(actualData, data[2*blockSize:2*blockSize+100]), Equals, true)
+	_, err = readRange(0, 100)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestStorageClass(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	readDonutObjectMetadata := func(object string) map[string]string {
+		metadata := make(map[string]string)
+		metadataBytes, err := ioutil.ReadFile(path.Join(root, "0", "test", "foo$0$0", object, donutObjectMetadataConfig))
+		c.Assert(err, IsNil)
+		c.Assert(json.Unmarshal(metadataBytes, &metadata), IsNil)
+		return metadata
+	}
+
+	err = d.PutObject("foo", "standard", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	metadata := readDonutObjectMetadata("standard")
+	c.Assert(metadata["sys.storageClass"], Equals, StorageClassStandard)
+	c.Assert(metadata["sys.erasureK"], Equals, "8")
+	c.Assert(metadata["sys.erasureM"], Equals, "8")
+	c.Assert(metadata["sys.erasureTechnique"], Equals, "Cauchy")
+
+	err = d.PutObject("foo", "reduced", "", ioutil.NopCloser(bytes.NewReader(data)), map[string]string{"storageClass": StorageClassReducedRedundancy})
+	c.Assert(err, IsNil)
+	metadata = readDonutObjectMetadata("reduced")
+	c.Assert(metadata["sys.storageClass"], Equals, StorageClassReducedRedundancy)
+	c.Assert(metadata["sys.erasureK"], Equals, "12")
+	c.Assert(metadata["sys.erasureM"], Equals, "4")
+	c.Assert(metadata["sys.erasureTechnique"], Equals, "Vandermonde")
+	objectMetadata, err := d.GetObjectMetadata("foo", "reduced")
+	c.Assert(err, IsNil)
+	c.Assert(objectMetadata["storageClass"], Equals, StorageClassReducedRedundancy)
+
+	// as many slices lost as there is parity
+	for _, disk := range []int{0, 5, 10, 15} {
+		c.Assert(os.RemoveAll(path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), "reduced")), IsNil)
+	}
+	reader, size, err := d.GetObject("foo", "reduced")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+
+	// objects of a bucket default to its storage class
+	err = d.SetBucketMetadata("foo", map[string]string{"storageClass": StorageClassReducedRedundancy})
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "default", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	c.Assert(readDonutObjectMetadata("default")["sys.storageClass"], Equals, StorageClassReducedRedundancy)
+
+	err = d.PutObject("foo", "invalid", "", ioutil.NopCloser(bytes.NewReader(data)), map[string]string{"storageClass": "GLACIER"})
+	c.Assert(err, Not(IsNil))
+	err = d.SetBucketMetadata("foo", map[string]string{"storageClass": "GLACIER"})
+	c.Assert(err, Not(IsNil))
+
+	// default storage class given when the bucket is made
+	err = d.MakeBucket("bar", "private", StorageClassReducedRedundancy)
+	c.Assert(err, IsNil)
+	bucketMetadata, err := d.GetBucketMetadata("bar")
+	c.Assert(err, IsNil)
+	c.Assert(bucketMetadata["storageClass"], Equals, StorageClassReducedRedundancy)
+	err = d.PutObject("bar", "default", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	objectMetadata, err = d.GetObjectMetadata("bar", "default")
+	c.Assert(err, IsNil)
+	c.Assert(objectMetadata["storageClass"], Equals, StorageClassReducedRedundancy)
+	err = d.MakeBucket("baz", "private", "GLACIER")
+	c.Assert(err, Not(IsNil))
+	_, err = d.GetBucketMetadata("baz")
+	c.Assert(err, Not(IsNil))
+}
+
+// createTestMultiNodeDiskMap - nodes of disks each, every node in its own directory
+func createTestMultiNodeDiskMap(p string, nodes, disks int) map[string][]string {
+	nodeDiskMap := make(map[string][]string)
+	for i := 0; i < nodes; i++ {
+		hostname := "node" + strconv.Itoa(i)
+		nodeDiskMap[hostname] = createTestNodeDiskMapWithDisks(path.Join(p, hostname), disks)["localhost"]
+	}
+	return nodeDiskMap
+}
+
+func (s *MySuite) TestDiskReserve(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	info, err := d.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.DiskReserve, Equals, defaultDiskReserve)
+	c.Assert(info.FSInfo["localhost"][0]["TotalBytes"], Not(Equals), "")
+
+	err = d.PutObject("foo", "small", "", ioutil.NopCloser(bytes.NewBufferString("hello world")), map[string]string{"contentLength": "11"})
+	c.Assert(err, IsNil)
+	// no disk has room for its slice, nothing is written
+	err = d.PutObject("foo", "huge", "", ioutil.NopCloser(bytes.NewBufferString("hello world")), map[string]string{"contentLength": "1125899906842624"})
+	c.Assert(iodine.ToError(err), Equals, ErrStorageFull)
+	_, err = d.GetObjectMetadata("foo", "huge")
+	c.Assert(err, Not(IsNil))
+
+	// every block is encoded on its own and prefixed with its checksum
+	blockSize := getBlockSize(8)
+	blockLen := encoding.GetEncodedBlockLen(blockSize, 8)
+	c.Assert(getSliceSize(int64(2*blockSize+1), 8, 8), Equals, int64(2*blockLen+encoding.GetEncodedBlockLen(1, 8)+3*blockChecksumSize))
+
+	c.Assert(d.SetDiskReserve(100), Not(IsNil))
+	c.Assert(d.SetDiskReserve(0), IsNil)
+	c.Assert(d.SaveConfig(), IsNil)
+	d, err = NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	c.Assert(d.LoadConfig(), IsNil)
+	info, err = d.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.DiskReserve, Equals, 0)
+}
+
+func (s *MySuite) TestBucketMetadataQuorum(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	metadataPath := func(disk int) string {
+		return path.Join(root, strconv.Itoa(disk), "test", bucketMetadataConfig)
+	}
+	readGeneration := func(diskOrder int) int64 {
+		metadataDisk, err := NewDisk(path.Join(root, strconv.Itoa(diskOrder)), diskOrder)
+		c.Assert(err, IsNil)
+		diskMetadata, err := readDonutBucketMetadata(metadataDisk, "test")
+		c.Assert(err, IsNil)
+		c.Assert(diskMetadata.Buckets["foo"]["acl"], Equals, "private")
+		return diskMetadata.Generation
+	}
+
+	// a minority disagreeing, as written before bucket metadata was versioned, is outvoted and repaired
+	for _, disk := range []int{0, 1, 2} {
+		c.Assert(ioutil.WriteFile(metadataPath(disk), []byte(`{"foo":{"acl":"public-read-write"}}`), 0600), IsNil)
+	}
+	c.Assert(os.Remove(metadataPath(3)), IsNil)
+	metadata, err := d.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	c.Assert(metadata["acl"], Equals, "private")
+	for _, disk := range []int{0, 1, 2, 3} {
+		c.Assert(readGeneration(disk), Equals, int64(1))
+	}
+
+	// every update is applied on top of the one before, none is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Check(d.SetBucketMetadata("foo", map[string]string{"acl": "private"}), IsNil)
+		}()
+	}
+	wg.Wait()
+	for disk := 0; disk < 16; disk++ {
+		c.Assert(readGeneration(disk), Equals, int64(11))
+	}
+
+	// fewer than half of the disks left with a readable copy
+	for disk := 0; disk < 9; disk++ {
+		c.Assert(ioutil.WriteFile(metadataPath(disk), []byte("corrupted"), 0600), IsNil)
+	}
+	_, err = d.GetBucketMetadata("foo")
+	c.Assert(err, Not(IsNil))
+	c.Assert(d.SetBucketMetadata("foo", map[string]string{"acl": "public-read"}), Not(IsNil))
+	// heal trusts the copies left
+	_, err = d.Heal()
+	c.Assert(err, IsNil)
+	metadata, err = d.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	c.Assert(metadata["acl"], Equals, "private")
+	for disk := 0; disk < 16; disk++ {
+		c.Assert(readGeneration(disk), Equals, int64(11))
+	}
+
+	// an update rejected short of write quorum, left behind on half of the disks, is not read back
+	rejected := donutBucketMetadata{Version: "1.0", Generation: 12, Disks: 16, Buckets: map[string]map[string]string{
+		"foo": {"acl": "public-read"},
+	}}
+	for disk := 0; disk < 8; disk++ {
+		metadataDisk, err := NewDisk(path.Join(root, strconv.Itoa(disk)), disk)
+		c.Assert(err, IsNil)
+		c.Assert(writeDonutBucketMetadata(metadataDisk, "test", rejected), IsNil)
+	}
+	_, err = d.GetBucketMetadata("foo")
+	c.Assert(err, Not(IsNil))
+	c.Assert(d.SetBucketMetadata("foo", map[string]string{"acl": "private"}), Not(IsNil))
+}
+
+func (s *MySuite) TestMultiNodeErasureSet(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestMultiNodeDiskMap(root, 2, 8))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	getSlicePath := func(node, disk int, object string) string {
+		bucketSlice := "foo$" + strconv.Itoa(node) + "$" + strconv.Itoa(disk)
+		return path.Join(root, "node"+strconv.Itoa(node), strconv.Itoa(disk), "test", bucketSlice, object)
+	}
+
+	// slices are spread over the disks of both nodes, k/m computed over all 16 disks
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	for node := 0; node < 2; node++ {
+		for disk := 0; disk < 8; disk++ {
+			_, err := os.Stat(path.Join(getSlicePath(node, disk, "obj"), "data"))
+			c.Assert(err, IsNil)
+		}
+	}
+	metadata := make(map[string]string)
+	metadataBytes, err := ioutil.ReadFile(path.Join(getSlicePath(1, 0, "obj"), donutObjectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(metadataBytes, &metadata), IsNil)
+	c.Assert(metadata["sys.erasureK"], Equals, "8")
+	c.Assert(metadata["sys.erasureM"], Equals, "8")
+
+	// reduced redundancy still covers losing a whole node
+	err = d.PutObject("foo", "reduced", "", ioutil.NopCloser(bytes.NewReader(data)), map[string]string{"storageClass": StorageClassReducedRedundancy})
+	c.Assert(err, IsNil)
+	metadataBytes, err = ioutil.ReadFile(path.Join(getSlicePath(0, 0, "reduced"), donutObjectMetadataConfig))
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(metadataBytes, &metadata), IsNil)
+	c.Assert(metadata["sys.erasureM"], Equals, "8")
+
+	// lose every slice of one node
+	for disk := 0; disk < 8; disk++ {
+		c.Assert(os.RemoveAll(getSlicePath(1, disk, "obj")), IsNil)
+		c.Assert(os.RemoveAll(getSlicePath(1, disk, "reduced")), IsNil)
+	}
+	restarted, err := NewDonut("test", createTestMultiNodeDiskMap(root, 2, 8))
+	c.Assert(err, IsNil)
+	for _, object := range []string{"obj", "reduced"} {
+		reader, size, err := restarted.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var actualData bytes.Buffer
+		_, err = io.CopyN(&actualData, reader, size)
+		c.Assert(err, IsNil)
+		c.Assert(actualData.Bytes(), DeepEquals, data)
+	}
+	objects, _, _, err := restarted.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj", "reduced"})
+
+	// heal puts the lost node slices back
+	report, err := restarted.Heal()
+	c.Assert(err, IsNil)
+	c.Assert(report.Healed, Equals, 2)
+	_, err = os.Stat(path.Join(getSlicePath(1, 7, "obj"), "data"))
+	c.Assert(err, IsNil)
+}
+
+func (s *MySuite) TestErasureSets(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	var objects []string
+	for i := 0; i < 32; i++ {
+		object := fmt.Sprintf("obj%02d", i)
+		objects = append(objects, object)
+		err = d.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), nil)
+		c.Assert(err, IsNil)
+	}
+
+	// 16 more disks make up a second erasure set, objects are read from the first until moved
+	grown, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 32))
+	c.Assert(err, IsNil)
+	b := grown.(donut).buckets["foo"].(bucket)
+	sets, err := getErasureSets(b.nodes())
+	c.Assert(err, IsNil)
+	c.Assert(len(sets), Equals, 2)
+	c.Assert(len(sets[1].disks), Equals, 16)
+	ring := newPlacementRing(sets)
+	getSlicePath := func(disk int, object string) string {
+		return path.Join(root, strconv.Itoa(disk), "test", "foo$0$"+strconv.Itoa(disk), object, "data")
+	}
+	readObject := func(object string) string {
+		reader, size, err := grown.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var actualData bytes.Buffer
+		_, err = io.CopyN(&actualData, reader, size)
+		c.Assert(err, IsNil)
+		return actualData.String()
+	}
+	moved := 0
+	for _, object := range objects {
+		if ring.getErasureSet("foo", object) == 1 {
+			moved = moved + 1
+		}
+		c.Assert(readObject(object), Equals, object)
+	}
+	// only a bounded fraction of objects is placed on the new set
+	c.Assert(moved > 0 && moved < len(objects), Equals, true)
+
+	// new objects are written onto the set they are placed on, listed along with all others
+	for i := 32; i < 48; i++ {
+		object := fmt.Sprintf("obj%02d", i)
+		objects = append(objects, object)
+		err = grown.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(object))), nil)
+		c.Assert(err, IsNil)
+		_, err = os.Stat(getSlicePath(16*ring.getErasureSet("foo", object), object))
+		c.Assert(err, IsNil)
+	}
+	listed, _, _, err := grown.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(listed, DeepEquals, objects)
+	listed, _, isTruncated, err := grown.ListObjects("foo", "obj1", "obj12", "", 5)
+	c.Assert(err, IsNil)
+	c.Assert(isTruncated, Equals, true)
+	c.Assert(listed, DeepEquals, []string{"obj13", "obj14", "obj15", "obj16", "obj17"})
+
+	err = grown.Rebalance()
+	c.Assert(err, IsNil)
+	info, err := grown.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.LastRebalance.Objects, Equals, len(objects))
+	c.Assert(info.LastRebalance.Migrated, Equals, moved)
+	for _, object := range objects {
+		placed := ring.getErasureSet("foo", object)
+		_, err := os.Stat(getSlicePath(16*placed, object))
+		c.Assert(err, IsNil)
+		_, err = os.Stat(getSlicePath(16*(1-placed), object))
+		c.Assert(os.IsNotExist(err), Equals, true)
+		c.Assert(readObject(object), Equals, object)
+	}
+
+	// indexes of both sets are found again after a restart
+	restarted, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 32))
+	c.Assert(err, IsNil)
+	listed, _, _, err = restarted.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(listed, DeepEquals, objects)
+}
+
+// benchmarkPutObject - throughput of writing an object erasure coded over disks
+func benchmarkPutObject(b *testing.B, disks int) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, disks))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := d.MakeBucket("foo", "private", ""); err != nil {
+		b.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("a"), 16*1024*1024)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkGetObject - throughput of reading back an object erasure coded over disks
+func benchmarkGetObject(b *testing.B, disks int) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, disks))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := d.MakeBucket("foo", "private", ""); err != nil {
+		b.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("a"), 16*1024*1024)
+	if err := d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader, size, err := d.GetObject("foo", "obj")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.CopyN(ioutil.Discard, reader, size); err != nil {
+			b.Fatal(err)
+		}
+		reader.Close()
+	}
+}
+
+func BenchmarkPutObject4Disks(b *testing.B)  { benchmarkPutObject(b, 4) }
+func BenchmarkPutObject8Disks(b *testing.B)  { benchmarkPutObject(b, 8) }
+func BenchmarkPutObject16Disks(b *testing.B) { benchmarkPutObject(b, 16) }
+func BenchmarkGetObject4Disks(b *testing.B)  { benchmarkGetObject(b, 4) }
+func BenchmarkGetObject8Disks(b *testing.B)  { benchmarkGetObject(b, 8) }
+func BenchmarkGetObject16Disks(b *testing.B) { benchmarkGetObject(b, 16) }
+
+func (s *MySuite) TestRemoteDisks(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	credentials := map[string]string{"DISKACCESSKEY": "disksecretkey"}
+	c.Assert(SetDiskCredentials("DISKACCESSKEY", "disksecretkey"), IsNil)
+	// four hosts exporting four disks each
+	var servers []*httptest.Server
+	var diskPaths []string
+	for i := 0; i < 4; i++ {
+		var exported []string
+		for j := 0; j < 4; j++ {
+			diskPath := path.Join(root, strconv.Itoa(i*4+j))
+			c.Assert(os.MkdirAll(diskPath, 0700), IsNil)
+			exported = append(exported, diskPath)
+		}
+		_, err := NewDiskServer(exported, nil)
+		c.Assert(err, Not(IsNil))
+		handler, err := NewDiskServer(exported, credentials)
+		c.Assert(err, IsNil)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		servers = append(servers, server)
+		for _, diskPath := range exported {
+			diskPaths = append(diskPaths, strings.TrimPrefix(server.URL, "http://")+diskPath)
+		}
+	}
+	c.Assert(IsRemoteDisk(diskPaths[0]), Equals, true)
+	c.Assert(IsRemoteDisk(path.Join(root, "0")), Equals, false)
+
+	// requests not signed with a shared access key are refused
+	fsInfoURL := servers[0].URL + diskServerPath + "fsinfo?disk=" + path.Join(root, "0")
+	resp, err := http.Get(fsInfoURL)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+	c.Assert(SetDiskCredentials("DISKACCESSKEY", "wrongsecretkey"), IsNil)
+	_, err = NewRemoteDisk(strings.TrimPrefix(servers[0].URL, "http://"), path.Join(root, "0"), 0)
+	c.Assert(err, Not(IsNil))
+	c.Assert(SetDiskCredentials("DISKACCESSKEY", "disksecretkey"), IsNil)
+
+	d, err := NewDonut("test", map[string][]string{"localhost": diskPaths})
+	c.Assert(err, IsNil)
+	c.Assert(d.SaveConfig(), IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	data := bytes.Repeat([]byte("Hello World "), 100000)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+	c.Assert(err, IsNil)
+	// slices are written to the exported disks
+	_, err = os.Stat(path.Join(root, "15", "test", "foo$0$15", "obj", "data"))
+	c.Assert(err, IsNil)
+
+	// disks are identified by their format across restarts
+	d, err = NewDonut("test", map[string][]string{"localhost": diskPaths})
+	c.Assert(err, IsNil)
+	c.Assert(d.LoadConfig(), IsNil)
+	reader, size, err := d.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+	reader, err = d.GetPartialObject("foo", "obj", 600000, 12)
+	c.Assert(err, IsNil)
+	partialData, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(partialData), Equals, "Hello World ")
+
+	// a host going down takes its disks along, objects are still readable
+	servers[1].Close()
+	reader, size, err = d.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	actualData.Reset()
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.Bytes(), DeepEquals, data)
+}
+
+func (s *MySuite) TestDiskLock(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	nodeDiskMap := createTestNodeDiskMapWithDisks(root, 4)
+	lock, err := LockDisks(nodeDiskMap)
+	c.Assert(err, IsNil)
+	// another process, or another open of the same disks, is refused
+	_, err = LockDisks(nodeDiskMap)
+	c.Assert(iodine.ToError(err), Equals, ErrDiskLocked)
+	c.Assert(lock.Unlock(), IsNil)
+	lock, err = LockDisks(nodeDiskMap)
+	c.Assert(err, IsNil)
+	c.Assert(lock.Unlock(), IsNil)
+
+	// remote disks are locked by the disk server exporting them, on behalf of the client
+	c.Assert(SetDiskCredentials("DISKACCESSKEY", "disksecretkey"), IsNil)
+	handler, err := NewDiskServer(nodeDiskMap["localhost"], map[string]string{"DISKACCESSKEY": "disksecretkey"})
+	c.Assert(err, IsNil)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	remoteNodeDiskMap := make(map[string][]string)
+	for _, diskPath := range nodeDiskMap["localhost"] {
+		remoteNodeDiskMap["localhost"] = append(remoteNodeDiskMap["localhost"], strings.TrimPrefix(server.URL, "http://")+diskPath)
+	}
+	lock, err = LockDisks(remoteNodeDiskMap)
+	c.Assert(err, IsNil)
+	_, err = LockDisks(remoteNodeDiskMap)
+	c.Assert(iodine.ToError(err), Equals, ErrDiskLocked)
+	_, err = LockDisks(nodeDiskMap)
+	c.Assert(iodine.ToError(err), Equals, ErrDiskLocked)
+	c.Assert(lock.Unlock(), IsNil)
+	lock, err = LockDisks(nodeDiskMap)
+	c.Assert(err, IsNil)
+	c.Assert(lock.Unlock(), IsNil)
+}
+
+func (s *MySuite) TestReadOnlyDonut(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	// blank disks are not formatted
+	_, err = NewReadOnlyDonut("test", createTestNodeDiskMapWithDisks(root, 4))
+	c.Assert(err, Not(IsNil))
+	_, err = os.Stat(path.Join(root, "0", diskFormatConfig))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 4))
+	c.Assert(err, IsNil)
+	c.Assert(d.SaveConfig(), IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	// a write of the serving process still in staging
+	stagingPath := path.Join(root, "0", stagingDir, "inprogress")
+	c.Assert(os.MkdirAll(stagingPath, 0700), IsNil)
+	old := time.Now().Add(-2 * stagingGracePeriod)
+	c.Assert(os.Chtimes(stagingPath, old, old), IsNil)
+
+	// disks of another donut are refused
+	_, err = NewReadOnlyDonut("other", createTestNodeDiskMapWithDisks(root, 4))
+	c.Assert(err, Not(IsNil))
+	readOnly, err := NewReadOnlyDonut("test", createTestNodeDiskMapWithDisks(root, 4))
+	c.Assert(err, IsNil)
+	c.Assert(readOnly.LoadConfig(), IsNil)
+	_, err = os.Stat(stagingPath)
+	c.Assert(err, IsNil)
+	info, err := readOnly.Info()
+	c.Assert(err, IsNil)
+	c.Assert(len(info.Disks["localhost"]), Equals, 4)
+	reader, size, err := readOnly.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	var actualData bytes.Buffer
+	_, err = io.CopyN(&actualData, reader, size)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "one")
+	c.Assert(readOnly.SaveConfig(), Not(IsNil))
+}
+
+func (s *MySuite) TestListTooWideObjects(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 8))
+	c.Assert(err, IsNil)
+	err = d.MakeBucket("foo", "private", "")
+	c.Assert(err, IsNil)
+	err = d.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), nil)
+	c.Assert(err, IsNil)
+	tooWide, err := d.ListTooWideObjects()
+	c.Assert(err, IsNil)
+	c.Assert(len(tooWide), Equals, 0)
+
+	// the object is spread over all eight disks, one of them detached
+	detached, err := NewDonut("test", createTestNodeDiskMapWithDisks(root, 7))
+	c.Assert(err, IsNil)
+	tooWide, err = detached.ListTooWideObjects()
+	c.Assert(err, IsNil)
+	c.Assert(tooWide, DeepEquals, []string{"foo/obj"})
+}
@@ -4,30 +4,151 @@ import (
 	"encoding/json"
 	"errors"
 	"path"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/utils/log"
 )
 
 // Heal - heal a donut and fix bad data blocks
-func (d donut) Heal() error {
-	return errors.New("Not Implemented")
+func (d donut) Heal() (HealReport, error) {
+	report := HealReport{}
+	// a replaced disk is empty, start by giving it an identity and its donut directory
+	formatted := false
+	for hostname, node := range d.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return report, iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			format, err := readDiskFormat(disk)
+			if err != nil {
+				return report, iodine.New(err, nil)
+			}
+			if format == nil {
+				if err := d.formatDisk(hostname, disk); err != nil {
+					return report, iodine.New(err, nil)
+				}
+				formattedDisk, err := OpenDisk(disk.GetPath(), disk.GetOrder())
+				if err != nil {
+					return report, iodine.New(err, nil)
+				}
+				if err := node.AttachDisk(formattedDisk); err != nil {
+					return report, iodine.New(err, nil)
+				}
+				disk = formattedDisk
+				formatted = true
+			}
+			if err := disk.MakeDir(d.name); err != nil {
+				return report, iodine.New(err, nil)
+			}
+		}
+	}
+	// replaced disks are recorded in place of the lost ones
+	if formatted && !d.readOnly {
+		if err := d.SaveConfig(); err != nil {
+			return report, iodine.New(err, nil)
+		}
+	}
+	if err := d.getDonutBuckets(); err != nil {
+		return report, iodine.New(err, nil)
+	}
+	if err := d.healDonutBucketMetadata(); err != nil {
+		return report, iodine.New(err, nil)
+	}
+	for _, bucketName := range d.getBucketNames() {
+		// a replaced disk carries none of the bucket slices
+		if err := d.makeDonutBucketSlices(bucketName); err != nil {
+			return report, iodine.New(err, nil)
+		}
+		bucket, _ := d.getBucket(bucketName)
+		bucketReport, err := bucket.Heal(d.healLimiter)
+		if err != nil {
+			return report, iodine.New(err, nil)
+		}
+		report.Healed = report.Healed + bucketReport.Healed
+		report.Failed = report.Failed + bucketReport.Failed
+		report.Unrecoverable = report.Unrecoverable + bucketReport.Unrecoverable
+	}
+	return report, nil
 }
 
-// Info - return info about donut configuration
-func (d donut) Info() (nodeDiskMap map[string][]string, err error) {
-	nodeDiskMap = make(map[string][]string)
-	for nodeName, node := range d.nodes {
+// saveDonutConfig - encode a donut wide config file on every disk, succeeds if saved on any disk
+func (d donut) saveDonutConfig(config string, v interface{}) error {
+	saved := 0
+	for _, node := range d.getNodes() {
 		disks, err := node.ListDisks()
 		if err != nil {
-			return nil, iodine.New(err, nil)
+			return iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			if err := writeConfigFile(disk, path.Join(d.name, config), v); err != nil {
+				log.Error.Println(iodine.New(err, map[string]string{"disk": disk.GetPath(), "config": config}))
+				continue
+			}
+			saved = saved + 1
+		}
+	}
+	if saved == 0 {
+		return iodine.New(errors.New("unable to save config on any disk"), map[string]string{"config": config})
+	}
+	return nil
+}
+
+// loadDonutConfig - decode a donut wide config file from every disk it is readable on,
+// disks where decode fails are logged and skipped
+func (d donut) loadDonutConfig(config string, decode func(jdec *json.Decoder) error) error {
+	for _, node := range d.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			reader, err := disk.OpenFile(path.Join(d.name, config))
+			if err != nil {
+				continue
+			}
+			err = decode(json.NewDecoder(reader))
+			reader.Close()
+			if err != nil {
+				log.Error.Println(iodine.New(err, map[string]string{"disk": disk.GetPath(), "config": config}))
+			}
+		}
+	}
+	return nil
+}
+
+// Info - return info about donut configuration, last scrub and rebalance
+func (d donut) Info() (DonutInfo, error) {
+	nodeDiskMap := make(map[string][]string)
+	nodeFSInfo := make(map[string][]map[string]string)
+	for nodeName, node := range d.getNodes() {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return DonutInfo{}, iodine.New(err, nil)
 		}
 		diskList := make([]string, len(disks))
+		fsInfoList := make([]map[string]string, len(disks))
 		for diskName, disk := range disks {
 			diskList[disk.GetOrder()] = diskName
+			// nil for disks which are unreachable
+			fsInfoList[disk.GetOrder()] = disk.GetFSInfo()
 		}
 		nodeDiskMap[nodeName] = diskList
+		nodeFSInfo[nodeName] = fsInfoList
+	}
+	info := DonutInfo{Disks: nodeDiskMap, FSInfo: nodeFSInfo, DiskReserve: d.reserve.get()}
+	if err := d.loadScrubProgress(); err != nil {
+		return DonutInfo{}, iodine.New(err, nil)
+	}
+	info.LastScrub, info.CurrentScrub = d.scrubber.getStats()
+	if err := d.loadRebalanceProgress(); err != nil {
+		return DonutInfo{}, iodine.New(err, nil)
 	}
-	return nodeDiskMap, nil
+	info.LastRebalance, info.CurrentRebalance = d.rebalancer.getStats()
+	return info, nil
 }
 
 // AttachNode - attach node
@@ -35,42 +156,179 @@ func (d donut) AttachNode(node Node) error {
 	if node == nil {
 		return iodine.New(errors.New("invalid argument"), nil)
 	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	d.nodes[node.GetNodeName()] = node
 	return nil
 }
 
 // DetachNode - detach node
 func (d donut) DetachNode(node Node) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	delete(d.nodes, node.GetNodeName())
 	return nil
 }
 
-// SaveConfig - save donut configuration
+// ListTooWideObjects - objects of every bucket spanning more disks than their erasure set has
+// attached, as bucket/object. Heal cannot reconstruct their slices on detached disks
+func (d donut) ListTooWideObjects() ([]string, error) {
+	if err := d.getDonutBuckets(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var tooWide []string
+	for _, bucketName := range d.getBucketNames() {
+		bucket, _ := d.getBucket(bucketName)
+		objects, err := bucket.ListTooWideObjects()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, object := range objects {
+			tooWide = append(tooWide, bucketName+"/"+object)
+		}
+	}
+	sort.Strings(tooWide)
+	return tooWide, nil
+}
+
+// SetDiskReserve - percentage of every disk kept free, writes not fitting beside it fail with
+// ErrStorageFull. Persisted by SaveConfig
+func (d donut) SetDiskReserve(percent int) error {
+	if err := d.reserve.set(percent); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// donutConfiguration - topology of a donut, persisted on every disk
+type donutConfiguration struct {
+	Version string
+	Name    string
+	ID      string
+	// paths and ids of disks of every node, in order
+	Nodes   map[string][]string
+	DiskIDs map[string][]string
+	// erasure parameters new objects are written with
+	ErasureK         uint8
+	ErasureM         uint8
+	ErasureTechnique string
+	// percentage of every disk kept free
+	DiskReserve int
+	Updated     time.Time
+}
+
+// SaveConfig - save donut configuration and the configuration of its nodes on every disk
 func (d donut) SaveConfig() error {
-	nodeDiskMap := make(map[string][]string)
-	for hostname, node := range d.nodes {
+	if d.readOnly {
+		return iodine.New(errors.New("donut opened read only"), map[string]string{"donut": d.name})
+	}
+	config := donutConfiguration{
+		Version:     "1.0",
+		Name:        d.name,
+		ID:          d.id,
+		Nodes:       make(map[string][]string),
+		DiskIDs:     make(map[string][]string),
+		DiskReserve: d.reserve.get(),
+		Updated:     time.Now().UTC(),
+	}
+	for hostname, node := range d.getNodes() {
+		if err := node.SaveConfig(); err != nil {
+			return iodine.New(err, nil)
+		}
 		disks, err := node.ListDisks()
 		if err != nil {
 			return iodine.New(err, nil)
 		}
+		config.Nodes[hostname] = make([]string, len(disks))
+		config.DiskIDs[hostname] = make([]string, len(disks))
 		for _, disk := range disks {
-			donutConfigPath := path.Join(d.name, donutConfig)
-			donutConfigWriter, err := disk.MakeFile(donutConfigPath)
-			defer donutConfigWriter.Close()
-			if err != nil {
-				return iodine.New(err, nil)
-			}
-			nodeDiskMap[hostname][disk.GetOrder()] = disk.GetPath()
-			jenc := json.NewEncoder(donutConfigWriter)
-			if err := jenc.Encode(nodeDiskMap); err != nil {
-				return iodine.New(err, nil)
-			}
+			config.Nodes[hostname][disk.GetOrder()] = disk.GetPath()
+			config.DiskIDs[hostname][disk.GetOrder()] = disk.GetID()
+		}
+	}
+	sets, err := getErasureSets(d.getNodes())
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if len(sets[0].disks) > 1 {
+		// parameters of the default storage class on the first erasure set, others are recorded
+		// with every object
+		sc := storageClasses[StorageClassStandard]
+		config.ErasureK, config.ErasureM, err = sc.getDataAndParity(len(sets[0].disks), sets[0].getNodeWidth())
+		if err != nil {
+			return iodine.New(err, nil)
 		}
+		config.ErasureTechnique = sc.technique
+	}
+	if err := d.saveDonutConfig(donutConfig, config); err != nil {
+		return iodine.New(err, nil)
 	}
 	return nil
 }
 
-// LoadConfig - load configuration
+// LoadConfig - load donut configuration saved on its disks, verifying every saved disk is
+// attached to its node in the same position. Disks are matched by id, their paths may change
 func (d donut) LoadConfig() error {
-	return errors.New("Not Implemented")
+	var config donutConfiguration
+	err := d.loadDonutConfig(donutConfig, func(jdec *json.Decoder) error {
+		// configs saved before the reserve was configurable keep the default
+		diskConfig := donutConfiguration{DiskReserve: defaultDiskReserve}
+		if err := jdec.Decode(&diskConfig); err != nil {
+			return iodine.New(err, nil)
+		}
+		if diskConfig.Updated.After(config.Updated) {
+			config = diskConfig
+		}
+		return nil
+	})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if config.Updated.IsZero() {
+		return nil
+	}
+	errParams := map[string]string{"donut": d.name}
+	if config.Name != d.name || config.ID != d.id {
+		errParams["savedName"] = config.Name
+		return iodine.New(errors.New("donut config belongs to another donut"), errParams)
+	}
+	nodes := d.getNodes()
+	savedDisks, replaced := 0, 0
+	for hostname, diskIDs := range config.DiskIDs {
+		node, ok := nodes[hostname]
+		if !ok {
+			errParams["node"] = hostname
+			return iodine.New(errors.New("node missing from donut"), errParams)
+		}
+		if err := node.LoadConfig(); err != nil {
+			return iodine.New(err, errParams)
+		}
+		disks, err := node.ListDisks()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		nodeReplaced, err := verifyDiskOrder(disks, diskIDs, config.Updated)
+		if err != nil {
+			errParams["node"] = hostname
+			return iodine.New(err, errParams)
+		}
+		savedDisks = savedDisks + len(diskIDs)
+		replaced = replaced + nodeReplaced
+	}
+	// replaced disks are healed from the disks left, a majority of them is needed to read from
+	if replaced > 0 && savedDisks-replaced < savedDisks/2+1 {
+		errParams["replaced"] = strconv.Itoa(replaced)
+		errParams["disks"] = strconv.Itoa(savedDisks)
+		return iodine.New(errors.New("too many disks replaced"), errParams)
+	}
+	if err := d.reserve.set(config.DiskReserve); err != nil {
+		return iodine.New(err, errParams)
+	}
+	// replaced disks are recorded in place of the lost ones
+	if replaced > 0 && !d.readOnly {
+		if err := d.SaveConfig(); err != nil {
+			return iodine.New(err, errParams)
+		}
+	}
+	return nil
 }
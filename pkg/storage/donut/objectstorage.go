@@ -27,12 +27,18 @@ import (
 	"github.com/minio-io/minio/pkg/iodine"
 )
 
-// MakeBucket - make a new bucket
-func (d donut) MakeBucket(bucket, acl string) error {
+// MakeBucket - make a new bucket, its objects are written with storageClass unless they ask for
+// another one. Empty storageClass picks the default
+func (d donut) MakeBucket(bucket, acl, storageClass string) error {
 	if bucket == "" || strings.TrimSpace(bucket) == "" {
 		return iodine.New(errors.New("invalid argument"), nil)
 	}
-	return d.makeDonutBucket(bucket, acl)
+	if storageClass != "" {
+		if _, err := getStorageClass(storageClass); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return d.makeDonutBucket(bucket, acl, storageClass)
 }
 
 // GetBucketMetadata - get bucket metadata
@@ -41,14 +47,18 @@ func (d donut) GetBucketMetadata(bucket string) (map[string]string, error) {
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	if _, ok := d.getBucket(bucket); !ok {
 		return nil, iodine.New(errors.New("bucket does not exist"), nil)
 	}
 	metadata, err := d.getDonutBucketMetadata()
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	return metadata[bucket], nil
+	bucketMetadata, ok := metadata[bucket]
+	if !ok {
+		return nil, iodine.New(errors.New("bucket does not exist"), nil)
+	}
+	return bucketMetadata, nil
 }
 
 // SetBucketMetadata - set bucket metadata
@@ -57,15 +67,27 @@ func (d donut) SetBucketMetadata(bucket string, bucketMetadata map[string]string
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	metadata, err := d.getDonutBucketMetadata()
-	if err != nil {
-		return iodine.New(err, nil)
+	if storageClass := bucketMetadata["storageClass"]; storageClass != "" {
+		if _, err := getStorageClass(storageClass); err != nil {
+			return iodine.New(err, nil)
+		}
 	}
-	oldBucketMetadata := metadata[bucket]
-	// TODO ignore rest of the keys for now, only mutable data is "acl"
-	oldBucketMetadata["acl"] = bucketMetadata["acl"]
-	metadata[bucket] = oldBucketMetadata
-	return d.setDonutBucketMetadata(metadata)
+	// concurrent updates are serialized, each applied to the metadata left by the one before
+	return d.updateDonutBucketMetadata(func(metadata map[string]map[string]string) error {
+		oldBucketMetadata, ok := metadata[bucket]
+		if !ok {
+			return iodine.New(errors.New("bucket does not exist"), nil)
+		}
+		// TODO ignore rest of the keys for now, only mutable data is "acl", "accessControlPolicy"
+		// and the default "storageClass" of objects
+		for _, key := range []string{"acl", "accessControlPolicy", "storageClass"} {
+			if value, ok := bucketMetadata[key]; ok {
+				oldBucketMetadata[key] = value
+			}
+		}
+		metadata[bucket] = oldBucketMetadata
+		return nil
+	})
 }
 
 // ListBuckets - return list of buckets
@@ -76,11 +98,6 @@ func (d donut) ListBuckets() (results []string, err error) {
 	}
 	metadata, err := d.getDonutBucketMetadata()
 	if err != nil {
-		err = iodine.ToError(err)
-		if os.IsNotExist(err) {
-			// valid case
-			return nil, nil
-		}
 		return nil, iodine.New(err, nil)
 	}
 	for name := range metadata {
@@ -92,7 +109,6 @@ func (d donut) ListBuckets() (results []string, err error) {
 
 // ListObjects - return list of objects
 func (d donut) ListObjects(bucket, prefix, marker, delimiter string, maxkeys int) ([]string, []string, bool, error) {
-	// TODO: Marker is not yet handled please handle it
 	errParams := map[string]string{
 		"bucket":    bucket,
 		"prefix":    prefix,
@@ -104,51 +120,17 @@ func (d donut) ListObjects(bucket, prefix, marker, delimiter string, maxkeys int
 	if err != nil {
 		return nil, nil, false, iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, nil, false, iodine.New(errors.New("bucket does not exist"), errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
-	if err != nil {
-		return nil, nil, false, iodine.New(err, errParams)
-	}
-	var donutObjects []string
-	for objectName := range objectList {
-		donutObjects = append(donutObjects, objectName)
-	}
 	if maxkeys <= 0 {
 		maxkeys = 1000
 	}
-	if strings.TrimSpace(prefix) != "" {
-		donutObjects = filterPrefix(donutObjects, prefix)
-		donutObjects = removePrefix(donutObjects, prefix)
-	}
-
-	var actualObjects []string
-	var actualPrefixes []string
-	var isTruncated bool
-	if strings.TrimSpace(delimiter) != "" {
-		actualObjects = filterDelimited(donutObjects, delimiter)
-		actualPrefixes = filterNotDelimited(donutObjects, delimiter)
-		actualPrefixes = extractDir(actualPrefixes, delimiter)
-		actualPrefixes = uniqueObjects(actualPrefixes)
-	} else {
-		actualObjects = donutObjects
-	}
-
-	var results []string
-	var commonPrefixes []string
-	for _, objectName := range actualObjects {
-		if len(results) >= maxkeys {
-			isTruncated = true
-			break
-		}
-		results = appendUniq(results, prefix+objectName)
-	}
-	for _, commonPrefix := range actualPrefixes {
-		commonPrefixes = appendUniq(commonPrefixes, prefix+commonPrefix)
+	results, commonPrefixes, isTruncated, err := donutBucket.ListObjects(prefix, marker, delimiter, maxkeys)
+	if err != nil {
+		return nil, nil, false, iodine.New(err, errParams)
 	}
-	sort.Strings(results)
-	sort.Strings(commonPrefixes)
 	return results, commonPrefixes, isTruncated, nil
 }
 
@@ -168,19 +150,25 @@ func (d donut) PutObject(bucket, object, expectedMD5Sum string, reader io.ReadCl
 	if err != nil {
 		return iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return iodine.New(errors.New("bucket does not exist"), nil)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
-	if err != nil {
-		return iodine.New(err, nil)
+	// storage class requested along with the object, otherwise the one of the bucket
+	objectMetadata := make(map[string]string)
+	for k, v := range metadata {
+		objectMetadata[k] = v
+	}
+	if objectMetadata["storageClass"] == "" {
+		objectMetadata["storageClass"] = d.getBucketStorageClass(bucket)
 	}
-	for objectName := range objectList {
-		if objectName == object {
-			return iodine.New(errors.New("object exists"), nil)
+	if storageClass := objectMetadata["storageClass"]; storageClass != "" {
+		if _, err := getStorageClass(storageClass); err != nil {
+			return iodine.New(err, errParams)
 		}
 	}
-	err = d.buckets[bucket].PutObject(object, reader, expectedMD5Sum, metadata)
+	// an existing object is replaced
+	err = donutBucket.PutObject(object, reader, expectedMD5Sum, objectMetadata)
 	if err != nil {
 		return iodine.New(err, errParams)
 	}
@@ -203,19 +191,50 @@ func (d donut) GetObject(bucket, object string) (reader io.ReadCloser, size int6
 	if err != nil {
 		return nil, 0, iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, 0, iodine.New(errors.New("bucket does not exist"), errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
+	reader, size, err = donutBucket.GetObject(object)
 	if err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return nil, 0, iodine.New(errors.New("object not found"), nil)
+		}
 		return nil, 0, iodine.New(err, nil)
 	}
-	for objectName := range objectList {
-		if objectName == object {
-			return d.buckets[bucket].GetObject(object)
+	return reader, size, nil
+}
+
+// GetPartialObject - get length bytes of an object starting at start
+func (d donut) GetPartialObject(bucket, object string, start, length int64) (io.ReadCloser, error) {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+		"start":  strconv.FormatInt(start, 10),
+		"length": strconv.FormatInt(length, 10),
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return nil, iodine.New(errors.New("invalid argument"), errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return nil, iodine.New(errors.New("invalid argument"), errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return nil, iodine.New(errors.New("bucket does not exist"), errParams)
+	}
+	reader, err := donutBucket.GetPartialObject(object, start, length)
+	if err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return nil, iodine.New(errors.New("object not found"), errParams)
 		}
+		return nil, iodine.New(err, errParams)
 	}
-	return nil, 0, iodine.New(errors.New("object not found"), nil)
+	return reader, nil
 }
 
 // GetObjectMetadata - get object metadata
@@ -228,16 +247,39 @@ func (d donut) GetObjectMetadata(bucket, object string) (map[string]string, erro
 	if err != nil {
 		return nil, iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, iodine.New(errors.New("bucket does not exist"), errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
+	objectMetadata, err := donutBucket.GetObjectMetadata(object)
 	if err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return nil, iodine.New(errors.New("object does not exist"), errParams)
+		}
 		return nil, iodine.New(err, errParams)
 	}
-	donutObject, ok := objectList[object]
+	return objectMetadata, nil
+}
+
+// SetObjectMetadata - set object metadata, provided keys are merged into existing object metadata
+func (d donut) SetObjectMetadata(bucket, object string, metadata map[string]string) error {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
 	if !ok {
-		return nil, iodine.New(errors.New("object does not exist"), errParams)
+		return iodine.New(errors.New("bucket does not exist"), errParams)
 	}
-	return donutObject.GetObjectMetadata()
+	if err := donutBucket.SetObjectMetadata(object, metadata); err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return iodine.New(errors.New("object does not exist"), errParams)
+		}
+		return iodine.New(err, errParams)
+	}
+	return nil
 }
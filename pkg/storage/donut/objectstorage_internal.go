@@ -17,12 +17,9 @@
 package donut
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/minio-io/minio/pkg/iodine"
@@ -30,141 +27,117 @@ import (
 
 /// This file contains all the internal functions used by Object interface
 
-// getDiskWriters -
-func (d donut) getBucketMetadataWriters() ([]io.WriteCloser, error) {
-	var writers []io.WriteCloser
-	for _, node := range d.nodes {
-		disks, err := node.ListDisks()
-		if err != nil {
-			return nil, iodine.New(err, nil)
-		}
-		writers = make([]io.WriteCloser, len(disks))
-		for _, disk := range disks {
-			bucketMetaDataWriter, err := disk.MakeFile(path.Join(d.name, bucketMetadataConfig))
-			if err != nil {
-				return nil, iodine.New(err, nil)
-			}
-			writers[disk.GetOrder()] = bucketMetaDataWriter
-		}
-	}
-	return writers, nil
-}
-
-func (d donut) getBucketMetadataReaders() ([]io.ReadCloser, error) {
-	var readers []io.ReadCloser
-	for _, node := range d.nodes {
-		disks, err := node.ListDisks()
-		if err != nil {
-			return nil, iodine.New(err, nil)
-		}
-		readers = make([]io.ReadCloser, len(disks))
-		for _, disk := range disks {
-			bucketMetaDataReader, err := disk.OpenFile(path.Join(d.name, bucketMetadataConfig))
-			if err != nil {
-				return nil, iodine.New(err, nil)
-			}
-			readers[disk.GetOrder()] = bucketMetaDataReader
-		}
+// getBucketStorageClass - default storage class of objects in a bucket, empty if none was set
+// or bucket metadata is unreadable
+func (d donut) getBucketStorageClass(bucketName string) string {
+	metadata, err := d.getDonutBucketMetadata()
+	if err != nil {
+		return ""
 	}
-	return readers, nil
+	return metadata[bucketName]["storageClass"]
 }
 
-//
-func (d donut) setDonutBucketMetadata(metadata map[string]map[string]string) error {
-	writers, err := d.getBucketMetadataWriters()
+func (d donut) makeDonutBucket(bucketName, acl, storageClass string) error {
+	err := d.getDonutBuckets()
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	for _, writer := range writers {
-		defer writer.Close()
-	}
-	for _, writer := range writers {
-		jenc := json.NewEncoder(writer)
-		if err := jenc.Encode(metadata); err != nil {
-			return iodine.New(err, nil)
-		}
+	if _, ok := d.getBucket(bucketName); ok {
+		return iodine.New(errors.New("bucket exists"), nil)
 	}
-	return nil
-}
-
-func (d donut) getDonutBucketMetadata() (map[string]map[string]string, error) {
-	metadata := make(map[string]map[string]string)
-	readers, err := d.getBucketMetadataReaders()
+	bucket, bucketMetadata, err := NewBucket(bucketName, acl, d.name, d.getNodes, d.reserve)
 	if err != nil {
-		return nil, iodine.New(err, nil)
+		return iodine.New(err, nil)
 	}
-	for _, reader := range readers {
-		defer reader.Close()
+	if storageClass != "" {
+		bucketMetadata["storageClass"] = storageClass
 	}
-	for _, reader := range readers {
-		jenc := json.NewDecoder(reader)
-		if err := jenc.Decode(&metadata); err != nil {
-			return nil, iodine.New(err, nil)
-		}
+	if err := d.makeDonutBucketSlices(bucketName); err != nil {
+		return iodine.New(err, nil)
 	}
-	return metadata, nil
-}
-
-func (d donut) makeDonutBucket(bucketName, acl string) error {
-	err := d.getDonutBuckets()
+	err = d.updateDonutBucketMetadata(func(metadata map[string]map[string]string) error {
+		// made by a concurrent request in between
+		if _, ok := metadata[bucketName]; ok {
+			return iodine.New(errors.New("bucket exists"), nil)
+		}
+		metadata[bucketName] = bucketMetadata
+		return nil
+	})
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucketName]; ok {
-		return iodine.New(errors.New("bucket exists"), nil)
-	}
-	bucket, bucketMetadata, err := NewBucket(bucketName, acl, d.name, d.nodes)
+	// only known once made, a bucket found in between by a concurrent request is kept along
+	// with its lock
+	d.addBucket(bucketName, bucket)
+	return nil
+}
+
+// makeDonutBucketSlices - make bucket slice directories on every disk
+func (d donut) makeDonutBucketSlices(bucketName string) error {
+	donutDisks, err := getDonutDisks(d.getNodes())
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	nodeNumber := 0
-	d.buckets[bucketName] = bucket
-	for _, node := range d.nodes {
-		disks, err := node.ListDisks()
+	for _, setDisk := range donutDisks {
+		err := setDisk.disk.MakeDir(path.Join(d.name, setDisk.getBucketSlice(bucketName)))
 		if err != nil {
 			return iodine.New(err, nil)
 		}
-		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", bucketName, nodeNumber, disk.GetOrder())
-			err := disk.MakeDir(path.Join(d.name, bucketSlice))
-			if err != nil {
-				return iodine.New(err, nil)
-			}
-		}
-		nodeNumber = nodeNumber + 1
 	}
-	metadata, err := d.getDonutBucketMetadata()
-	if err != nil {
-		err = iodine.ToError(err)
-		if os.IsNotExist(err) {
-			metadata := make(map[string]map[string]string)
-			metadata[bucketName] = bucketMetadata
-			err = d.setDonutBucketMetadata(metadata)
-			if err != nil {
-				return iodine.New(err, nil)
-			}
-			return nil
-		}
-		return iodine.New(err, nil)
+	return nil
+}
+
+// getNodes - snapshot of the nodes of the donut
+func (d donut) getNodes() map[string]Node {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	nodes := make(map[string]Node)
+	for hostname, node := range d.nodes {
+		nodes[hostname] = node
 	}
-	metadata[bucketName] = bucketMetadata
-	err = d.setDonutBucketMetadata(metadata)
-	if err != nil {
-		return iodine.New(err, nil)
+	return nodes
+}
+
+// getBucket - known bucket by name
+func (d donut) getBucket(bucketName string) (Bucket, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	bucket, ok := d.buckets[bucketName]
+	return bucket, ok
+}
+
+// getBucketNames - snapshot of the names of all known buckets, in lexical order
+func (d donut) getBucketNames() []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	var bucketNames []string
+	for bucketName := range d.buckets {
+		bucketNames = append(bucketNames, bucketName)
+	}
+	sort.Strings(bucketNames)
+	return bucketNames
+}
+
+// addBucket - keep a bucket as known, unless one of the same name already is
+func (d donut) addBucket(bucketName string, bucket Bucket) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.buckets[bucketName]; !ok {
+		d.buckets[bucketName] = bucket
 	}
-	return nil
 }
 
 func (d donut) getDonutBuckets() error {
-	for _, node := range d.nodes {
+	for _, node := range d.getNodes() {
 		disks, err := node.ListDisks()
 		if err != nil {
 			return iodine.New(err, nil)
 		}
 		for _, disk := range disks {
+			// buckets are listed as long as any disk is available, a failed disk is healed later
 			dirs, err := disk.ListDir(d.name)
 			if err != nil {
-				return iodine.New(err, nil)
+				continue
 			}
 			for _, dir := range dirs {
 				splitDir := strings.Split(dir.Name(), "$")
@@ -172,12 +145,19 @@ func (d donut) getDonutBuckets() error {
 					return iodine.New(errors.New("corrupted backend"), nil)
 				}
 				bucketName := splitDir[0]
-				// we dont need this NewBucket once we cache from makeDonutBucket()
-				bucket, _, err := NewBucket(bucketName, "private", d.name, d.nodes)
+				// keep buckets already known, they carry their own lock
+				if _, ok := d.getBucket(bucketName); ok {
+					continue
+				}
+				bucket, _, err := NewBucket(bucketName, "private", d.name, d.getNodes, d.reserve)
 				if err != nil {
 					return iodine.New(err, nil)
 				}
-				d.buckets[bucketName] = bucket
+				// objects written by older versions are moved to their current names once
+				if err := bucket.MigrateObjectNames(); err != nil {
+					return iodine.New(err, nil)
+				}
+				d.addBucket(bucketName, bucket)
 			}
 		}
 	}
@@ -0,0 +1,147 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drivers
+
+// Please read for more information - http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html
+
+// Permission - access granted to a grantee
+type Permission string
+
+// different types of permissions currently supported
+const (
+	PermissionRead        = Permission("READ")
+	PermissionWrite       = Permission("WRITE")
+	PermissionReadACP     = Permission("READ_ACP")
+	PermissionWriteACP    = Permission("WRITE_ACP")
+	PermissionFullControl = Permission("FULL_CONTROL")
+)
+
+// IsValid - is permission supported
+func (p Permission) IsValid() bool {
+	switch p {
+	case PermissionRead, PermissionWrite, PermissionReadACP, PermissionWriteACP, PermissionFullControl:
+		return true
+	}
+	return false
+}
+
+// predefined groups a permission can be granted to
+const (
+	AllUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// DefaultOwner - owner of all buckets and objects, minio is single tenant for now
+var DefaultOwner = Owner{ID: "minio", DisplayName: "minio"}
+
+// Owner - canonical user owning a bucket or an object
+type Owner struct {
+	ID          string
+	DisplayName string
+}
+
+// Grantee - either a canonical user identified by ID or a predefined group identified by URI
+type Grantee struct {
+	ID          string
+	DisplayName string
+	URI         string
+}
+
+// IsGroup - is grantee a predefined group
+func (g Grantee) IsGroup() bool {
+	return g.URI != ""
+}
+
+// Grant - permission granted to a grantee
+type Grant struct {
+	Grantee    Grantee
+	Permission Permission
+}
+
+// AccessControlPolicy - owner and access control list of a bucket or an object
+type AccessControlPolicy struct {
+	Owner  Owner
+	Grants []Grant
+}
+
+// IsEmpty - policy was never set
+func (p AccessControlPolicy) IsEmpty() bool {
+	return p.Owner.ID == "" && len(p.Grants) == 0
+}
+
+// IsAllowed - verify if requester is granted permission, an empty requester is anonymous
+// and any other requester is the canonical user ID of an authenticated user
+func (p AccessControlPolicy) IsAllowed(requester string, permission Permission) bool {
+	// owner always has full control
+	if requester != "" && requester == p.Owner.ID {
+		return true
+	}
+	for _, grant := range p.Grants {
+		if grant.Permission != permission && grant.Permission != PermissionFullControl {
+			continue
+		}
+		switch {
+		case grant.Grantee.URI == AllUsersGroup:
+			return true
+		case grant.Grantee.URI == AuthenticatedUsersGroup && requester != "":
+			return true
+		case grant.Grantee.ID != "" && grant.Grantee.ID == requester:
+			return true
+		}
+	}
+	return false
+}
+
+// isGrantedToAllUsers - verify if a permission is granted to everyone
+func (p AccessControlPolicy) isGrantedToAllUsers(permission Permission) bool {
+	for _, grant := range p.Grants {
+		if grant.Grantee.URI == AllUsersGroup && (grant.Permission == permission || grant.Permission == PermissionFullControl) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCannedACL - closest canned acl representing this policy
+func (p AccessControlPolicy) GetCannedACL() BucketACL {
+	switch {
+	case p.isGrantedToAllUsers(PermissionRead) && p.isGrantedToAllUsers(PermissionWrite):
+		return BucketPublicReadWrite
+	case p.isGrantedToAllUsers(PermissionRead):
+		return BucketPublicRead
+	default:
+		return BucketPrivate
+	}
+}
+
+// NewCannedACLPolicy - access control policy equivalent of a canned acl
+func NewCannedACLPolicy(acl BucketACL, owner Owner) AccessControlPolicy {
+	policy := AccessControlPolicy{Owner: owner}
+	policy.Grants = append(policy.Grants, Grant{
+		Grantee:    Grantee{ID: owner.ID, DisplayName: owner.DisplayName},
+		Permission: PermissionFullControl,
+	})
+	allUsers := Grantee{URI: AllUsersGroup}
+	switch {
+	case acl.IsPublicRead():
+		policy.Grants = append(policy.Grants, Grant{Grantee: allUsers, Permission: PermissionRead})
+	case acl.IsPublicReadWrite():
+		policy.Grants = append(policy.Grants, Grant{Grantee: allUsers, Permission: PermissionRead})
+		policy.Grants = append(policy.Grants, Grant{Grantee: allUsers, Permission: PermissionWrite})
+	}
+	return policy
+}
@@ -34,9 +34,13 @@ func APITestSuite(c *check.C, create func() Driver) {
 	testCreateBucket(c, create)
 	testMultipleObjectCreation(c, create)
 	testPaging(c, create)
-	testObjectOverwriteFails(c, create)
+	testPagingWithMarker(c, create)
+	testObjectOverwriteWorks(c, create)
 	testNonExistantBucketOperations(c, create)
 	testBucketMetadata(c, create)
+	testBucketStorageClass(c, create)
+	testBucketACL(c, create)
+	testObjectACL(c, create)
 	testBucketRecreateFails(c, create)
 	testPutObjectInSubdir(c, create)
 	testListBuckets(c, create)
@@ -49,14 +53,14 @@ func APITestSuite(c *check.C, create func() Driver) {
 
 func testCreateBucket(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 }
 
 func testMultipleObjectCreation(c *check.C, create func() Driver) {
 	objects := make(map[string][]byte)
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 	for i := 0; i < 10; i++ {
 		randomPerm := rand.Perm(10)
@@ -71,7 +75,7 @@ func testMultipleObjectCreation(c *check.C, create func() Driver) {
 
 		key := "obj" + strconv.Itoa(i)
 		objects[key] = []byte(randomString)
-		err := drivers.CreateObject("bucket", key, "", md5Sum, bytes.NewBufferString(randomString))
+		err := drivers.CreateObject("bucket", key, "", "", md5Sum, int64(len(randomString)), bytes.NewBufferString(randomString), nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -95,7 +99,7 @@ func testMultipleObjectCreation(c *check.C, create func() Driver) {
 
 func testPaging(c *check.C, create func() Driver) {
 	drivers := create()
-	drivers.CreateBucket("bucket", "")
+	drivers.CreateBucket("bucket", "", "")
 	resources := BucketResourcesMetadata{}
 	objects, resources, err := drivers.ListObjects("bucket", resources)
 	c.Assert(err, check.IsNil)
@@ -104,7 +108,7 @@ func testPaging(c *check.C, create func() Driver) {
 	// check before paging occurs
 	for i := 0; i < 5; i++ {
 		key := "obj" + strconv.Itoa(i)
-		drivers.CreateObject("bucket", key, "", "", bytes.NewBufferString(key))
+		drivers.CreateObject("bucket", key, "", "", "", int64(len(key)), bytes.NewBufferString(key), nil)
 		resources.Maxkeys = 5
 		resources.Prefix = ""
 		objects, resources, err = drivers.ListObjects("bucket", resources)
@@ -115,7 +119,7 @@ func testPaging(c *check.C, create func() Driver) {
 	// check after paging occurs pages work
 	for i := 6; i <= 10; i++ {
 		key := "obj" + strconv.Itoa(i)
-		drivers.CreateObject("bucket", key, "", "", bytes.NewBufferString(key))
+		drivers.CreateObject("bucket", key, "", "", "", int64(len(key)), bytes.NewBufferString(key), nil)
 		resources.Maxkeys = 5
 		resources.Prefix = ""
 		objects, resources, err = drivers.ListObjects("bucket", resources)
@@ -125,8 +129,8 @@ func testPaging(c *check.C, create func() Driver) {
 	}
 	// check paging with prefix at end returns less objects
 	{
-		drivers.CreateObject("bucket", "newPrefix", "", "", bytes.NewBufferString("prefix1"))
-		drivers.CreateObject("bucket", "newPrefix2", "", "", bytes.NewBufferString("prefix2"))
+		drivers.CreateObject("bucket", "newPrefix", "", "", "", int64(len("prefix1")), bytes.NewBufferString("prefix1"), nil)
+		drivers.CreateObject("bucket", "newPrefix2", "", "", "", int64(len("prefix2")), bytes.NewBufferString("prefix2"), nil)
 		resources.Prefix = "new"
 		resources.Maxkeys = 5
 		objects, resources, err = drivers.ListObjects("bucket", resources)
@@ -147,8 +151,8 @@ func testPaging(c *check.C, create func() Driver) {
 
 	// check delimited results with delimiter and prefix
 	{
-		drivers.CreateObject("bucket", "this/is/delimited", "", "", bytes.NewBufferString("prefix1"))
-		drivers.CreateObject("bucket", "this/is/also/a/delimited/file", "", "", bytes.NewBufferString("prefix2"))
+		drivers.CreateObject("bucket", "this/is/delimited", "", "", "", int64(len("prefix1")), bytes.NewBufferString("prefix1"), nil)
+		drivers.CreateObject("bucket", "this/is/also/a/delimited/file", "", "", "", int64(len("prefix2")), bytes.NewBufferString("prefix2"), nil)
 		var prefixes []string
 		resources.CommonPrefixes = prefixes // allocate new everytime
 		resources.Delimiter = "/"
@@ -199,38 +203,149 @@ func testPaging(c *check.C, create func() Driver) {
 	}
 }
 
-func testObjectOverwriteFails(c *check.C, create func() Driver) {
+func testPagingWithMarker(c *check.C, create func() Driver) {
 	drivers := create()
-	drivers.CreateBucket("bucket", "")
+	drivers.CreateBucket("bucket", "", "")
+	keys := []string{"a", "b/1", "b/2", "c", "d/1", "d/2/x", "e"}
+	for _, key := range keys {
+		err := drivers.CreateObject("bucket", key, "", "", "", int64(len(key)), bytes.NewBufferString(key), nil)
+		c.Assert(err, check.IsNil)
+	}
+
+	// walk all pages using NextMarker
+	{
+		var listedKeys []string
+		resources := BucketResourcesMetadata{Maxkeys: 2}
+		for {
+			objects, newResources, err := drivers.ListObjects("bucket", resources)
+			c.Assert(err, check.IsNil)
+			for _, object := range objects {
+				listedKeys = append(listedKeys, object.Key)
+			}
+			if !newResources.IsTruncated {
+				c.Assert(newResources.NextMarker, check.Equals, "")
+				break
+			}
+			c.Assert(len(objects), check.Equals, 2)
+			c.Assert(newResources.NextMarker, check.Equals, objects[len(objects)-1].Key)
+			resources.Marker = newResources.NextMarker
+		}
+		c.Assert(listedKeys, check.DeepEquals, keys)
+	}
+
+	// common prefixes count towards maxkeys and can be used as NextMarker
+	{
+		resources := BucketResourcesMetadata{Maxkeys: 2, Delimiter: "/"}
+		objects, resources, err := drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 1)
+		c.Assert(objects[0].Key, check.Equals, "a")
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"b/"})
+		c.Assert(resources.IsTruncated, check.Equals, true)
+		c.Assert(resources.NextMarker, check.Equals, "b/")
+
+		resources.Marker = resources.NextMarker
+		objects, resources, err = drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 1)
+		c.Assert(objects[0].Key, check.Equals, "c")
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"d/"})
+		c.Assert(resources.IsTruncated, check.Equals, true)
+		c.Assert(resources.NextMarker, check.Equals, "d/")
+
+		resources.Marker = resources.NextMarker
+		objects, resources, err = drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 1)
+		c.Assert(objects[0].Key, check.Equals, "e")
+		c.Assert(len(resources.CommonPrefixes), check.Equals, 0)
+		c.Assert(resources.IsTruncated, check.Equals, false)
+	}
+
+	// marker with prefix and delimiter
+	{
+		resources := BucketResourcesMetadata{Maxkeys: 1, Prefix: "d/", Delimiter: "/"}
+		objects, resources, err := drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 1)
+		c.Assert(objects[0].Key, check.Equals, "d/1")
+		c.Assert(resources.IsTruncated, check.Equals, true)
+		c.Assert(resources.NextMarker, check.Equals, "d/1")
+
+		resources.Marker = resources.NextMarker
+		objects, resources, err = drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 0)
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"d/2/"})
+		c.Assert(resources.IsTruncated, check.Equals, false)
+	}
+
+	// a page ending on a common prefix is only truncated if entries outside of it follow
+	{
+		resources := BucketResourcesMetadata{Maxkeys: 1, Prefix: "b", Delimiter: "/"}
+		objects, resources, err := drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 0)
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"b/"})
+		c.Assert(resources.IsTruncated, check.Equals, false)
+		c.Assert(resources.NextMarker, check.Equals, "")
+
+		resources = BucketResourcesMetadata{Maxkeys: 4, Delimiter: "/"}
+		objects, resources, err = drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 2)
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"b/", "d/"})
+		c.Assert(resources.IsTruncated, check.Equals, true)
+		c.Assert(resources.NextMarker, check.Equals, "d/")
+	}
+}
+
+func testObjectOverwriteWorks(c *check.C, create func() Driver) {
+	drivers := create()
+	drivers.CreateBucket("bucket", "", "")
 
 	hasher1 := md5.New()
 	hasher1.Write([]byte("one"))
 	md5Sum1 := base64.StdEncoding.EncodeToString(hasher1.Sum(nil))
-	err := drivers.CreateObject("bucket", "object", "", md5Sum1, bytes.NewBufferString("one"))
+	err := drivers.CreateObject("bucket", "object", "", "", md5Sum1, int64(len("one")), bytes.NewBufferString("one"), nil)
 	c.Assert(err, check.IsNil)
 
 	hasher2 := md5.New()
 	hasher2.Write([]byte("three"))
 	md5Sum2 := base64.StdEncoding.EncodeToString(hasher2.Sum(nil))
-	err = drivers.CreateObject("bucket", "object", "", md5Sum2, bytes.NewBufferString("three"))
+	err = drivers.CreateObject("bucket", "object", "", "", md5Sum2, int64(len("three")), bytes.NewBufferString("three"), nil)
+	c.Assert(err, check.IsNil)
 
-	c.Assert(err, check.Not(check.IsNil))
 	var bytesBuffer bytes.Buffer
 	length, err := drivers.GetObject(&bytesBuffer, "bucket", "object")
-	c.Assert(length, check.Equals, int64(len("one")))
 	c.Assert(err, check.IsNil)
-	c.Assert(string(bytesBuffer.Bytes()), check.Equals, "one")
+	c.Assert(length, check.Equals, int64(len("three")))
+	c.Assert(string(bytesBuffer.Bytes()), check.Equals, "three")
+
+	metadata, err := drivers.GetObjectMetadata("bucket", "object", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.Size, check.Equals, int64(len("three")))
+
+	// a failed overwrite leaves the existing object intact
+	err = drivers.CreateObject("bucket", "object", "", "", md5Sum1, int64(len("four")), bytes.NewBufferString("four"), nil)
+	c.Assert(err, check.Not(check.IsNil))
+
+	var bytesBuffer2 bytes.Buffer
+	length, err = drivers.GetObject(&bytesBuffer2, "bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(length, check.Equals, int64(len("three")))
+	c.Assert(string(bytesBuffer2.Bytes()), check.Equals, "three")
 }
 
 func testNonExistantBucketOperations(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateObject("bucket", "object", "", "", bytes.NewBufferString("one"))
+	err := drivers.CreateObject("bucket", "object", "", "", "", int64(len("one")), bytes.NewBufferString("one"), nil)
 	c.Assert(err, check.Not(check.IsNil))
 }
 
 func testBucketMetadata(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("string", "")
+	err := drivers.CreateBucket("string", "", "")
 	c.Assert(err, check.IsNil)
 
 	metadata, err := drivers.GetBucketMetadata("string")
@@ -238,23 +353,106 @@ func testBucketMetadata(c *check.C, create func() Driver) {
 	c.Assert(metadata.ACL, check.Equals, BucketACL("private"))
 }
 
+func testBucketStorageClass(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "private", "REDUCED_REDUNDANCY")
+	c.Assert(err, check.IsNil)
+	metadata, err := drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.StorageClass, check.Equals, "REDUCED_REDUNDANCY")
+
+	err = drivers.CreateBucket("glacier", "private", "GLACIER")
+	c.Assert(err, check.Not(check.IsNil))
+	c.Assert(iodine.ToError(err), check.Equals, InvalidStorageClass{StorageClass: "GLACIER"})
+	err = drivers.CreateObject("bucket", "object", "", "GLACIER", "", 0, bytes.NewBufferString(""), nil)
+	c.Assert(iodine.ToError(err), check.Equals, InvalidStorageClass{StorageClass: "GLACIER"})
+}
+
+func testBucketACL(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "public-read", "")
+	c.Assert(err, check.IsNil)
+
+	metadata, err := drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("", PermissionRead), check.Equals, true)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("", PermissionWrite), check.Equals, false)
+
+	policy := AccessControlPolicy{Owner: DefaultOwner}
+	policy.Grants = append(policy.Grants, Grant{Grantee: Grantee{ID: "user"}, Permission: PermissionWrite})
+	policy.Grants = append(policy.Grants, Grant{Grantee: Grantee{URI: AuthenticatedUsersGroup}, Permission: PermissionRead})
+	err = drivers.SetBucketACL("bucket", policy)
+	c.Assert(err, check.IsNil)
+
+	metadata, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, BucketACL("private"))
+	c.Assert(metadata.AccessControlPolicy, check.DeepEquals, policy)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("", PermissionRead), check.Equals, false)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("other", PermissionRead), check.Equals, true)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("user", PermissionWrite), check.Equals, true)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("other", PermissionWrite), check.Equals, false)
+
+	err = drivers.SetBucketACL("nonexistbucket", policy)
+	c.Assert(err, check.Not(check.IsNil))
+}
+
+func testObjectACL(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "", "")
+	c.Assert(err, check.IsNil)
+	err = drivers.CreateObject("bucket", "object", "", "", "", int64(len("one")), bytes.NewBufferString("one"), nil)
+	c.Assert(err, check.IsNil)
+
+	metadata, err := drivers.GetObjectMetadata("bucket", "object", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.AccessControlPolicy.Owner, check.Equals, DefaultOwner)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("", PermissionRead), check.Equals, false)
+
+	policy := NewCannedACLPolicy(BucketPublicRead, DefaultOwner)
+	err = drivers.SetObjectACL("bucket", "object", policy)
+	c.Assert(err, check.IsNil)
+
+	metadata, err = drivers.GetObjectMetadata("bucket", "object", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.AccessControlPolicy, check.DeepEquals, policy)
+	c.Assert(metadata.AccessControlPolicy.IsAllowed("", PermissionRead), check.Equals, true)
+	c.Assert(metadata.Size, check.Equals, int64(3))
+
+	var byteBuffer bytes.Buffer
+	length, err := drivers.GetObject(&byteBuffer, "bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(length, check.Equals, int64(3))
+	c.Assert(byteBuffer.String(), check.Equals, "one")
+
+	err = drivers.SetObjectACL("bucket", "nonexistobject", policy)
+	c.Assert(err, check.Not(check.IsNil))
+
+	// policy written along with the object
+	err = drivers.CreateObject("bucket", "public", "", "", "", int64(len("two")), bytes.NewBufferString("two"), &policy)
+	c.Assert(err, check.IsNil)
+	metadata, err = drivers.GetObjectMetadata("bucket", "public", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.AccessControlPolicy, check.DeepEquals, policy)
+}
+
 func testBucketRecreateFails(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("string", "")
+	err := drivers.CreateBucket("string", "", "")
 	c.Assert(err, check.IsNil)
-	err = drivers.CreateBucket("string", "")
+	err = drivers.CreateBucket("string", "", "")
 	c.Assert(err, check.Not(check.IsNil))
 }
 
 func testPutObjectInSubdir(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 
 	hasher := md5.New()
 	hasher.Write([]byte("hello world"))
 	md5Sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
-	err = drivers.CreateObject("bucket", "dir1/dir2/object", "", md5Sum, bytes.NewBufferString("hello world"))
+	err = drivers.CreateObject("bucket", "dir1/dir2/object", "", "", md5Sum, int64(len("hello world")), bytes.NewBufferString("hello world"), nil)
 	c.Assert(err, check.IsNil)
 
 	var bytesBuffer bytes.Buffer
@@ -273,7 +471,7 @@ func testListBuckets(c *check.C, create func() Driver) {
 	c.Assert(len(buckets), check.Equals, 0)
 
 	// add one and test exists
-	err = drivers.CreateBucket("bucket1", "")
+	err = drivers.CreateBucket("bucket1", "", "")
 	c.Assert(err, check.IsNil)
 
 	buckets, err = drivers.ListBuckets()
@@ -281,7 +479,7 @@ func testListBuckets(c *check.C, create func() Driver) {
 	c.Assert(err, check.IsNil)
 
 	// add two and test exists
-	err = drivers.CreateBucket("bucket2", "")
+	err = drivers.CreateBucket("bucket2", "", "")
 	c.Assert(err, check.IsNil)
 
 	buckets, err = drivers.ListBuckets()
@@ -289,7 +487,7 @@ func testListBuckets(c *check.C, create func() Driver) {
 	c.Assert(err, check.IsNil)
 
 	// add three and test exists + prefix
-	err = drivers.CreateBucket("bucket22", "")
+	err = drivers.CreateBucket("bucket22", "", "")
 
 	buckets, err = drivers.ListBuckets()
 	c.Assert(len(buckets), check.Equals, 3)
@@ -302,8 +500,8 @@ func testListBucketsOrder(c *check.C, create func() Driver) {
 	for i := 0; i < 10; i++ {
 		drivers := create()
 		// add one and test exists
-		drivers.CreateBucket("bucket1", "")
-		drivers.CreateBucket("bucket2", "")
+		drivers.CreateBucket("bucket1", "", "")
+		drivers.CreateBucket("bucket2", "", "")
 
 		buckets, err := drivers.ListBuckets()
 		c.Assert(len(buckets), check.Equals, 2)
@@ -324,7 +522,7 @@ func testListObjectsTestsForNonExistantBucket(c *check.C, create func() Driver)
 
 func testNonExistantObjectInBucket(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 
 	var byteBuffer bytes.Buffer
@@ -346,10 +544,10 @@ func testNonExistantObjectInBucket(c *check.C, create func() Driver) {
 
 func testGetDirectoryReturnsObjectNotFound(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 
-	err = drivers.CreateObject("bucket", "dir1/dir2/object", "", "", bytes.NewBufferString("hello world"))
+	err = drivers.CreateObject("bucket", "dir1/dir2/object", "", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"), nil)
 	c.Assert(err, check.IsNil)
 
 	var byteBuffer bytes.Buffer
@@ -389,23 +587,23 @@ func testGetDirectoryReturnsObjectNotFound(c *check.C, create func() Driver) {
 
 func testDefaultContentType(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 
 	// test empty
-	err = drivers.CreateObject("bucket", "one", "", "", bytes.NewBufferString("one"))
+	err = drivers.CreateObject("bucket", "one", "", "", "", int64(len("one")), bytes.NewBufferString("one"), nil)
 	metadata, err := drivers.GetObjectMetadata("bucket", "one", "")
 	c.Assert(err, check.IsNil)
 	c.Assert(metadata.ContentType, check.Equals, "application/octet-stream")
 
 	// test custom
-	drivers.CreateObject("bucket", "two", "application/text", "", bytes.NewBufferString("two"))
+	drivers.CreateObject("bucket", "two", "application/text", "", "", int64(len("two")), bytes.NewBufferString("two"), nil)
 	metadata, err = drivers.GetObjectMetadata("bucket", "two", "")
 	c.Assert(err, check.IsNil)
 	c.Assert(metadata.ContentType, check.Equals, "application/text")
 
 	// test trim space
-	drivers.CreateObject("bucket", "three", "\tapplication/json    ", "", bytes.NewBufferString("three"))
+	drivers.CreateObject("bucket", "three", "\tapplication/json    ", "", "", int64(len("three")), bytes.NewBufferString("three"), nil)
 	metadata, err = drivers.GetObjectMetadata("bucket", "three", "")
 	c.Assert(err, check.IsNil)
 	c.Assert(metadata.ContentType, check.Equals, "application/json")
@@ -413,12 +611,12 @@ func testDefaultContentType(c *check.C, create func() Driver) {
 
 func testContentMd5Set(c *check.C, create func() Driver) {
 	drivers := create()
-	err := drivers.CreateBucket("bucket", "")
+	err := drivers.CreateBucket("bucket", "", "")
 	c.Assert(err, check.IsNil)
 
 	// test md5 invalid
-	err = drivers.CreateObject("bucket", "one", "", "NWJiZjVhNTIzMjhlNzQzOWFlNmU3MTlkZmU3MTIyMDA", bytes.NewBufferString("one"))
+	err = drivers.CreateObject("bucket", "one", "", "", "NWJiZjVhNTIzMjhlNzQzOWFlNmU3MTlkZmU3MTIyMDA", int64(len("one")), bytes.NewBufferString("one"), nil)
 	c.Assert(err, check.Not(check.IsNil))
-	err = drivers.CreateObject("bucket", "two", "", "NWJiZjVhNTIzMjhlNzQzOWFlNmU3MTlkZmU3MTIyMDA=", bytes.NewBufferString("one"))
+	err = drivers.CreateObject("bucket", "two", "", "", "NWJiZjVhNTIzMjhlNzQzOWFlNmU3MTlkZmU3MTIyMDA=", int64(len("one")), bytes.NewBufferString("one"), nil)
 	c.Assert(err, check.IsNil)
 }
@@ -19,6 +19,7 @@ package donut
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"os"
 	"path"
@@ -38,11 +39,14 @@ import (
 // donutDriver - creates a new single disk drivers driver using donut
 type donutDriver struct {
 	donut donut.Donut
+	// disks are locked against other processes modifying the donut while it is served
+	lock  donut.DiskLock
 	paths []string
 }
 
 const (
-	blockSize = 10 * 1024 * 1024
+	// delay before migrating objects onto newly attached disks in the background
+	rebalanceStartDelay = time.Minute
 )
 
 // This is a dummy nodeDiskMap which is going to be deprecated soon
@@ -50,13 +54,13 @@ const (
 // to show multi disk API correctness and parity calculation
 //
 // Ideally this should be obtained from per node configuration file
-func createNodeDiskMap(p string) map[string][]string {
+func createNodeDiskMap(p string, create bool) map[string][]string {
 	nodes := make(map[string][]string)
 	nodes["localhost"] = make([]string, 16)
 	for i := 0; i < len(nodes["localhost"]); i++ {
 		diskPath := path.Join(p, strconv.Itoa(i))
 		if _, err := os.Stat(diskPath); err != nil {
-			if os.IsNotExist(err) {
+			if os.IsNotExist(err) && create {
 				os.MkdirAll(diskPath, 0700)
 			}
 		}
@@ -68,54 +72,131 @@ func createNodeDiskMap(p string) map[string][]string {
 // This is a dummy nodeDiskMap which is going to be deprecated soon
 // once the Management API is standardized, and we have way of adding
 // and removing disks. This is useful for now to take inputs from CLI
-func createNodeDiskMapFromSlice(paths []string) map[string][]string {
-	diskPaths := make([]string, len(paths))
+func createNodeDiskMapFromSlice(paths []string, create bool) map[string][]string {
 	nodes := make(map[string][]string)
 	for i, p := range paths {
+		// disks exported by other hosts are attached as is, one node per host
+		if donut.IsRemoteDisk(p) {
+			host := p[:strings.Index(p, "/")]
+			nodes[host] = append(nodes[host], p)
+			continue
+		}
 		diskPath := path.Join(p, strconv.Itoa(i))
 		if _, err := os.Stat(diskPath); err != nil {
-			if os.IsNotExist(err) {
+			if os.IsNotExist(err) && create {
 				os.MkdirAll(diskPath, 0700)
 			}
 		}
-		diskPaths[i] = diskPath
+		nodes["localhost"] = append(nodes["localhost"], diskPath)
 	}
-	nodes["localhost"] = diskPaths
 	return nodes
 }
 
+// CreateNodeDiskMap - disks of every node of the donut served from paths, a single local path
+// holds all disks of the donut. Local disks missing are created
+func CreateNodeDiskMap(paths []string) map[string][]string {
+	if len(paths) == 1 && !donut.IsRemoteDisk(paths[0]) {
+		return createNodeDiskMap(paths[0], true)
+	}
+	return createNodeDiskMapFromSlice(paths, true)
+}
+
+// getNodeDiskMap - disks of every node of the donut served from paths, without creating any
+func getNodeDiskMap(paths []string) map[string][]string {
+	if len(paths) == 1 && !donut.IsRemoteDisk(paths[0]) {
+		return createNodeDiskMap(paths[0], false)
+	}
+	return createNodeDiskMapFromSlice(paths, false)
+}
+
+// LockDonut - lock all disks of the donut served from paths against other processes modifying
+// it, fails with donut.ErrDiskLocked while it is served or modified by another process
+func LockDonut(paths []string) (donut.DiskLock, error) {
+	lock, err := donut.LockDisks(CreateNodeDiskMap(paths))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return lock, nil
+}
+
+// OpenDonut - open the donut served from paths, its topology restored from the disks. Staged
+// objects left by a crash are recovered, disks are to be locked with LockDonut beforehand
+func OpenDonut(paths []string) (donut.Donut, error) {
+	// Soon to be user configurable, when Management API is available
+	// we should remove "default" to something which is passed down
+	// from configuration paramters
+	d, err := donut.NewDonut("default", CreateNodeDiskMap(paths))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	// disk order never changes between restarts
+	if err := d.LoadConfig(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return d, nil
+}
+
+// OpenReadOnlyDonut - open the donut served from paths for inspection only, safe while it is
+// served by another process
+func OpenReadOnlyDonut(paths []string) (donut.Donut, error) {
+	d, err := donut.NewReadOnlyDonut("default", getNodeDiskMap(paths))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if err := d.LoadConfig(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return d, nil
+}
+
 // Start a single disk subsystem
 func Start(paths []string) (chan<- string, <-chan error, drivers.Driver) {
 	ctrlChannel := make(chan string)
 	errorChannel := make(chan error)
 
-	// Soon to be user configurable, when Management API is available
-	// we should remove "default" to something which is passed down
-	// from configuration paramters
+	// held for as long as the process serves the donut
+	lock, err := LockDonut(paths)
 	var d donut.Donut
-	var err error
-	if len(paths) == 1 {
-		d, err = donut.NewDonut("default", createNodeDiskMap(paths[0]))
-		if err != nil {
-			err = iodine.New(err, nil)
-			log.Error.Println(err)
+	if err == nil {
+		d, err = OpenDonut(paths)
+	}
+	if err == nil {
+		err = d.SaveConfig()
+	}
+	if err != nil {
+		err = iodine.New(err, nil)
+		// nothing is served, the donut is left to other processes
+		if lock != nil {
+			lock.Unlock()
+			lock = nil
 		}
-	} else {
-		d, err = donut.NewDonut("default", createNodeDiskMapFromSlice(paths))
-		if err != nil {
-			err = iodine.New(err, nil)
-			log.Error.Println(err)
+	}
+	// verify all objects for bitrot at low priority in the background
+	if err == nil {
+		if err := d.StartScrubber(); err != nil {
+			log.Error.Println(iodine.New(err, nil))
 		}
+		// migrate objects onto disks attached since they were written, once the server is up
+		time.AfterFunc(rebalanceStartDelay, func() {
+			if err := d.Rebalance(); err != nil {
+				log.Error.Println(iodine.New(err, nil))
+			}
+		})
 	}
 	s := new(donutDriver)
 	s.donut = d
+	s.lock = lock
 	s.paths = paths
 
-	go start(ctrlChannel, errorChannel, s)
+	go start(ctrlChannel, errorChannel, s, err)
 	return ctrlChannel, errorChannel, s
 }
 
-func start(ctrlChannel <-chan string, errorChannel chan<- error, s *donutDriver) {
+// start - report the donut failing to open, it is not served
+func start(ctrlChannel <-chan string, errorChannel chan<- error, s *donutDriver, err error) {
+	if err != nil {
+		errorChannel <- err
+	}
 	close(errorChannel)
 }
 
@@ -145,15 +226,18 @@ func (d donutDriver) ListBuckets() (results []drivers.BucketMetadata, err error)
 }
 
 // CreateBucket creates a new bucket
-func (d donutDriver) CreateBucket(bucketName, acl string) error {
+func (d donutDriver) CreateBucket(bucketName, acl, storageClass string) error {
 	if !drivers.IsValidBucketACL(acl) {
 		return iodine.New(drivers.InvalidACL{ACL: acl}, nil)
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, nil)
+	}
 	if drivers.IsValidBucket(bucketName) && !strings.Contains(bucketName, ".") {
 		if strings.TrimSpace(acl) == "" {
 			acl = "private"
 		}
-		if err := d.donut.MakeBucket(bucketName, acl); err != nil {
+		if err := d.donut.MakeBucket(bucketName, acl, storageClass); err != nil {
 			err = iodine.ToError(err)
 			if err.Error() == "bucket exists" {
 				return iodine.New(drivers.BucketExists{Bucket: bucketName}, nil)
@@ -182,24 +266,64 @@ func (d donutDriver) GetBucketMetadata(bucketName string) (drivers.BucketMetadat
 	if !ok {
 		return drivers.BucketMetadata{}, iodine.New(drivers.BackendCorrupted{}, nil)
 	}
+	policy, err := getAccessControlPolicy(metadata, drivers.BucketACL(acl))
+	if err != nil {
+		return drivers.BucketMetadata{}, iodine.New(drivers.BackendCorrupted{}, nil)
+	}
 	bucketMetadata := drivers.BucketMetadata{
-		Name:    bucketName,
-		Created: created,
-		ACL:     drivers.BucketACL(acl),
+		Name:                bucketName,
+		Created:             created,
+		ACL:                 drivers.BucketACL(acl),
+		AccessControlPolicy: policy,
+		StorageClass:        metadata["storageClass"],
 	}
 	return bucketMetadata, nil
 }
 
+// getAccessControlPolicy - decode access control policy stored in donut metadata, buckets
+// and objects created without one fall back to the canned acl
+func getAccessControlPolicy(metadata map[string]string, acl drivers.BucketACL) (drivers.AccessControlPolicy, error) {
+	policyJSON, ok := metadata["accessControlPolicy"]
+	if !ok {
+		return drivers.NewCannedACLPolicy(acl, drivers.DefaultOwner), nil
+	}
+	var policy drivers.AccessControlPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return drivers.AccessControlPolicy{}, iodine.New(err, nil)
+	}
+	return policy, nil
+}
+
+// setAccessControlPolicy - encode access control policy into donut metadata
+func setAccessControlPolicy(metadata map[string]string, policy drivers.AccessControlPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	metadata["accessControlPolicy"] = string(policyJSON)
+	return nil
+}
+
 // SetBucketMetadata sets bucket's metadata
-func (d donutDriver) SetBucketMetadata(bucketName, acl string) error {
+func (d donutDriver) SetBucketMetadata(bucketName, acl, storageClass string) error {
 	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
 		return drivers.BucketNameInvalid{Bucket: bucketName}
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, nil)
+	}
 	if strings.TrimSpace(acl) == "" {
 		acl = "private"
 	}
 	bucketMetadata := make(map[string]string)
 	bucketMetadata["acl"] = acl
+	if err := setAccessControlPolicy(bucketMetadata, drivers.NewCannedACLPolicy(drivers.BucketACL(acl), drivers.DefaultOwner)); err != nil {
+		return iodine.New(err, nil)
+	}
+	// empty keeps the storage class of the bucket
+	if storageClass != "" {
+		bucketMetadata["storageClass"] = storageClass
+	}
 	err := d.donut.SetBucketMetadata(bucketName, bucketMetadata)
 	if err != nil {
 		return iodine.New(drivers.BucketNotFound{Bucket: bucketName}, nil)
@@ -207,6 +331,48 @@ func (d donutDriver) SetBucketMetadata(bucketName, acl string) error {
 	return nil
 }
 
+// SetBucketACL sets bucket's access control policy
+func (d donutDriver) SetBucketACL(bucketName string, policy drivers.AccessControlPolicy) error {
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
+	}
+	bucketMetadata := make(map[string]string)
+	bucketMetadata["acl"] = policy.GetCannedACL().String()
+	if err := setAccessControlPolicy(bucketMetadata, policy); err != nil {
+		return iodine.New(err, nil)
+	}
+	err := d.donut.SetBucketMetadata(bucketName, bucketMetadata)
+	if err != nil {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucketName}, nil)
+	}
+	return nil
+}
+
+// SetObjectACL sets object's access control policy
+func (d donutDriver) SetObjectACL(bucketName, objectName string, policy drivers.AccessControlPolicy) error {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"objectName": objectName,
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, errParams)
+	}
+	if !drivers.IsValidObject(objectName) || strings.TrimSpace(objectName) == "" {
+		return iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
+	}
+	metadata := make(map[string]string)
+	if err := setAccessControlPolicy(metadata, policy); err != nil {
+		return iodine.New(err, errParams)
+	}
+	if err := d.donut.SetObjectMetadata(bucketName, objectName, metadata); err != nil {
+		return iodine.New(drivers.ObjectNotFound{
+			Bucket: bucketName,
+			Object: objectName,
+		}, errParams)
+	}
+	return nil
+}
+
 // GetObject retrieves an object and writes it to a writer
 func (d donutDriver) GetObject(target io.Writer, bucketName, objectName string) (int64, error) {
 	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
@@ -228,7 +394,6 @@ func (d donutDriver) GetObject(target io.Writer, bucketName, objectName string)
 
 // GetPartialObject retrieves an object range and writes it to a writer
 func (d donutDriver) GetPartialObject(w io.Writer, bucketName, objectName string, start, length int64) (int64, error) {
-	// TODO more efficient get partial object with proper donut support
 	errParams := map[string]string{
 		"bucketName": bucketName,
 		"objectName": objectName,
@@ -247,24 +412,29 @@ func (d donutDriver) GetPartialObject(w io.Writer, bucketName, objectName string
 			Length: length,
 		}, errParams)
 	}
-	reader, size, err := d.donut.GetObject(bucketName, objectName)
-	defer reader.Close()
+	metadata, err := d.donut.GetObjectMetadata(bucketName, objectName)
 	if err != nil {
 		return 0, iodine.New(drivers.ObjectNotFound{
 			Bucket: bucketName,
 			Object: objectName,
 		}, nil)
 	}
-	if start > size || (start+length-1) > size {
+	size, err := strconv.ParseInt(metadata["size"], 10, 64)
+	if err != nil {
+		return 0, iodine.New(err, errParams)
+	}
+	if start > size || start+length > size {
 		return 0, iodine.New(drivers.InvalidRange{
 			Start:  start,
 			Length: length,
 		}, errParams)
 	}
-	_, err = io.CopyN(ioutil.Discard, reader, start)
+	// only the chunks covering the range are read from disks
+	reader, err := d.donut.GetPartialObject(bucketName, objectName, start, length)
 	if err != nil {
 		return 0, iodine.New(err, errParams)
 	}
+	defer reader.Close()
 	n, err := io.CopyN(w, reader, length)
 	if err != nil {
 		return 0, iodine.New(err, errParams)
@@ -300,6 +470,10 @@ func (d donutDriver) GetObjectMetadata(bucketName, objectName, prefixName string
 	if err != nil {
 		return drivers.ObjectMetadata{}, iodine.New(err, errParams)
 	}
+	policy, err := getAccessControlPolicy(metadata, drivers.BucketPrivate)
+	if err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, errParams)
+	}
 	objectMetadata := drivers.ObjectMetadata{
 		Bucket: bucketName,
 		Key:    objectName,
@@ -308,6 +482,8 @@ func (d donutDriver) GetObjectMetadata(bucketName, objectName, prefixName string
 		Created:     created,
 		Md5:         metadata["md5"],
 		Size:        size,
+
+		AccessControlPolicy: policy,
 	}
 	return objectMetadata, nil
 }
@@ -339,6 +515,10 @@ func (d donutDriver) ListObjects(bucketName string, resources drivers.BucketReso
 	}
 	resources.CommonPrefixes = commonPrefixes
 	resources.IsTruncated = isTruncated
+	resources.NextMarker = ""
+	if isTruncated {
+		resources.NextMarker = drivers.GetNextMarker(actualObjects, commonPrefixes)
+	}
 
 	var results []drivers.ObjectMetadata
 	for _, objectName := range actualObjects {
@@ -366,11 +546,12 @@ func (d donutDriver) ListObjects(bucketName string, resources drivers.BucketReso
 }
 
 // CreateObject creates a new object
-func (d donutDriver) CreateObject(bucketName, objectName, contentType, expectedMD5Sum string, reader io.Reader) error {
+func (d donutDriver) CreateObject(bucketName, objectName, contentType, storageClass, expectedMD5Sum string, size int64, reader io.Reader, policy *drivers.AccessControlPolicy) error {
 	errParams := map[string]string{
-		"bucketName":  bucketName,
-		"objectName":  objectName,
-		"contentType": contentType,
+		"bucketName":   bucketName,
+		"objectName":   objectName,
+		"contentType":  contentType,
+		"storageClass": storageClass,
 	}
 	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
@@ -381,8 +562,25 @@ func (d donutDriver) CreateObject(bucketName, objectName, contentType, expectedM
 	if strings.TrimSpace(contentType) == "" {
 		contentType = "application/octet-stream"
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, errParams)
+	}
 	metadata := make(map[string]string)
 	metadata["contentType"] = strings.TrimSpace(contentType)
+	// empty picks the storage class of the bucket
+	if storageClass != "" {
+		metadata["storageClass"] = storageClass
+	}
+	// written along with the object, never visible without it
+	if policy != nil {
+		if err := setAccessControlPolicy(metadata, *policy); err != nil {
+			return iodine.New(err, errParams)
+		}
+	}
+	// free space of the disks is verified upfront when the size is known
+	if size >= 0 {
+		metadata["contentLength"] = strconv.FormatInt(size, 10)
+	}
 
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		expectedMD5SumBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(expectedMD5Sum))
@@ -393,6 +591,9 @@ func (d donutDriver) CreateObject(bucketName, objectName, contentType, expectedM
 	}
 	err := d.donut.PutObject(bucketName, objectName, expectedMD5Sum, ioutil.NopCloser(reader), metadata)
 	if err != nil {
+		if iodine.ToError(err) == donut.ErrStorageFull {
+			return iodine.New(drivers.StorageFull{Bucket: bucketName, Object: objectName}, errParams)
+		}
 		return iodine.New(err, errParams)
 	}
 	return nil
@@ -19,9 +19,12 @@ package donut
 import (
 	"io/ioutil"
 	"os"
+	"path"
 	"testing"
 
 	. "github.com/minio-io/check"
+	"github.com/minio-io/minio/pkg/iodine"
+	"github.com/minio-io/minio/pkg/storage/donut"
 	"github.com/minio-io/minio/pkg/storage/drivers"
 )
 
@@ -46,6 +49,32 @@ func (s *MySuite) TestAPISuite(c *C) {
 	removeRoots(c, storageList)
 }
 
+func (s *MySuite) TestServedDonutLocked(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start([]string{root})
+	c.Assert(store.CreateBucket("foo", "private", ""), IsNil)
+
+	// modifying the donut while it is served is refused, inspecting it is not
+	_, err = LockDonut([]string{root})
+	c.Assert(iodine.ToError(err), Equals, donut.ErrDiskLocked)
+	d, err := OpenReadOnlyDonut([]string{root})
+	c.Assert(err, IsNil)
+	buckets, err := d.ListBuckets()
+	c.Assert(err, IsNil)
+	c.Assert(buckets, DeepEquals, []string{"foo"})
+	c.Assert(d.SaveConfig(), Not(IsNil))
+
+	// serving it twice fails to start
+	_, errorChannel, _ := Start([]string{root})
+	c.Assert(iodine.ToError(<-errorChannel), Equals, donut.ErrDiskLocked)
+
+	// a donut never served is not opened read only
+	_, err = OpenReadOnlyDonut([]string{path.Join(root, "blank")})
+	c.Assert(err, Not(IsNil))
+}
+
 func removeRoots(c *C, roots []string) {
 	for _, root := range roots {
 		err := os.RemoveAll(root)
@@ -28,16 +28,18 @@ import (
 type Driver interface {
 	// Bucket Operations
 	ListBuckets() ([]BucketMetadata, error)
-	CreateBucket(bucket, acl string) error
+	CreateBucket(bucket, acl, storageClass string) error
 	GetBucketMetadata(bucket string) (BucketMetadata, error)
-	SetBucketMetadata(bucket, acl string) error
+	SetBucketMetadata(bucket, acl, storageClass string) error
+	SetBucketACL(bucket string, policy AccessControlPolicy) error
 
 	// Object Operations
 	GetObject(w io.Writer, bucket, object string) (int64, error)
 	GetPartialObject(w io.Writer, bucket, object string, start, length int64) (int64, error)
 	GetObjectMetadata(bucket string, object string, prefix string) (ObjectMetadata, error)
 	ListObjects(bucket string, resources BucketResourcesMetadata) ([]ObjectMetadata, BucketResourcesMetadata, error)
-	CreateObject(bucket string, key string, contentType string, md5sum string, data io.Reader) error
+	CreateObject(bucket string, key string, contentType string, storageClass string, md5sum string, size int64, data io.Reader, policy *AccessControlPolicy) error
+	SetObjectACL(bucket, key string, policy AccessControlPolicy) error
 }
 
 // BucketACL - bucket level access control
@@ -71,9 +73,12 @@ func (b BucketACL) IsPublicReadWrite() bool {
 
 // BucketMetadata - name and create date
 type BucketMetadata struct {
-	Name    string
-	Created time.Time
-	ACL     BucketACL
+	Name                string
+	Created             time.Time
+	ACL                 BucketACL
+	AccessControlPolicy AccessControlPolicy
+	// default storage class of objects, empty if none was set
+	StorageClass string
 }
 
 // ObjectMetadata - object key and its relevant metadata
@@ -85,6 +90,8 @@ type ObjectMetadata struct {
 	Created     time.Time
 	Md5         string
 	Size        int64
+
+	AccessControlPolicy AccessControlPolicy
 }
 
 // FilterMode type
@@ -102,6 +109,7 @@ const (
 type BucketResourcesMetadata struct {
 	Prefix         string
 	Marker         string
+	NextMarker     string
 	Maxkeys        int
 	Delimiter      string
 	IsTruncated    bool
@@ -147,6 +155,20 @@ func IsValidBucketACL(acl string) bool {
 	}
 }
 
+// IsValidStorageClass - is provided storage class supported, empty picks the bucket default
+func IsValidStorageClass(storageClass string) bool {
+	switch storageClass {
+	case "STANDARD":
+		fallthrough
+	case "REDUCED_REDUNDANCY":
+		return true
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
 // IsDelimiterPrefixSet Delimiter and Prefix set
 func (b BucketResourcesMetadata) IsDelimiterPrefixSet() bool {
 	return b.Mode == DelimiterPrefixMode
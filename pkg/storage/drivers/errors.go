@@ -67,6 +67,15 @@ func (e InvalidACL) Error() string {
 	return "Requested ACL is " + e.ACL + " invalid"
 }
 
+// InvalidStorageClass - storage class invalid
+type InvalidStorageClass struct {
+	StorageClass string
+}
+
+func (e InvalidStorageClass) Error() string {
+	return "Requested storage class " + e.StorageClass + " is invalid"
+}
+
 /// Bucket related errors
 
 // BucketNameInvalid - bucketname provided is invalid
@@ -99,6 +108,9 @@ type EntityTooLarge struct {
 // ObjectNameInvalid - object name provided is invalid
 type ObjectNameInvalid GenericObjectError
 
+// StorageFull - not enough free space left to store an object
+type StorageFull GenericObjectError
+
 // BadDigest - md5 mismatch from data received
 type BadDigest DigestError
 
@@ -167,6 +179,11 @@ func (e ObjectNameInvalid) Error() string {
 	return "Object name invalid: " + e.Bucket + "#" + e.Object
 }
 
+// Return string an error formatted as the given text
+func (e StorageFull) Error() string {
+	return "Storage full, not enough free space left for: " + e.Bucket + "#" + e.Object
+}
+
 // Return string an error formatted as the given text
 func (e EntityTooLarge) Error() string {
 	return e.Bucket + "#" + e.Object + "with " + e.Size + "reached maximum allowed size limit " + e.TotalSize
@@ -17,7 +17,6 @@
 package memory
 
 import (
-	"bufio"
 	"bytes"
 	"io"
 	"sort"
@@ -155,12 +154,16 @@ func (memory *memoryDriver) GetBucketMetadata(bucket string) (drivers.BucketMeta
 }
 
 // SetBucketMetadata -
-func (memory *memoryDriver) SetBucketMetadata(bucket, acl string) error {
+func (memory *memoryDriver) SetBucketMetadata(bucket, acl, storageClass string) error {
 	memory.lock.RLock()
 	if !drivers.IsValidBucket(bucket) {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		memory.lock.RUnlock()
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, nil)
+	}
 	if _, ok := memory.bucketMetadata[bucket]; ok == false {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
@@ -173,10 +176,54 @@ func (memory *memoryDriver) SetBucketMetadata(bucket, acl string) error {
 	defer memory.lock.Unlock()
 	storedBucket := memory.bucketMetadata[bucket]
 	storedBucket.metadata.ACL = drivers.BucketACL(acl)
+	storedBucket.metadata.AccessControlPolicy = drivers.NewCannedACLPolicy(drivers.BucketACL(acl), drivers.DefaultOwner)
+	if storageClass != "" {
+		storedBucket.metadata.StorageClass = storageClass
+	}
 	memory.bucketMetadata[bucket] = storedBucket
 	return nil
 }
 
+// SetBucketACL -
+func (memory *memoryDriver) SetBucketACL(bucket string, policy drivers.AccessControlPolicy) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	storedBucket, ok := memory.bucketMetadata[bucket]
+	if ok == false {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	storedBucket.metadata.ACL = policy.GetCannedACL()
+	storedBucket.metadata.AccessControlPolicy = policy
+	memory.bucketMetadata[bucket] = storedBucket
+	return nil
+}
+
+// SetObjectACL -
+func (memory *memoryDriver) SetObjectACL(bucket, key string, policy drivers.AccessControlPolicy) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !drivers.IsValidObject(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
+	}
+	if _, ok := memory.bucketMetadata[bucket]; ok == false {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	objectKey := bucket + "/" + key
+	storedObject, ok := memory.objectMetadata[objectKey]
+	if ok == false {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	storedObject.metadata.AccessControlPolicy = policy
+	memory.objectMetadata[objectKey] = storedObject
+	return nil
+}
+
 // isMD5SumEqual - returns error if md5sum mismatches, success its `nil`
 func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) error {
 	if strings.TrimSpace(expectedMD5Sum) != "" && strings.TrimSpace(actualMD5Sum) != "" {
@@ -196,13 +243,18 @@ func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) error {
 	return iodine.New(errors.New("invalid argument"), nil)
 }
 
-// CreateObject - PUT object to memory buffer
-func (memory *memoryDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string, data io.Reader) error {
+// CreateObject - PUT object to memory buffer along with its access control policy, private unless
+// given. Storage class is validated but otherwise ignored
+func (memory *memoryDriver) CreateObject(bucket, key, contentType, storageClass, expectedMD5Sum string, size int64, data io.Reader, policy *drivers.AccessControlPolicy) error {
 	memory.lock.RLock()
 	if !drivers.IsValidBucket(bucket) {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		memory.lock.RUnlock()
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, nil)
+	}
 	if !drivers.IsValidObject(key) {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
@@ -212,10 +264,6 @@ func (memory *memoryDriver) CreateObject(bucket, key, contentType, expectedMD5Su
 		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
 	objectKey := bucket + "/" + key
-	if _, ok := memory.objectMetadata[objectKey]; ok == true {
-		memory.lock.RUnlock()
-		return iodine.New(drivers.ObjectExists{Bucket: bucket, Object: key}, nil)
-	}
 	memory.lock.RUnlock()
 
 	if contentType == "" {
@@ -271,12 +319,15 @@ func (memory *memoryDriver) CreateObject(bucket, key, contentType, expectedMD5Su
 		Created:     time.Now(),
 		Md5:         md5Sum,
 		Size:        int64(totalLength),
+
+		AccessControlPolicy: drivers.NewCannedACLPolicy(drivers.BucketPrivate, drivers.DefaultOwner),
 	}
-	memory.lock.Lock()
-	if _, ok := memory.objectMetadata[objectKey]; ok == true {
-		memory.lock.Unlock()
-		return iodine.New(drivers.ObjectExists{Bucket: bucket, Object: key}, nil)
+	if policy != nil {
+		newObject.metadata.AccessControlPolicy = *policy
 	}
+	memory.lock.Lock()
+	// replace an existing object, evicting it releases its size
+	memory.objects.Remove(objectKey)
 	memory.objectMetadata[objectKey] = newObject
 	memory.objects.Add(objectKey, bytesBuffer.Bytes())
 	memory.totalSize = memory.totalSize + uint64(newObject.metadata.Size)
@@ -288,7 +339,7 @@ func (memory *memoryDriver) CreateObject(bucket, key, contentType, expectedMD5Su
 }
 
 // CreateBucket - create bucket in memory
-func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
+func (memory *memoryDriver) CreateBucket(bucketName, acl, storageClass string) error {
 	memory.lock.RLock()
 	if len(memory.bucketMetadata) == totalBuckets {
 		memory.lock.RLock()
@@ -302,6 +353,10 @@ func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.InvalidACL{ACL: acl}, nil)
 	}
+	if !drivers.IsValidStorageClass(storageClass) {
+		memory.lock.RUnlock()
+		return iodine.New(drivers.InvalidStorageClass{StorageClass: storageClass}, nil)
+	}
 	if _, ok := memory.bucketMetadata[bucketName]; ok == true {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketExists{Bucket: bucketName}, nil)
@@ -317,6 +372,8 @@ func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
 	newBucket.metadata.Name = bucketName
 	newBucket.metadata.Created = time.Now()
 	newBucket.metadata.ACL = drivers.BucketACL(acl)
+	newBucket.metadata.AccessControlPolicy = drivers.NewCannedACLPolicy(drivers.BucketACL(acl), drivers.DefaultOwner)
+	newBucket.metadata.StorageClass = storageClass
 	memory.lock.Lock()
 	defer memory.lock.Unlock()
 	memory.bucketMetadata[bucketName] = newBucket
@@ -324,65 +381,6 @@ func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
 	return nil
 }
 
-func delimiter(object, delimiter string) string {
-	readBuffer := bytes.NewBufferString(object)
-	reader := bufio.NewReader(readBuffer)
-	stringReader := strings.NewReader(delimiter)
-	delimited, _ := stringReader.ReadByte()
-	delimitedStr, _ := reader.ReadString(delimited)
-	return delimitedStr
-}
-
-func appendUniq(slice []string, i string) []string {
-	for _, ele := range slice {
-		if ele == i {
-			return slice
-		}
-	}
-	return append(slice, i)
-}
-
-func (memory *memoryDriver) filterDelimiterPrefix(keys []string, key, delimitedName string, resources drivers.BucketResourcesMetadata) (drivers.BucketResourcesMetadata, []string) {
-	switch true {
-	case key == resources.Prefix:
-		keys = appendUniq(keys, key)
-	// DelimitedName - requires resources.Prefix as it was trimmed off earlier in the flow
-	case key == resources.Prefix+delimitedName:
-		keys = appendUniq(keys, key)
-	case delimitedName != "":
-		resources.CommonPrefixes = appendUniq(resources.CommonPrefixes, resources.Prefix+delimitedName)
-	}
-	return resources, keys
-}
-
-func (memory *memoryDriver) listObjectsInternal(keys []string, key string, resources drivers.BucketResourcesMetadata) ([]string, drivers.BucketResourcesMetadata) {
-	switch true {
-	// Prefix absent, delimit object key based on delimiter
-	case resources.IsDelimiterSet():
-		delimitedName := delimiter(key, resources.Delimiter)
-		switch true {
-		case delimitedName == "" || delimitedName == key:
-			keys = appendUniq(keys, key)
-		case delimitedName != "":
-			resources.CommonPrefixes = appendUniq(resources.CommonPrefixes, delimitedName)
-		}
-	// Prefix present, delimit object key with prefix key based on delimiter
-	case resources.IsDelimiterPrefixSet():
-		if strings.HasPrefix(key, resources.Prefix) {
-			trimmedName := strings.TrimPrefix(key, resources.Prefix)
-			delimitedName := delimiter(trimmedName, resources.Delimiter)
-			resources, keys = memory.filterDelimiterPrefix(keys, key, delimitedName, resources)
-		}
-	// Prefix present, nothing to delimit
-	case resources.IsPrefixSet():
-		keys = appendUniq(keys, key)
-	// Prefix and delimiter absent
-	case resources.IsDefault():
-		keys = appendUniq(keys, key)
-	}
-	return keys, resources
-}
-
 // ListObjects - list objects from memory
 func (memory *memoryDriver) ListObjects(bucket string, resources drivers.BucketResourcesMetadata) ([]drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
 	memory.lock.RLock()
@@ -396,23 +394,24 @@ func (memory *memoryDriver) ListObjects(bucket string, resources drivers.BucketR
 	if _, ok := memory.bucketMetadata[bucket]; ok == false {
 		return nil, drivers.BucketResourcesMetadata{IsTruncated: false}, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
-	var results []drivers.ObjectMetadata
 	var keys []string
 	for key := range memory.objectMetadata {
 		if strings.HasPrefix(key, bucket+"/") {
-			key = key[len(bucket)+1:]
-			keys, resources = memory.listObjectsInternal(keys, key, resources)
+			keys = append(keys, key[len(bucket)+1:])
 		}
 	}
 	sort.Strings(keys)
+	if resources.Maxkeys <= 0 {
+		resources.Maxkeys = 1000
+	}
+	keys, resources.CommonPrefixes, resources.IsTruncated = drivers.ListObjectsPage(keys, resources.Prefix, resources.Marker, resources.Delimiter, resources.Maxkeys)
+	resources.NextMarker = ""
+	if resources.IsTruncated {
+		resources.NextMarker = drivers.GetNextMarker(keys, resources.CommonPrefixes)
+	}
+	var results []drivers.ObjectMetadata
 	for _, key := range keys {
-		if len(results) == resources.Maxkeys {
-			return results, drivers.BucketResourcesMetadata{IsTruncated: true}, nil
-		}
-		object := memory.objectMetadata[bucket+"/"+key]
-		if bucket == object.metadata.Bucket {
-			results = append(results, object.metadata)
-		}
+		results = append(results, memory.objectMetadata[bucket+"/"+key].metadata)
 	}
 	return results, resources, nil
 }
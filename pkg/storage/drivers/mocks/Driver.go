@@ -27,8 +27,8 @@ func (m *Driver) ListBuckets() ([]drivers.BucketMetadata, error) {
 }
 
 // CreateBucket is a mock
-func (m *Driver) CreateBucket(bucket, acl string) error {
-	ret := m.Called(bucket, acl)
+func (m *Driver) CreateBucket(bucket, acl, storageClass string) error {
+	ret := m.Called(bucket, acl, storageClass)
 
 	r0 := ret.Error(0)
 
@@ -45,8 +45,8 @@ func (m *Driver) GetBucketMetadata(bucket string) (drivers.BucketMetadata, error
 }
 
 // SetBucketMetadata is a mock
-func (m *Driver) SetBucketMetadata(bucket, acl string) error {
-	ret := m.Called(bucket, acl)
+func (m *Driver) SetBucketMetadata(bucket, acl, storageClass string) error {
+	ret := m.Called(bucket, acl, storageClass)
 
 	r0 := ret.Error(0)
 
@@ -116,8 +116,26 @@ func (m *Driver) ListObjects(bucket string, resources drivers.BucketResourcesMet
 }
 
 // CreateObject is a mock
-func (m *Driver) CreateObject(bucket string, key string, contentType string, md5sum string, data io.Reader) error {
-	ret := m.Called(bucket, key, contentType, md5sum, data)
+func (m *Driver) CreateObject(bucket string, key string, contentType string, storageClass string, md5sum string, size int64, data io.Reader, policy *drivers.AccessControlPolicy) error {
+	ret := m.Called(bucket, key, contentType, storageClass, md5sum, size, data, policy)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetBucketACL is a mock
+func (m *Driver) SetBucketACL(bucket string, policy drivers.AccessControlPolicy) error {
+	ret := m.Called(bucket, policy)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectACL is a mock
+func (m *Driver) SetObjectACL(bucket, key string, policy drivers.AccessControlPolicy) error {
+	ret := m.Called(bucket, key, policy)
 
 	r0 := ret.Error(0)
 
@@ -0,0 +1,79 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drivers
+
+import "strings"
+
+// GetCommonPrefix - returns the common prefix an object rolls up into, if the
+// remainder of its name after prefix contains delimiter, otherwise returns ""
+func GetCommonPrefix(objectName, prefix, delimiter string) string {
+	if delimiter == "" {
+		return ""
+	}
+	trimmedName := strings.TrimPrefix(objectName, prefix)
+	index := strings.Index(trimmedName, delimiter)
+	if index < 0 {
+		return ""
+	}
+	return prefix + trimmedName[:index+len(delimiter)]
+}
+
+// ListObjectsPage - walks a sorted list of object names and returns a single page
+// of objects and common prefixes, both count towards maxkeys. A page is truncated
+// only if another object or common prefix follows it.
+//
+// Objects lexically smaller or equal to marker are skipped, so is a common prefix
+// equal to marker since it was returned as the last entry of a previous page.
+func ListObjectsPage(objects []string, prefix, marker, delimiter string, maxkeys int) (results []string, commonPrefixes []string, isTruncated bool) {
+	for _, objectName := range objects {
+		if !strings.HasPrefix(objectName, prefix) || objectName <= marker {
+			continue
+		}
+		commonPrefix := GetCommonPrefix(objectName, prefix, delimiter)
+		if commonPrefix != "" {
+			if commonPrefix == marker {
+				continue
+			}
+			// objects are sorted, objects sharing a common prefix are always adjacent
+			if len(commonPrefixes) > 0 && commonPrefixes[len(commonPrefixes)-1] == commonPrefix {
+				continue
+			}
+		}
+		if len(results)+len(commonPrefixes) == maxkeys {
+			isTruncated = true
+			break
+		}
+		if commonPrefix != "" {
+			commonPrefixes = append(commonPrefixes, commonPrefix)
+			continue
+		}
+		results = append(results, objectName)
+	}
+	return results, commonPrefixes, isTruncated
+}
+
+// GetNextMarker - last entry of a page, the greater of the last object and the last common prefix
+func GetNextMarker(objects, commonPrefixes []string) string {
+	var nextMarker string
+	if len(objects) > 0 {
+		nextMarker = objects[len(objects)-1]
+	}
+	if len(commonPrefixes) > 0 && commonPrefixes[len(commonPrefixes)-1] > nextMarker {
+		nextMarker = commonPrefixes[len(commonPrefixes)-1]
+	}
+	return nextMarker
+}